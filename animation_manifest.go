@@ -0,0 +1,444 @@
+package hasp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rmcsoft/chanim"
+)
+
+// AnimationManifestFileName is the name of the manifest in the image directory
+const AnimationManifestFileName = "manifest.json"
+
+// AnimatorFrameRate is the fixed frame rate of chanim.Animator
+const AnimatorFrameRate = 25
+
+// AnimationMode is the way the frames of an animation are played
+type AnimationMode string
+
+const (
+	// LoopMode plays the frames in a loop
+	LoopMode AnimationMode = "loop"
+	// PingPongMode plays the frames forward and then backward in a loop
+	PingPongMode AnimationMode = "ping-pong"
+	// OnceMode plays the frames once and then switches to the Next animation
+	OnceMode AnimationMode = "once"
+)
+
+// ManifestDuration is time.Duration given as a string in JSON, e.g. "80ms"
+type ManifestDuration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *ManifestDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = ManifestDuration(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d ManifestDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// FrameHold shows the frame for the duration instead of the frame duration
+type FrameHold struct {
+	// Frame is the frame index in the series, starting from 0
+	Frame    int              `json:"frame"`
+	Duration ManifestDuration `json:"duration"`
+}
+
+// AnimationSpec is the manifest entry of a frame series
+type AnimationSpec struct {
+	// FrameDuration is how long each frame is shown, one animator frame
+	// by default
+	FrameDuration ManifestDuration `json:"frameDuration,omitempty"`
+	Mode          AnimationMode    `json:"mode,omitempty"`
+	// Next is the animation played after a OnceMode animation
+	Next string `json:"next,omitempty"`
+	// Weight is the relative chance to be picked as an idle animation,
+	// 1 by default
+	Weight *float64    `json:"weight,omitempty"`
+	Holds  []FrameHold `json:"holds,omitempty"`
+	// Markers names the frames to leave the animation from, see TransitionSpec
+	Markers map[string]int `json:"markers,omitempty"`
+	// Layers are drawn over the frames of the animation, e.g. the eyes
+	Layers []LayerSpec `json:"layers,omitempty"`
+}
+
+// LayerSpec is a frame series drawn over the frames of another series or
+// as an overlay. The layer frames are played in a loop, independently of the
+// frames under them. X and Y are in the coordinates of the packed frames.
+type LayerSpec struct {
+	Series string `json:"series"`
+	X      int    `json:"x,omitempty"`
+	Y      int    `json:"y,omitempty"`
+}
+
+// AnyAnimation matches any animation in TransitionSpec
+const AnyAnimation = "*"
+
+// TransitionSpec declares the transition from one animation to another.
+// The transition starts on the source frames given by the frame indexes and
+// the markers, on the first and the last frame if none is given (on the last
+// frame of a OnceMode animation). The bridge series is played between the
+// animations; if it is not set, the <From>_exit and <To>_entry series are
+// played if they exist. Cut switches without any bridge.
+type TransitionSpec struct {
+	From    string   `json:"from,omitempty"`
+	To      string   `json:"to,omitempty"`
+	Frames  []int    `json:"frames,omitempty"`
+	Markers []string `json:"markers,omitempty"`
+	Bridge  string   `json:"bridge,omitempty"`
+	Cut     bool     `json:"cut,omitempty"`
+}
+
+// AnimationManifest is the optional manifest.json in the image directory.
+// The animations are keyed by the frame series name, e.g.:
+//
+//	{
+//	  "animations": {
+//	    "lotus":   {"frameDuration": "80ms", "mode": "ping-pong", "weight": 3},
+//	    "giggles": {"mode": "once", "next": "reading", "weight": 0.5,
+//	                "holds": [{"frame": 12, "duration": "1s"}]},
+//	    "reading": {"markers": {"page-turned": 30},
+//	                "layers": [{"series": "reading_eyes", "x": 210, "y": 380}]}
+//	  },
+//	  "transitions": [
+//	    {"from": "reading", "to": "tells", "markers": ["page-turned"], "bridge": "reading->tells"},
+//	    {"from": "*", "to": "silent", "cut": true}
+//	  ],
+//	  "fallback": {},
+//	  "overlays": {
+//	    "listening": {"series": "mic_icon", "x": 20, "y": 20}
+//	  }
+//	}
+//
+// If the manifest declares transitions, only the declared transitions are
+// made and the animator fails to load if a pair of the animations has
+// neither a transition nor the fallback. Otherwise every animation goes to
+// every other one from its first and last frames.
+//
+// The animator draws AnimatorFrameRate frames per second. The frame
+// durations and the holds are rounded to the animator frames, longer
+// durations are made by repeating the frames.
+//
+// The overlays are shown over any animation at runtime, see Overlays.
+type AnimationManifest struct {
+	Animations  map[string]AnimationSpec `json:"animations,omitempty"`
+	Transitions []TransitionSpec         `json:"transitions,omitempty"`
+	Fallback    *TransitionSpec          `json:"fallback,omitempty"`
+	Overlays    map[string]LayerSpec     `json:"overlays,omitempty"`
+}
+
+// LoadAnimationManifest loads the manifest from the image directory.
+// It returns an empty manifest if there is no manifest.
+func LoadAnimationManifest(imageDir string) (AnimationManifest, error) {
+	fileName := filepath.Join(imageDir, AnimationManifestFileName)
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return AnimationManifest{}, nil
+	}
+	if err != nil {
+		return AnimationManifest{}, err
+	}
+
+	var manifest AnimationManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return AnimationManifest{}, fmt.Errorf("Failed to parse animation manifest '%s': %v", fileName, err)
+	}
+	return manifest, nil
+}
+
+// Validate checks the manifest against the loaded frame series
+func (m AnimationManifest) Validate(allFrameSeries []chanim.FrameSeries) error {
+	frameCounts := make(map[string]int, len(allFrameSeries))
+	for _, frameSeries := range allFrameSeries {
+		frameCounts[frameSeries.Name] = len(frameSeries.Frames)
+	}
+	isAnimation := func(name string) bool {
+		_, ok := frameCounts[name]
+		return ok && !isTransitName(name) && !m.isAuxiliary(name)
+	}
+
+	for name, spec := range m.Animations {
+		frameCount, ok := frameCounts[name]
+		if !ok {
+			return fmt.Errorf("Manifest: unknown frame series '%s'", name)
+		}
+		if spec.FrameDuration < 0 {
+			return fmt.Errorf("Manifest: '%s': negative frame duration", name)
+		}
+		if spec.Weight != nil && *spec.Weight < 0 {
+			return fmt.Errorf("Manifest: '%s': negative weight", name)
+		}
+		if isTransitName(name) && (spec.Mode != "" || spec.Weight != nil || len(spec.Markers) > 0) {
+			return fmt.Errorf("Manifest: '%s': transition frames can only set the timing", name)
+		}
+
+		switch spec.Mode {
+		case "", LoopMode, PingPongMode:
+			if spec.Next != "" {
+				return fmt.Errorf("Manifest: '%s': next is only allowed in '%s' mode", name, OnceMode)
+			}
+		case OnceMode:
+			if spec.Next == "" {
+				return fmt.Errorf("Manifest: '%s': '%s' mode requires next animation", name, OnceMode)
+			}
+			if !isAnimation(spec.Next) {
+				return fmt.Errorf("Manifest: '%s': unknown next animation '%s'", name, spec.Next)
+			}
+		default:
+			return fmt.Errorf("Manifest: '%s': unknown mode '%s'", name, spec.Mode)
+		}
+
+		for _, hold := range spec.Holds {
+			if hold.Frame < 0 || hold.Frame >= frameCount {
+				return fmt.Errorf("Manifest: '%s': no frame %d to hold", name, hold.Frame)
+			}
+		}
+		for marker, frame := range spec.Markers {
+			if frame < 0 || frame >= frameCount {
+				return fmt.Errorf("Manifest: '%s': no frame %d for marker '%s'", name, frame, marker)
+			}
+		}
+		for _, layer := range spec.Layers {
+			if err := validateLayer(layer, frameCounts); err != nil {
+				return fmt.Errorf("Manifest: '%s': layer %v", name, err)
+			}
+		}
+	}
+
+	for name, overlay := range m.Overlays {
+		if err := validateLayer(overlay, frameCounts); err != nil {
+			return fmt.Errorf("Manifest: overlay '%s': %v", name, err)
+		}
+	}
+
+	pairs := make(map[[2]string]bool, len(m.Transitions))
+	for _, spec := range m.Transitions {
+		pair := [2]string{spec.From, spec.To}
+		if pairs[pair] {
+			return fmt.Errorf("Manifest: duplicate transition %s", spec)
+		}
+		pairs[pair] = true
+
+		for _, name := range pair {
+			if name != AnyAnimation && !isAnimation(name) {
+				return fmt.Errorf("Manifest: transition %s: unknown animation '%s'", spec, name)
+			}
+		}
+		if spec.From == AnyAnimation && (len(spec.Frames) > 0 || len(spec.Markers) > 0) {
+			return fmt.Errorf("Manifest: transition %s: source frames require the source animation", spec)
+		}
+		if err := m.validateTransition(spec, frameCounts); err != nil {
+			return fmt.Errorf("Manifest: transition %s: %v", spec, err)
+		}
+	}
+
+	if m.Fallback != nil {
+		if m.Fallback.From != "" || m.Fallback.To != "" || len(m.Fallback.Frames) > 0 || len(m.Fallback.Markers) > 0 {
+			return fmt.Errorf("Manifest: fallback transition can only set the bridge")
+		}
+		if err := m.validateTransition(*m.Fallback, frameCounts); err != nil {
+			return fmt.Errorf("Manifest: fallback transition: %v", err)
+		}
+	}
+	return nil
+}
+
+func validateLayer(layer LayerSpec, frameCounts map[string]int) error {
+	frameCount, ok := frameCounts[layer.Series]
+	if !ok {
+		return fmt.Errorf("unknown series '%s'", layer.Series)
+	}
+	if frameCount == 0 {
+		return fmt.Errorf("series '%s' has no frame", layer.Series)
+	}
+	if isTransitName(layer.Series) {
+		return fmt.Errorf("'%s' is a transition series", layer.Series)
+	}
+	return nil
+}
+
+func (m AnimationManifest) validateTransition(spec TransitionSpec, frameCounts map[string]int) error {
+	if _, ok := frameCounts[spec.Bridge]; spec.Bridge != "" && !ok {
+		return fmt.Errorf("unknown bridge series '%s'", spec.Bridge)
+	}
+	if spec.Bridge != "" && spec.Cut {
+		return fmt.Errorf("cut transition can't have a bridge")
+	}
+	for _, frame := range spec.Frames {
+		if frame < 0 || frame >= frameCounts[spec.From] {
+			return fmt.Errorf("no frame %d", frame)
+		}
+	}
+	for _, marker := range spec.Markers {
+		if _, ok := m.Animations[spec.From].Markers[marker]; !ok {
+			return fmt.Errorf("unknown marker '%s'", marker)
+		}
+	}
+	return nil
+}
+
+func (spec TransitionSpec) String() string {
+	return fmt.Sprintf("'%s' -> '%s'", spec.From, spec.To)
+}
+
+// HasTransitions reports whether the manifest declares the transitions
+func (m AnimationManifest) HasTransitions() bool {
+	return len(m.Transitions) > 0 || m.Fallback != nil
+}
+
+// isAuxiliary reports whether the series is a bridge of a transition, a layer
+// or an overlay, not an animation
+func (m AnimationManifest) isAuxiliary(name string) bool {
+	if m.Fallback != nil && m.Fallback.Bridge == name {
+		return true
+	}
+	for _, spec := range m.Transitions {
+		if spec.Bridge == name {
+			return true
+		}
+	}
+	return m.isLayer(name)
+}
+
+// isLayer reports whether the series is a layer or an overlay
+func (m AnimationManifest) isLayer(name string) bool {
+	for _, spec := range m.Animations {
+		for _, layer := range spec.Layers {
+			if layer.Series == name {
+				return true
+			}
+		}
+	}
+	for _, overlay := range m.Overlays {
+		if overlay.Series == name {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionBetween finds the declared transition: the exact one, then the
+// one from the animation, the one to the animation and the fallback
+func (m AnimationManifest) transitionBetween(from string, to string) (TransitionSpec, bool) {
+	for _, pair := range [][2]string{{from, to}, {from, AnyAnimation}, {AnyAnimation, to}} {
+		for _, spec := range m.Transitions {
+			if spec.From == pair[0] && spec.To == pair[1] {
+				return spec, true
+			}
+		}
+	}
+	if m.Fallback != nil {
+		return *m.Fallback, true
+	}
+	return TransitionSpec{}, false
+}
+
+// sourceFrames returns the indexes of the frames the transition starts on
+func (m AnimationManifest) sourceFrames(spec TransitionSpec, from string, frameCount int) []int {
+	var frames []int
+	if spec.From == from {
+		frames = append(frames, spec.Frames...)
+		for _, marker := range spec.Markers {
+			frames = append(frames, m.Animations[from].Markers[marker])
+		}
+	}
+	if len(frames) > 0 {
+		return frames
+	}
+
+	if m.Mode(from) == OnceMode {
+		return []int{frameCount - 1}
+	}
+	return []int{0, frameCount - 1}
+}
+
+// Mode returns the mode of the animation
+func (m AnimationManifest) Mode(name string) AnimationMode {
+	if spec, ok := m.Animations[name]; ok && spec.Mode != "" {
+		return spec.Mode
+	}
+	return LoopMode
+}
+
+// Next returns the animation played after the OnceMode animation
+func (m AnimationManifest) Next(name string) (string, bool) {
+	spec, ok := m.Animations[name]
+	if !ok || spec.Mode != OnceMode {
+		return "", false
+	}
+	return spec.Next, true
+}
+
+// Weights returns the idle selection weights of the animations.
+// It returns nil if the manifest sets no weight for them.
+func (m AnimationManifest) Weights(names []string) map[string]float64 {
+	var weights map[string]float64
+	for _, name := range names {
+		if spec, ok := m.Animations[name]; ok && spec.Weight != nil {
+			if weights == nil {
+				weights = make(map[string]float64, len(names))
+			}
+			weights[name] = *spec.Weight
+		}
+	}
+	return weights
+}
+
+// expandFrames returns the frames as played by the animator: in ping-pong
+// order and repeated to last the frame duration or the hold. It also returns
+// the positions of the last repeat of each frame for every source frame.
+func (m AnimationManifest) expandFrames(name string, frames []chanim.Frame) ([]chanim.Frame, [][]int) {
+	spec := m.Animations[name]
+
+	order := make([]int, 0, 2*len(frames))
+	for i := range frames {
+		order = append(order, i)
+	}
+	if spec.Mode == PingPongMode {
+		for i := len(frames) - 2; i > 0; i-- {
+			order = append(order, i)
+		}
+	}
+
+	durations := make([]time.Duration, len(frames))
+	for i := range durations {
+		durations[i] = time.Duration(spec.FrameDuration)
+	}
+	for _, hold := range spec.Holds {
+		durations[hold.Frame] = time.Duration(hold.Duration)
+	}
+
+	expanded := make([]chanim.Frame, 0, len(order))
+	positions := make([][]int, len(frames))
+	for _, i := range order {
+		for n := frameRepeats(durations[i]); n > 0; n-- {
+			expanded = append(expanded, frames[i])
+		}
+		positions[i] = append(positions[i], len(expanded)-1)
+	}
+	return expanded, positions
+}
+
+// frameRepeats returns how many animator frames the duration lasts
+func frameRepeats(duration time.Duration) int {
+	frameDuration := time.Second / AnimatorFrameRate
+	repeats := int((duration + frameDuration/2) / frameDuration)
+	if repeats < 1 {
+		return 1
+	}
+	return repeats
+}
@@ -0,0 +1,326 @@
+package hasp
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rmcsoft/chanim"
+)
+
+// testFrames returns n frames labeled by their index, see frameLabels
+func testFrames(n int) []chanim.Frame {
+	frames := make([]chanim.Frame, n)
+	for i := range frames {
+		frames[i].DrawOperations = make([]chanim.DrawOperation, i)
+	}
+	return frames
+}
+
+// frameLabels returns the indexes of the frames made by testFrames
+func frameLabels(frames []chanim.Frame) []int {
+	labels := make([]int, len(frames))
+	for i, frame := range frames {
+		labels[i] = len(frame.DrawOperations)
+	}
+	return labels
+}
+
+// transitionFrames returns the indexes of the transition frames of the series
+func transitionFrames(allFrameSeries []chanim.FrameSeries, name string) []int {
+	var frames []int
+	for _, frameSeries := range allFrameSeries {
+		if frameSeries.Name != name {
+			continue
+		}
+		for i, frame := range frameSeries.Frames {
+			if frame.IsTransitionFrame() {
+				frames = append(frames, i)
+			}
+		}
+	}
+	return frames
+}
+
+func TestExpandFrames(t *testing.T) {
+	manifest := AnimationManifest{
+		Animations: map[string]AnimationSpec{
+			"loop":      {},
+			"ping-pong": {Mode: PingPongMode},
+			"slow":      {FrameDuration: ManifestDuration(80 * time.Millisecond)},
+			"held":      {Mode: OnceMode, Next: "loop", Holds: []FrameHold{{Frame: 1, Duration: ManifestDuration(120 * time.Millisecond)}}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		frames    []int
+		positions [][]int
+	}{
+		{"loop", []int{0, 1, 2, 3}, [][]int{{0}, {1}, {2}, {3}}},
+		{"ping-pong", []int{0, 1, 2, 3, 2, 1}, [][]int{{0}, {1, 5}, {2, 4}, {3}}},
+		{"slow", []int{0, 0, 1, 1, 2, 2, 3, 3}, [][]int{{1}, {3}, {5}, {7}}},
+		{"held", []int{0, 1, 1, 1, 2, 3}, [][]int{{0}, {3}, {4}, {5}}},
+	}
+	for _, test := range tests {
+		frames, positions := manifest.expandFrames(test.name, testFrames(4))
+		if labels := frameLabels(frames); !reflect.DeepEqual(labels, test.frames) {
+			t.Errorf("%s: frames %v, want %v", test.name, labels, test.frames)
+		}
+		if !reflect.DeepEqual(positions, test.positions) {
+			t.Errorf("%s: positions %v, want %v", test.name, positions, test.positions)
+		}
+	}
+}
+
+func TestFrameRepeats(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		repeats  int
+	}{
+		{0, 1},
+		{10 * time.Millisecond, 1},
+		{40 * time.Millisecond, 1},
+		{59 * time.Millisecond, 1},
+		{60 * time.Millisecond, 2},
+		{80 * time.Millisecond, 2},
+		{time.Second, AnimatorFrameRate},
+	}
+	for _, test := range tests {
+		if repeats := frameRepeats(test.duration); repeats != test.repeats {
+			t.Errorf("%s: %d repeats, want %d", test.duration, repeats, test.repeats)
+		}
+	}
+}
+
+func TestValidateOnce(t *testing.T) {
+	allFrameSeries := []chanim.FrameSeries{
+		{Name: "giggles", Frames: testFrames(3)},
+		{Name: "reading", Frames: testFrames(3)},
+	}
+
+	tests := []struct {
+		spec  AnimationSpec
+		valid bool
+	}{
+		{AnimationSpec{Mode: OnceMode, Next: "reading"}, true},
+		{AnimationSpec{Mode: OnceMode}, false},
+		{AnimationSpec{Mode: OnceMode, Next: "unknown"}, false},
+		{AnimationSpec{Mode: LoopMode, Next: "reading"}, false},
+		{AnimationSpec{Mode: PingPongMode, Next: "reading"}, false},
+		{AnimationSpec{Mode: "twice"}, false},
+	}
+	for _, test := range tests {
+		manifest := AnimationManifest{Animations: map[string]AnimationSpec{"giggles": test.spec}}
+		if err := manifest.Validate(allFrameSeries); (err == nil) != test.valid {
+			t.Errorf("%+v: error %v, want valid %v", test.spec, err, test.valid)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	manifest := AnimationManifest{
+		Animations: map[string]AnimationSpec{
+			"giggles": {Mode: OnceMode, Next: "reading"},
+			"lotus":   {Mode: PingPongMode},
+		},
+	}
+
+	tests := []struct {
+		name string
+		next string
+		ok   bool
+	}{
+		{"giggles", "reading", true},
+		{"lotus", "", false},
+		{"reading", "", false},
+	}
+	for _, test := range tests {
+		if next, ok := manifest.Next(test.name); next != test.next || ok != test.ok {
+			t.Errorf("Next of %s: '%s', %v, want '%s', %v", test.name, next, ok, test.next, test.ok)
+		}
+	}
+}
+
+func TestTransitionFrames(t *testing.T) {
+	manifest := AnimationManifest{
+		Animations: map[string]AnimationSpec{
+			"giggles": {Mode: OnceMode, Next: "reading"},
+			"lotus":   {Mode: PingPongMode},
+		},
+	}
+	allFrameSeries := []chanim.FrameSeries{
+		{Name: "giggles", Frames: testFrames(3)},
+		{Name: "lotus", Frames: testFrames(4)},
+		{Name: "reading", Frames: testFrames(3)},
+	}
+
+	_, allFrameSeries, err := prepareAnimations(allFrameSeries, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		frames []int
+	}{
+		// The play-once animation is left only after the last frame
+		{"giggles", []int{2}},
+		// The last frame of the ping-pong animation is the turning one
+		{"lotus", []int{0, 3}},
+		{"reading", []int{0, 2}},
+	}
+	for _, test := range tests {
+		if frames := transitionFrames(allFrameSeries, test.name); !reflect.DeepEqual(frames, test.frames) {
+			t.Errorf("%s: transition frames %v, want %v", test.name, frames, test.frames)
+		}
+	}
+}
+
+func TestTransitionBetween(t *testing.T) {
+	transitions := []TransitionSpec{
+		{From: "lotus", To: "reading", Bridge: "exact"},
+		{From: AnyAnimation, To: "reading", Bridge: "to-reading"},
+		{From: "lotus", To: AnyAnimation, Cut: true},
+	}
+	fallback := &TransitionSpec{Bridge: "fallback"}
+
+	tests := []struct {
+		from     string
+		to       string
+		fallback *TransitionSpec
+		spec     TransitionSpec
+		ok       bool
+	}{
+		// The exact transition wins over the wildcards whatever the order
+		{"lotus", "reading", fallback, transitions[0], true},
+		// The transition from the animation wins over the one to the other
+		{"lotus", "silent", fallback, transitions[2], true},
+		{"silent", "reading", fallback, transitions[1], true},
+		{"silent", "lotus", fallback, *fallback, true},
+		{"silent", "lotus", nil, TransitionSpec{}, false},
+	}
+	for _, test := range tests {
+		manifest := AnimationManifest{Transitions: transitions, Fallback: test.fallback}
+		spec, ok := manifest.transitionBetween(test.from, test.to)
+		if !reflect.DeepEqual(spec, test.spec) || ok != test.ok {
+			t.Errorf("'%s' -> '%s': %+v, %v, want %+v, %v", test.from, test.to, spec, ok, test.spec, test.ok)
+		}
+	}
+}
+
+// frameTransitions returns the transitions of the frame as
+// "destination via series" sorted by destination
+func frameTransitions(allFrameSeries []chanim.FrameSeries, name string, frame int) []string {
+	var transitions []string
+	for _, frameSeries := range allFrameSeries {
+		if frameSeries.Name != name {
+			continue
+		}
+		for _, transition := range frameSeries.Frames[frame].Transitions {
+			transitions = append(transitions, transition.DestAnimationName+" via '"+transition.FrameSeriesName+"'")
+		}
+	}
+	sort.Strings(transitions)
+	return transitions
+}
+
+func TestDeclaredTransitions(t *testing.T) {
+	manifest := AnimationManifest{
+		Animations: map[string]AnimationSpec{
+			"lotus": {Markers: map[string]int{"closed": 2}},
+		},
+		Transitions: []TransitionSpec{
+			{From: "lotus", To: "reading", Frames: []int{1}, Markers: []string{"closed"}, Bridge: "lotus_to_reading"},
+			{From: "reading", To: AnyAnimation, Cut: true},
+		},
+		// The other transitions are made of the exit and entry series
+		Fallback: &TransitionSpec{},
+	}
+	allFrameSeries := []chanim.FrameSeries{
+		{Name: "lotus", Frames: testFrames(4)},
+		{Name: "lotus_exit", Frames: testFrames(1)},
+		{Name: "lotus_to_reading", Frames: testFrames(2)},
+		{Name: "reading", Frames: testFrames(3)},
+		{Name: "silent", Frames: testFrames(2)},
+		{Name: "silent_entry", Frames: testFrames(1)},
+	}
+
+	animations, allFrameSeries, err := prepareAnimations(allFrameSeries, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, animation := range animations {
+		names = append(names, animation.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"lotus", "reading", "silent"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Animations %v, want %v: the bridge is not an animation", names, want)
+	}
+
+	tests := []struct {
+		name        string
+		frame       int
+		transitions []string
+	}{
+		// The declared bridge wins over the exit series and starts on the
+		// declared frames only, 'lotus -> silent' is lotus_exit and silent_entry
+		{"lotus", 0, []string{"silent via 'lotus -> silent'"}},
+		{"lotus", 1, []string{"reading via 'lotus_to_reading'"}},
+		{"lotus", 2, []string{"reading via 'lotus_to_reading'"}},
+		{"lotus", 3, []string{"silent via 'lotus -> silent'"}},
+		{"reading", 0, []string{"lotus via ''", "silent via ''"}},
+		{"reading", 1, nil},
+		{"reading", 2, []string{"lotus via ''", "silent via ''"}},
+		{"silent", 0, []string{"lotus via ''", "reading via ''"}},
+		{"silent", 1, []string{"lotus via ''", "reading via ''"}},
+	}
+	for _, test := range tests {
+		transitions := frameTransitions(allFrameSeries, test.name, test.frame)
+		if !reflect.DeepEqual(transitions, test.transitions) {
+			t.Errorf("%s frame %d: transitions %v, want %v", test.name, test.frame, transitions, test.transitions)
+		}
+	}
+}
+
+func TestDeclaredTransitionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest AnimationManifest
+	}{
+		{"missing bridge series", AnimationManifest{
+			Transitions: []TransitionSpec{{From: "lotus", To: "reading", Bridge: "lotus_to_reading"}},
+			Fallback:    &TransitionSpec{},
+		}},
+		{"missing fallback bridge series", AnimationManifest{
+			Fallback: &TransitionSpec{Bridge: "bridge"},
+		}},
+		{"missing animation", AnimationManifest{
+			Transitions: []TransitionSpec{{From: "lotus", To: "giggles", Cut: true}},
+			Fallback:    &TransitionSpec{},
+		}},
+		{"missing transition", AnimationManifest{
+			Transitions: []TransitionSpec{{From: "lotus", To: "reading", Cut: true}},
+		}},
+		{"duplicate transition", AnimationManifest{
+			Transitions: []TransitionSpec{{From: "lotus", To: "reading", Cut: true}, {From: "lotus", To: "reading"}},
+			Fallback:    &TransitionSpec{},
+		}},
+		{"cut bridge", AnimationManifest{
+			Transitions: []TransitionSpec{{From: "lotus", To: "reading", Cut: true, Bridge: "lotus_exit"}},
+			Fallback:    &TransitionSpec{},
+		}},
+	}
+	for _, test := range tests {
+		allFrameSeries := []chanim.FrameSeries{
+			{Name: "lotus", Frames: testFrames(4)},
+			{Name: "lotus_exit", Frames: testFrames(1)},
+			{Name: "reading", Frames: testFrames(3)},
+		}
+		if _, _, err := prepareAnimations(allFrameSeries, test.manifest); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package hasp
+
+import (
+	"fmt"
+
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/sound"
+)
+
+const (
+	// AnnouncementEventName is emitted to play an announcement out of the
+	// schedule, see Character.Announce
+	AnnouncementEventName = "Announcement"
+)
+
+// AnnouncementEventData is the AnnouncementEvent data
+type AnnouncementEventData struct {
+	Speech *sound.AudioData
+}
+
+// NewAnnouncementEvent creates AnnouncementEvent
+func NewAnnouncementEvent(speech *sound.AudioData) *events.Event {
+	return &events.Event{
+		Name: AnnouncementEventName,
+		Args: []interface{}{AnnouncementEventData{Speech: speech}},
+	}
+}
+
+// GetAnnouncementEventData gets AnnouncementEvent data
+func GetAnnouncementEventData(event *events.Event) (AnnouncementEventData, error) {
+	if event.Name != AnnouncementEventName {
+		return AnnouncementEventData{}, fmt.Errorf("The event must be named %s", AnnouncementEventName)
+	}
+
+	if len(event.Args) != 1 {
+		return AnnouncementEventData{}, fmt.Errorf("Event does not contain data")
+	}
+
+	data, ok := event.Args[0].(AnnouncementEventData)
+	if !ok {
+		return AnnouncementEventData{}, fmt.Errorf("Invalid event data type")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,62 @@
+package hasp
+
+import (
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/schedule"
+	"github.com/rmcsoft/hasp/sound"
+	"github.com/sirupsen/logrus"
+)
+
+type announcementState struct {
+	availableAnimations []string
+	currentAnimation    int
+	clips               map[string]*sound.AudioData
+	speech              *sound.AudioData
+}
+
+// NewAnnouncementState creates new AnnouncementState.
+// It plays the clip named by the AnnouncementDue event or the speech
+// of AnnouncementEvent.
+func NewAnnouncementState(availableAnimations []string, clips map[string]*sound.AudioData) State {
+	return &announcementState{
+		availableAnimations: availableAnimations,
+		clips:               clips,
+	}
+}
+
+func (s *announcementState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
+	s.speech = nil
+	switch event.Name {
+	case schedule.AnnouncementDueEventName:
+		if data, err := schedule.GetAnnouncementDueEventData(&event); err == nil {
+			s.speech = s.clips[data.Name]
+			if s.speech == nil {
+				logrus.Warnf("Unknown announcement '%s'", data.Name)
+			}
+		}
+	case AnnouncementEventName:
+		if data, err := GetAnnouncementEventData(&event); err == nil {
+			s.speech = data.Speech
+		}
+	}
+
+	if s.speech == nil {
+		return events.EventSources{events.NewSingleEventSource(sound.SoundPlayedEventName, func() *events.Event {
+			return &events.Event{Name: sound.SoundPlayedEventName}
+		})}, nil
+	}
+	return nil, nil
+}
+
+func (s *announcementState) Leave(ctx CharacterCtx, event events.Event) bool {
+	return true
+}
+
+func (s *announcementState) GetAnimation() string {
+	animation := s.availableAnimations[s.currentAnimation]
+	return animation
+}
+
+func (s *announcementState) GetSound() *sound.AudioData {
+	return s.speech
+}
@@ -5,12 +5,12 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"syscall"
 	"time"
 
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/physic"
 	"periph.io/x/periph/conn/pin"
-	"periph.io/x/periph/host/fs"
 )
 
 // Pins is all the pins exported by GPIO sysfs.
@@ -29,15 +29,18 @@ type Pin struct {
 	name   string
 	root   string
 
+	// wait is held for reading while waiting for an edge, see close
+	wait sync.RWMutex
+
 	mu         sync.Mutex
-	err        error     // If open() failed
-	direction  direction // Cache of the last known direction
-	edge       gpio.Edge // Cache of the last edge used.
-	fDirection fileIO    // handle to /sys/class/gpio/gpio*/direction; never closed
-	fEdge      fileIO    // handle to /sys/class/gpio/gpio*/edge; never closed
-	fValue     fileIO    // handle to /sys/class/gpio/gpio*/value; never closed
-	event      fs.Event  // Initialized once
-	buf        [4]byte   // scratch buffer for Function(), Read() and Out()
+	err        error      // If open() failed
+	direction  direction  // Cache of the last known direction
+	edge       gpio.Edge  // Cache of the last edge used.
+	fDirection fileIO     // handle to /sys/class/gpio/gpio*/direction; never closed
+	fEdge      fileIO     // handle to /sys/class/gpio/gpio*/edge; never closed
+	fValue     fileIO     // handle to /sys/class/gpio/gpio*/value; never closed
+	event      *edgeEvent // Initialized once
+	buf        [4]byte    // scratch buffer for Function(), Read() and Out()
 }
 
 // String implements conn.Resource.
@@ -47,11 +50,15 @@ func (p *Pin) String() string {
 
 // Halt implements conn.Resource.
 //
-// It stops edge detection if enabled.
+// It stops edge detection if enabled and wakes up WaitForEdge.
 func (p *Pin) Halt() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	return p.haltEdge()
+	err := p.haltEdge()
+	if p.event != nil {
+		_ = p.event.Wake()
+	}
+	return err
 }
 
 // Name implements pin.Pin.
@@ -85,12 +92,12 @@ func (p *Pin) Func() pin.Func {
 		p.direction = dOut
 	}
 	if p.direction == dIn {
-		if p.Read() {
+		if p.read() {
 			return gpio.IN_HIGH
 		}
 		return gpio.IN_LOW
 	} else if p.direction == dOut {
-		if p.Read() {
+		if p.read() {
 			return gpio.OUT_HIGH
 		}
 		return gpio.OUT_LOW
@@ -149,7 +156,7 @@ func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
 			if err != nil {
 				return p.wrap(err)
 			}
-			if err = p.event.MakeEvent(p.fValue.Fd()); err != nil {
+			if p.event, err = newEdgeEvent(p.fValue.Fd()); err != nil {
 				_ = p.fEdge.Close()
 				p.fEdge = nil
 				return p.wrap(err)
@@ -183,55 +190,31 @@ func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
 	// CPU. In this case, the loop below is not sufficient, since the interrupt
 	// will happen afterward "out of the blue".
 	if edge != gpio.NoEdge {
-		p.WaitForEdge(0)
+		p.waitForEdge(p.event, 0)
 	}
 	return nil
 }
 
 // Read implements gpio.PinIn.
 func (p *Pin) Read() gpio.Level {
-	// There's no lock here.
-	if p.fValue == nil {
-		return gpio.Low
-	}
-	if _, err := seekRead(p.fValue, p.buf[:]); err != nil {
-		// Error.
-		return gpio.Low
-	}
-	if p.buf[0] == '0' {
-		return gpio.Low
-	}
-	if p.buf[0] == '1' {
-		return gpio.High
-	}
-	// Error.
-	return gpio.Low
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.read()
 }
 
 // WaitForEdge implements gpio.PinIn.
+//
+// It returns false if it is woken up by Halt. WaitForEdge must not be called
+// concurrently.
 func (p *Pin) WaitForEdge(timeout time.Duration) bool {
-	// Run lockless, as the normal use is to call in a busy loop.
-	var ms int
-	if timeout == -1 {
-		ms = -1
-	} else {
-		ms = int(timeout / time.Millisecond)
-	}
-	start := time.Now()
-	for {
-		if nr, err := p.event.Wait(ms); err != nil {
-			return false
-		} else if nr == 1 {
-			return true
-		}
-		// A signal occurred.
-		if timeout != -1 {
-			ms = int((timeout - time.Since(start)) / time.Millisecond)
-		}
-		if ms <= 0 {
-			return false
-		}
-	}
+	// The event is closed by close only after the wait
+	p.wait.RLock()
+	defer p.wait.RUnlock()
+
+	p.mu.Lock()
+	event := p.event
+	p.mu.Unlock()
+	return p.waitForEdge(event, timeout)
 }
 
 // Pull implements gpio.PinIn.
@@ -330,6 +313,83 @@ func (p *Pin) open() error {
 	return p.err
 }
 
+// close closes the gpio sysfs handles. The pin reopens them on the next use.
+// The edge event is closed once WaitForEdge is woken up.
+func (p *Pin) close() {
+	p.mu.Lock()
+	for _, f := range []fileIO{p.fValue, p.fDirection, p.fEdge} {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+	event := p.event
+	p.fValue, p.fDirection, p.fEdge, p.event = nil, nil, nil, nil
+	p.err = nil
+	p.direction = dUnknown
+	p.edge = gpio.NoEdge
+	if event != nil {
+		_ = event.Wake()
+	}
+	p.mu.Unlock()
+
+	if event != nil {
+		p.wait.Lock()
+		_ = event.Close()
+		p.wait.Unlock()
+	}
+}
+
+// read reads the value file.
+//
+// lock must be held.
+func (p *Pin) read() gpio.Level {
+	if p.fValue == nil {
+		return gpio.Low
+	}
+	if _, err := seekRead(p.fValue, p.buf[:]); err != nil {
+		// Error.
+		return gpio.Low
+	}
+	if p.buf[0] == '0' {
+		return gpio.Low
+	}
+	if p.buf[0] == '1' {
+		return gpio.High
+	}
+	// Error.
+	return gpio.Low
+}
+
+// waitForEdge waits for an edge reported by the event
+func (p *Pin) waitForEdge(event *edgeEvent, timeout time.Duration) bool {
+	if event == nil {
+		// Edge detection has never been enabled.
+		return false
+	}
+	var ms int
+	if timeout == -1 {
+		ms = -1
+	} else {
+		ms = int(timeout / time.Millisecond)
+	}
+	start := time.Now()
+	for {
+		if nr, err := event.Wait(ms); err == nil {
+			return nr == 1
+		} else if err != syscall.EINTR {
+			return false
+		}
+		// A signal occurred.
+		if timeout == -1 {
+			continue
+		}
+		ms = int((timeout - time.Since(start)) / time.Millisecond)
+		if ms <= 0 {
+			return false
+		}
+	}
+}
+
 // haltEdge stops any on-going edge detection.
 func (p *Pin) haltEdge() error {
 	if p.edge != gpio.NoEdge {
@@ -338,7 +398,7 @@ func (p *Pin) haltEdge() error {
 		}
 		p.edge = gpio.NoEdge
 		// This is still important to remove an accumulated edge.
-		p.WaitForEdge(0)
+		p.waitForEdge(p.event, 0)
 	}
 	return nil
 }
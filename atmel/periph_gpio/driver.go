@@ -3,11 +3,12 @@ package periph_gpio
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 
-	"github.com/sirupsen/logrus"
 	"periph.io/x/periph/conn/gpio/gpioreg"
-	"periph.io/x/periph/host/fs"
 )
 
 type AtmelGpioPin struct {
@@ -17,46 +18,163 @@ type AtmelGpioPin struct {
 
 type AtmelGpioPins []AtmelGpioPin
 
+// PinError is the error of the pin
+type PinError struct {
+	Pin AtmelGpioPin
+	Err error
+}
+
+func (e PinError) Error() string {
+	return fmt.Sprintf("pin %s (%d): %v", e.Pin.Name, e.Pin.Number, e.Err)
+}
+
+// PinErrors is the errors of the pins which failed to be exported, registered
+// or unexported. The other pins are usable.
+type PinErrors []PinError
+
+func (e PinErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pinErr := range e {
+		msgs[i] = pinErr.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AtmelGpioDriver exports the pins via GPIO sysfs and registers them in gpioreg.
+// Close unexports the pins exported by the driver.
 type AtmelGpioDriver struct {
 	Pins AtmelGpioPins
+
+	// Root is the GPIO sysfs directory, DefaultSysfsRoot if empty
+	Root string
+
+	mutex    sync.Mutex
+	pins     []*Pin // registered pins
+	exported []*Pin // pins exported by the driver
 }
 
 const (
 	AtmelGpioDriverName = "Atmel-GPIO-driver"
+
+	// DefaultSysfsRoot is the default GPIO sysfs directory
+	DefaultSysfsRoot = "/sys/class/gpio"
 )
 
-func (d AtmelGpioDriver) String() string          { return AtmelGpioDriverName }
-func (d AtmelGpioDriver) Prerequisites() []string { return nil }
-func (d AtmelGpioDriver) After() []string         { return nil }
+func (d *AtmelGpioDriver) String() string          { return AtmelGpioDriverName }
+func (d *AtmelGpioDriver) Prerequisites() []string { return nil }
+func (d *AtmelGpioDriver) After() []string         { return nil }
+
+// Init exports and registers the pins.
+// It returns PinErrors if some of the pins are not usable.
+func (d *AtmelGpioDriver) Init() (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
 
-func (d AtmelGpioDriver) Init() (bool, error) {
-	f, err := fs.Open("/sys/class/gpio/export", os.O_WRONLY)
+	root := d.root()
+	f, err := fileIOOpen(filepath.Join(root, "export"), os.O_WRONLY)
+	if os.IsNotExist(err) {
+		return false, fmt.Errorf("GPIO sysfs is not available: %v", err)
+	}
 	if os.IsPermission(err) {
 		return true, fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
 	}
+	if err != nil {
+		return true, err
+	}
 	defer f.Close()
 
 	// There are hosts that use non-continuous pin numbering so use a map instead
 	// of an array.
-	Pins = map[int]*Pin{}
+	if Pins == nil {
+		Pins = map[int]*Pin{}
+	}
+	var pinErrs PinErrors
 	for _, item := range d.Pins {
-		f.WriteString(fmt.Sprintf("%d", item.Number))
-
 		p := &Pin{
 			number: item.Number,
-			name:   fmt.Sprintf("%v", item.Name),
-			root:   fmt.Sprintf("/sys/class/gpio/%v/", item.Name),
+			name:   item.Name,
+			root:   filepath.Join(root, item.Name) + "/",
+		}
+
+		exported := true
+		if err := seekWrite(f, []byte(strconv.Itoa(item.Number))); err != nil {
+			if !isErrBusy(err) {
+				pinErrs = append(pinErrs, PinError{item, fmt.Errorf("export failed: %v", err)})
+				continue
+			}
+			// Already exported by someone else, keep it exported on Close
+			exported = false
 		}
-		//Pins[i] = p
+		if exported {
+			d.exported = append(d.exported, p)
+		}
+
 		if err := gpioreg.Register(p); err != nil {
-			return false, err
+			pinErrs = append(pinErrs, PinError{item, err})
+			continue
 		}
+		d.pins = append(d.pins, p)
+		Pins[item.Number] = p
+
 		// If there is a CPU memory mapped gpio pin with the same number, the
-		// driver has to unregister this pin and map its own after.
+		// pin is only available by its name.
 		if err := gpioreg.RegisterAlias(strconv.Itoa(item.Number), p.name); err != nil {
-			//return false, err
-			logrus.Info("Adding alias failed: ", strconv.Itoa(item.Number), "to", p.name)
+			pinErrs = append(pinErrs, PinError{item, fmt.Errorf("alias failed, use the pin name: %v", err)})
+		}
+	}
+
+	if len(pinErrs) > 0 {
+		return true, pinErrs
+	}
+	return true, nil
+}
+
+// Close unregisters the pins, closes their handles and unexports the pins
+// exported by Init.
+func (d *AtmelGpioDriver) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var pinErrs PinErrors
+	for _, p := range d.pins {
+		item := AtmelGpioPin{p.number, p.name}
+		if err := p.Halt(); err != nil {
+			pinErrs = append(pinErrs, PinError{item, err})
 		}
+		p.close()
+		_ = gpioreg.Unregister(strconv.Itoa(p.number))
+		if err := gpioreg.Unregister(p.name); err != nil {
+			pinErrs = append(pinErrs, PinError{item, err})
+		}
+		delete(Pins, p.number)
+	}
+	d.pins = nil
+
+	if len(d.exported) > 0 {
+		f, err := fileIOOpen(filepath.Join(d.root(), "unexport"), os.O_WRONLY)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		for _, p := range d.exported {
+			if err := seekWrite(f, []byte(strconv.Itoa(p.number))); err != nil {
+				pinErrs = append(pinErrs, PinError{AtmelGpioPin{p.number, p.name},
+					fmt.Errorf("unexport failed: %v", err)})
+			}
+		}
+		d.exported = nil
+	}
+
+	if len(pinErrs) > 0 {
+		return pinErrs
+	}
+	return nil
+}
+
+func (d *AtmelGpioDriver) root() string {
+	if d.Root == "" {
+		return DefaultSysfsRoot
 	}
-	return true, err
+	return d.Root
 }
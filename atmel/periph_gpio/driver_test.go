@@ -0,0 +1,108 @@
+package periph_gpio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+)
+
+// makeExport creates the export and unexport files of the sysfs directory
+func makeExport(t *testing.T, dir string) {
+	for _, file := range []string{"export", "unexport"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDriverRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	makeExport(t, dir)
+	makePin(t, dir, "SYSFS_ROOT")
+
+	d := &AtmelGpioDriver{Pins: AtmelGpioPins{{Number: 42, Name: "SYSFS_ROOT"}}, Root: dir}
+	if ok, err := d.Init(); !ok || err != nil {
+		t.Fatalf("Init: %v, %v", ok, err)
+	}
+	if export := readFile(t, filepath.Join(dir, "export")); export != "42" {
+		t.Errorf("Exported '%s', want '42'", export)
+	}
+
+	p := gpioreg.ByName("SYSFS_ROOT")
+	if p == nil {
+		t.Fatal("The pin is not registered")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "SYSFS_ROOT", "value"), []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if level := p.Read(); level != gpio.High {
+		t.Errorf("Level %v, want High", level)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if unexport := readFile(t, filepath.Join(dir, "unexport")); unexport != "42" {
+		t.Errorf("Unexported '%s', want '42'", unexport)
+	}
+	if p := gpioreg.ByName("SYSFS_ROOT"); p != nil {
+		t.Error("The pin is left registered")
+	}
+}
+
+func TestDriverWithoutSysfs(t *testing.T) {
+	d := &AtmelGpioDriver{Pins: AtmelGpioPins{{Number: 1, Name: "SYSFS_NONE"}}, Root: "/nonexistent"}
+	if ok, err := d.Init(); ok || err == nil {
+		t.Errorf("Init: %v, %v, want the driver skipped", ok, err)
+	}
+}
+
+func TestCloseWhileWaiting(t *testing.T) {
+	dir, restore := fakeSysfs(t)
+	defer restore()
+	makeExport(t, dir)
+	makePin(t, dir, "SYSFS_CLOSE")
+
+	d := &AtmelGpioDriver{Pins: AtmelGpioPins{{Number: 7, Name: "SYSFS_CLOSE"}}, Root: dir}
+	if ok, err := d.Init(); !ok || err != nil {
+		t.Fatalf("Init: %v, %v", ok, err)
+	}
+	p := gpioreg.ByName("SYSFS_CLOSE")
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+
+	edges := make(chan bool)
+	go func() {
+		edges <- p.WaitForEdge(-1)
+	}()
+	// Let the pin wait for an edge
+	time.Sleep(10 * time.Millisecond)
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case edge := <-edges:
+		if edge {
+			t.Error("Close is reported as an edge")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForEdge is not woken up by Close")
+	}
+	if p.WaitForEdge(0) {
+		t.Error("Edge after Close")
+	}
+}
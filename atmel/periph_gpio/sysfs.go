@@ -1,6 +1,7 @@
 package periph_gpio
 
 import (
+	"errors"
 	"io"
 	"os"
 	"syscall"
@@ -28,6 +29,91 @@ func fileIOOpenDefault(path string, flag int) (fileIO, error) {
 	return f, nil
 }
 
+// edgeEvent waits for the edges reported by the value file of the pin.
+// A wait is woken up by Wake, e.g. when the pin is halted.
+type edgeEvent struct {
+	epollFd int
+	wake    [2]int // pipe waking up Wait
+}
+
+// errWoken is returned by edgeEvent.Wait woken up by Wake
+var errWoken = errors.New("woken up")
+
+func newEdgeEvent(fd uintptr) (*edgeEvent, error) {
+	epollFd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	e := &edgeEvent{epollFd: epollFd, wake: [2]int{-1, -1}}
+	if err := syscall.Pipe2(e.wake[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	// The edges are edge triggered events, see fs.Event
+	const epollET = 1 << 31
+	events := []syscall.EpollEvent{
+		{Events: syscall.EPOLLPRI | epollET, Fd: int32(fd)},
+		{Events: syscall.EPOLLIN, Fd: int32(e.wake[0])},
+	}
+	for i := range events {
+		if err := syscall.EpollCtl(epollFd, syscall.EPOLL_CTL_ADD, int(events[i].Fd), &events[i]); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Wait returns 1 if an edge occurred, 0 on timeout and errWoken if it is
+// woken up by Wake.
+func (e *edgeEvent) Wait(timeoutms int) (int, error) {
+	var events [2]syscall.EpollEvent
+	n, err := syscall.EpollWait(e.epollFd, events[:], timeoutms)
+	if err != nil {
+		return 0, err
+	}
+	edges, woken := 0, false
+	for _, event := range events[:n] {
+		if int(event.Fd) == e.wake[0] {
+			woken = true
+		} else {
+			edges = 1
+		}
+	}
+	if woken {
+		var buf [16]byte
+		for {
+			if n, err := syscall.Read(e.wake[0], buf[:]); n <= 0 || err != nil {
+				break
+			}
+		}
+		if edges == 0 {
+			return 0, errWoken
+		}
+	}
+	return edges, nil
+}
+
+// Wake wakes up Wait. The next Wait returns at once if nobody is waiting.
+func (e *edgeEvent) Wake() error {
+	_, err := syscall.Write(e.wake[1], []byte{0})
+	if err == syscall.EAGAIN {
+		// The pipe is full of the wakeups not handled yet
+		return nil
+	}
+	return err
+}
+
+func (e *edgeEvent) Close() error {
+	for _, fd := range e.wake {
+		if fd >= 0 {
+			syscall.Close(fd)
+		}
+	}
+	return syscall.Close(e.epollFd)
+}
+
 type ioctlCloser interface {
 	io.Closer
 	fs.Ioctler
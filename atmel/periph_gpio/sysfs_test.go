@@ -0,0 +1,144 @@
+package periph_gpio
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// fakeFile is a GPIO sysfs file in a temporary directory
+type fakeFile struct {
+	*os.File
+	fd uintptr
+}
+
+func (f *fakeFile) Fd() uintptr {
+	return f.fd
+}
+
+func (f *fakeFile) Ioctl(op uint, data uintptr) error {
+	return errors.New("not a device")
+}
+
+// fakeSysfs makes fileIOOpen open the files in a temporary directory.
+// Epoll doesn't accept regular files, so the value files poll a pipe
+// that never reports an edge.
+func fakeSysfs(t *testing.T) (dir string, restore func()) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileIOOpen = func(path string, flag int) (fileIO, error) {
+		f, err := os.OpenFile(path, flag, 0)
+		if err != nil {
+			return nil, err
+		}
+		fd := f.Fd()
+		if filepath.Base(path) == "value" {
+			fd = r.Fd()
+		}
+		return &fakeFile{f, fd}, nil
+	}
+	return dir, func() {
+		fileIOOpen = fileIOOpenDefault
+		r.Close()
+		w.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// makePin creates the files of the exported pin
+func makePin(t *testing.T, dir string, name string) {
+	pinDir := filepath.Join(dir, name)
+	if err := os.Mkdir(pinDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"direction", "edge", "value"} {
+		if err := ioutil.WriteFile(filepath.Join(pinDir, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestPinIn(t *testing.T) {
+	dir, restore := fakeSysfs(t)
+	defer restore()
+	makePin(t, dir, "PA1")
+	p := &Pin{number: 1, name: "PA1", root: filepath.Join(dir, "PA1") + "/"}
+
+	if err := p.In(gpio.PullUp, gpio.NoEdge); err == nil {
+		t.Error("Pull-up is accepted")
+	}
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if direction := readFile(t, p.root+"direction"); direction != "in" {
+		t.Errorf("Direction '%s', want 'in'", direction)
+	}
+	if edge := readFile(t, p.root+"edge"); edge != "both" {
+		t.Errorf("Edge '%s', want 'both'", edge)
+	}
+
+	if err := ioutil.WriteFile(p.root+"value", []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if level := p.Read(); level != gpio.High {
+		t.Errorf("Level %v, want High", level)
+	}
+}
+
+func TestHaltWakesWaitForEdge(t *testing.T) {
+	dir, restore := fakeSysfs(t)
+	defer restore()
+	makePin(t, dir, "PA1")
+	p := &Pin{number: 1, name: "PA1", root: filepath.Join(dir, "PA1") + "/"}
+
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	defer p.close()
+
+	edges := make(chan bool)
+	go func() {
+		edges <- p.WaitForEdge(-1)
+	}()
+	select {
+	case <-edges:
+		t.Fatal("WaitForEdge returned without an edge")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case edge := <-edges:
+		if edge {
+			t.Error("Halt is reported as an edge")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForEdge is not woken up by Halt")
+	}
+	if edge := readFile(t, p.root+"edge"); !strings.HasPrefix(edge, "none") {
+		t.Errorf("Edge '%s' after Halt, want 'none'", edge)
+	}
+}
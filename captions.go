@@ -0,0 +1,338 @@
+package hasp
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rmcsoft/chanim"
+	"github.com/rmcsoft/hasp/pixmap"
+)
+
+// CaptionPosition is the edge of the display the captions are shown at
+type CaptionPosition string
+
+const (
+	// CaptionTop shows the captions at the top of the display
+	CaptionTop CaptionPosition = "top"
+	// CaptionBottom shows the captions at the bottom of the display
+	CaptionBottom CaptionPosition = "bottom"
+)
+
+// minReadingDuration is the shortest time the reply without speech is shown
+const minReadingDuration = 2 * time.Second
+
+// CaptionParams are the parameters of the captions
+type CaptionParams struct {
+	Position CaptionPosition
+	// Margin is the distance from the display edges in pixels
+	Margin int
+	// Scale is the size of a font pixel in pixels
+	Scale int
+	// MaxLines is the number of the lines shown at once. The longer replies
+	// are split into pages shown one after another while they are spoken.
+	MaxLines int
+	// HeardDuration is how long the words of the user are shown
+	HeardDuration time.Duration
+	// ReadingSpeed is the time per character the reply without speech is
+	// shown for
+	ReadingSpeed time.Duration
+
+	TextColor       color.Color
+	HeardColor      color.Color
+	BackgroundColor color.Color
+
+	PixFormat chanim.PixelFormat
+	// Rotate rotates the captions clockwise as the frames packed by repack
+	Rotate bool
+}
+
+// DefaultCaptionParams are the caption parameters for the character display
+var DefaultCaptionParams = CaptionParams{
+	Position:        CaptionBottom,
+	Margin:          16,
+	Scale:           3,
+	MaxLines:        3,
+	HeardDuration:   3 * time.Second,
+	ReadingSpeed:    60 * time.Millisecond,
+	TextColor:       color.White,
+	HeardColor:      color.RGBA{R: 0xff, G: 0xd7, B: 0x40, A: 0xff},
+	BackgroundColor: color.Black,
+	PixFormat:       chanim.RGB16,
+	Rotate:          true,
+}
+
+// CaptionState is implemented by the states showing the captions of the
+// replied speech
+type CaptionState interface {
+	// GetCaptions returns what the user said and what the character says
+	GetCaptions() (heard string, said string)
+}
+
+// captionPage is the lines shown until the end time since the captions
+// are shown
+type captionPage struct {
+	lines []string
+	end   time.Duration
+}
+
+// Captions are the subtitles of the speech of the character and the user
+// drawn over the animation. Captions implements chanim.DrawOperation and is
+// drawn with the overlays, see Overlays.Attach.
+type Captions struct {
+	params CaptionParams
+
+	mutex    sync.Mutex
+	heard    string
+	said     string
+	duration time.Duration
+	shown    time.Time
+
+	// Layout of the shown text, made for the display on the first draw
+	laidOut    bool
+	heardLines []string
+	pages      []captionPage
+
+	// The pixmap of the shown lines
+	rendered     bool
+	renderedPage int
+	renderedHear bool
+	pixmap       *chanim.Pixmap
+	top          image.Point
+}
+
+// NewCaptions creates new Captions
+func NewCaptions(params CaptionParams) *Captions {
+	if params.Scale <= 0 {
+		params.Scale = 1
+	}
+	if params.MaxLines <= 0 {
+		params.MaxLines = 1
+	}
+	return &Captions{
+		params: params,
+	}
+}
+
+// Show shows the words of the user and the reply spoken for the duration.
+// The reply without speech is shown for the time needed to read it.
+func (c *Captions) Show(heard string, said string, duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.heard = normalizeCaption(heard)
+	c.said = normalizeCaption(said)
+	if duration <= 0 {
+		duration = time.Duration(len(c.said)) * c.params.ReadingSpeed
+		if duration < minReadingDuration {
+			duration = minReadingDuration
+		}
+	}
+	c.duration = duration
+	c.shown = time.Now()
+	c.laidOut = false
+	c.rendered = false
+}
+
+// Hide hides the captions
+func (c *Captions) Hide() {
+	c.Show("", "", 0)
+}
+
+// Draw implements chanim.DrawOperation, it draws the captions for the time
+// since they are shown
+func (c *Captions) Draw(paintEngine chanim.PaintEngine) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.heard) == 0 && len(c.said) == 0 {
+		return nil
+	}
+
+	displayWidth, displayHeight := paintEngine.GetWidth(), paintEngine.GetHeight()
+	if c.params.Rotate {
+		displayWidth, displayHeight = displayHeight, displayWidth
+	}
+	if !c.laidOut {
+		c.layOut(displayWidth)
+	}
+
+	elapsed := time.Since(c.shown)
+	hear := len(c.heardLines) > 0 && elapsed < c.params.HeardDuration
+	page := len(c.pages)
+	for i := range c.pages {
+		if elapsed < c.pages[i].end {
+			page = i
+			break
+		}
+	}
+	if !hear && page == len(c.pages) {
+		return nil
+	}
+
+	if !c.rendered || c.renderedHear != hear || c.renderedPage != page {
+		var lines []string
+		if page < len(c.pages) {
+			lines = c.pages[page].lines
+		}
+		c.render(hear, lines, displayWidth, displayHeight)
+		c.rendered, c.renderedHear, c.renderedPage = true, hear, page
+	}
+	return paintEngine.DrawPixmap(c.top, c.pixmap)
+}
+
+// columns returns the number of characters per line
+func (c *Captions) columns(displayWidth int) int {
+	columns := (displayWidth - 2*c.params.Margin - 2*c.padding()) / (glyphCellWidth * c.params.Scale)
+	if columns < 1 {
+		return 1
+	}
+	return columns
+}
+
+func (c *Captions) padding() int {
+	return glyphCellWidth * c.params.Scale
+}
+
+// layOut wraps the text and splits the reply into the pages. A page is shown
+// for the part of the duration proportional to its length, so the captions
+// follow the speech.
+func (c *Captions) layOut(displayWidth int) {
+	columns := c.columns(displayWidth)
+
+	c.heardLines = wrapCaption(c.heard, columns)
+	if len(c.heardLines) > c.params.MaxLines {
+		c.heardLines = c.heardLines[len(c.heardLines)-c.params.MaxLines:]
+	}
+
+	c.pages = nil
+	lines := wrapCaption(c.said, columns)
+	total := 0
+	for _, line := range lines {
+		total += len(line)
+	}
+	spoken := 0
+	for len(lines) > 0 {
+		n := c.params.MaxLines
+		if n > len(lines) {
+			n = len(lines)
+		}
+		for _, line := range lines[:n] {
+			spoken += len(line)
+		}
+		c.pages = append(c.pages, captionPage{
+			lines: lines[:n],
+			end:   c.duration * time.Duration(spoken) / time.Duration(total),
+		})
+		lines = lines[n:]
+	}
+	c.laidOut = true
+}
+
+// render draws the lines onto the pixmap in the box at the caption position
+func (c *Captions) render(hear bool, lines []string, displayWidth int, displayHeight int) {
+	var heardLines []string
+	if hear {
+		heardLines = c.heardLines
+	}
+
+	scale := c.params.Scale
+	padding := c.padding()
+	lineHeight := glyphCellHeight * scale
+	box := image.Rect(0, 0,
+		displayWidth-2*c.params.Margin,
+		(len(heardLines)+len(lines))*lineHeight+2*padding-(glyphCellHeight-glyphHeight)*scale)
+
+	img := image.NewRGBA(box)
+	fillRect(img, box, c.params.BackgroundColor)
+	y := padding
+	for _, line := range heardLines {
+		drawCaptionLine(img, line, image.Pt(padding, y), scale, c.params.HeardColor)
+		y += lineHeight
+	}
+	for _, line := range lines {
+		drawCaptionLine(img, line, image.Pt(padding, y), scale, c.params.TextColor)
+		y += lineHeight
+	}
+
+	boxTop := image.Pt(c.params.Margin, c.params.Margin)
+	if c.params.Position != CaptionTop {
+		boxTop.Y = displayHeight - c.params.Margin - box.Dy()
+	}
+
+	c.pixmap = pixmap.FromImage(img, c.params.PixFormat)
+	c.top = boxTop
+	if c.params.Rotate {
+		// The point (x, y) is rotated clockwise to (height - 1 - y, x)
+		c.pixmap = pixmap.Rotate(c.pixmap)
+		c.top = image.Pt(displayHeight-boxTop.Y-box.Dy(), boxTop.X)
+	}
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+// drawCaptionLine draws the line with the bitmap font scaled
+func drawCaptionLine(img *image.RGBA, line string, top image.Point, scale int, c color.Color) {
+	for i, r := range []rune(line) {
+		g := glyph(r)
+		for col := 0; col < glyphWidth; col++ {
+			for row := 0; row < glyphHeight; row++ {
+				if g[col]&(1<<uint(row)) == 0 {
+					continue
+				}
+				x := top.X + (i*glyphCellWidth+col)*scale
+				y := top.Y + row*scale
+				fillRect(img, image.Rect(x, y, x+scale, y+scale), c)
+			}
+		}
+	}
+}
+
+var captionReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'",
+	"“", "\"", "”", "\"",
+	"–", "-", "—", "-",
+	"…", "...",
+)
+
+// normalizeCaption replaces the typographic characters missing in the font
+// and the white spaces with the spaces
+func normalizeCaption(text string) string {
+	text = captionReplacer.Replace(text)
+	return strings.Join(strings.FieldsFunc(text, unicode.IsSpace), " ")
+}
+
+// wrapCaption splits the text into the lines of the columns at most,
+// breaking the words longer than a line
+func wrapCaption(text string, columns int) []string {
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(text) {
+		w := []rune(word)
+		if len(line) > 0 && len(line)+1+len(w) <= columns {
+			line = append(append(line, ' '), w...)
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+		for len(w) > columns {
+			lines = append(lines, string(w[:columns]))
+			w = w[columns:]
+		}
+		line = w
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
@@ -2,6 +2,8 @@ package hasp
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -22,6 +24,9 @@ type CharacterCtx = map[string]interface{}
 
 const (
 	CtxUserId = "UserId"
+	// CtxSession is the *haspaws.Session of the conversation,
+	// see ConversationSession
+	CtxSession = "Session"
 )
 
 // Character is animated character
@@ -32,6 +37,37 @@ type Character struct {
 	ctx         CharacterCtx
 	fsm         *fsm.FSM
 
+	// The manifest sets the follow-up animations of the play-once animations.
+	// The animation changes requested until the follow-up animation is
+	// switched to are queued, the last one is played after it.
+	manifest        AnimationManifest
+	animationMutex  sync.Mutex
+	following       bool   // the follow-up animation is waited for
+	queuedAnimation string // played once the follow-up animation is switched to
+
+	// Overlays shown over the animations, see OverlayState
+	overlays *Overlays
+
+	// Captions of the speech, see CaptionState
+	captions *Captions
+
+	// Languages of the hot word keywords, see SetKeywordLanguages
+	keywordLanguages []string
+
+	// Manager of the conversation sessions, see SetSessionManager
+	sessions *SessionManager
+
+	// Guarded transitions by event name and the event names
+	// of their FSM events, see Transition
+	guarded       map[string][]guardedTransition
+	guardedEvents map[string]string
+
+	// Actions run on entering and leaving the states, see OnEnter
+	enterActions map[string][]Action
+	leaveActions map[string][]Action
+	// waitTimes are the times WaitTimeout is fired after, see CharacterParams
+	waitTimes map[string]time.Duration
+
 	eventSourceMultiplexer *events.EventSourceMultiplexer
 
 	// Event sources that are added when entering the state
@@ -40,6 +76,33 @@ type Character struct {
 
 	// Event sources that are replaced with each StateChangedEvent
 	stateChangedEventSources []events.IDEventSource
+
+	// Events injected from other goroutines (e.g. the control API)
+	injectedEventSource *events.ChanEventSource
+
+	// Snapshot of the state for readers outside of the FSM goroutine
+	snapshotMutex sync.RWMutex
+	currentState  string
+	ctxSnapshot   CharacterCtx
+
+	observersMutex sync.Mutex
+	observerSeq    int
+	observers      map[int]chan events.Transition
+}
+
+// CharacterParams are the parameters of Character
+type CharacterParams struct {
+	InitStateName string
+	States        States
+	EventDescs    EventDescs
+	// Transitions are optional, they are tried before EventDescs
+	Transitions Transitions
+	// WaitTimes are optional. WaitTimeout is fired if the state is not left
+	// for its wait time, the Transitions of WaitTimeout choose what follows.
+	WaitTimes    map[string]time.Duration
+	EventSources events.EventSources
+	Animator     *chanim.Animator
+	SoundPlayer  *sound.SoundPlayer
 }
 
 // NewCharacter creates new a Character
@@ -50,15 +113,38 @@ func NewCharacter(
 	eventSources events.EventSources,
 	animator *chanim.Animator,
 	soundPlayer *sound.SoundPlayer) (*Character, error) {
+	return NewCharacterWithParams(CharacterParams{
+		InitStateName: initStateName,
+		States:        states,
+		EventDescs:    eventDescs,
+		EventSources:  eventSources,
+		Animator:      animator,
+		SoundPlayer:   soundPlayer,
+	})
+}
+
+// NewCharacterWithParams creates new a Character
+func NewCharacterWithParams(params CharacterParams) (*Character, error) {
+	initStateName := params.InitStateName
+	states := params.States
 
 	c := &Character{
 		states:                 states,
-		animator:               animator,
+		animator:               params.Animator,
 		eventSourceMultiplexer: events.NewEventSourceMultiplexer(),
-		soundPlayer:            soundPlayer,
+		soundPlayer:            params.SoundPlayer,
 		ctx:                    make(CharacterCtx),
+		enterActions:           make(map[string][]Action),
+		leaveActions:           make(map[string][]Action),
+		waitTimes:              params.WaitTimes,
+		injectedEventSource:    events.NewChanEventSource("InjectedEventSource", 16),
+		currentState:           initStateName,
+		ctxSnapshot:            make(CharacterCtx),
+		observers:              make(map[int]chan events.Transition),
 	}
 
+	eventDescs := c.addTransitions(params.EventDescs, params.Transitions)
+
 	// In any of the states, the StateChanged event should lead to updating
 	// the animation and sound without going to another state.
 	for stateName := range states {
@@ -85,9 +171,10 @@ func NewCharacter(
 		},
 	)
 
-	for _, eventSource := range eventSources {
+	for _, eventSource := range params.EventSources {
 		c.eventSourceMultiplexer.AddEventSource(eventSource)
 	}
+	c.eventSourceMultiplexer.AddEventSource(c.injectedEventSource)
 
 	return c, nil
 }
@@ -101,28 +188,63 @@ func (c *Character) SetDebug(val bool) {
 	c.ctx["Debug"] = val
 }
 
+// SetAnimationManifest sets the manifest the animator was created with
+func (c *Character) SetAnimationManifest(manifest AnimationManifest) {
+	c.manifest = manifest
+}
+
+// SetOverlays sets the overlays the animator was created with
+func (c *Character) SetOverlays(overlays *Overlays) {
+	c.overlays = overlays
+}
+
+// SetCaptions sets the captions drawn over the animations
+func (c *Character) SetCaptions(captions *Captions) {
+	c.captions = captions
+}
+
 func isNoTransitionError(err error) bool {
 	_, ok := err.(fsm.NoTransitionError)
 	return ok
 }
 
+// isInvalidEventError checks whether the event is known but has no transition
+// from the current state, e.g. PassedBy while the character is talking
+func isInvalidEventError(err error) bool {
+	_, ok := err.(fsm.InvalidEventError)
+	return ok
+}
+
 // Run starting point for the character
 func (c *Character) Run() error {
 	if err := c.start(); err != nil {
 		return err
 	}
 
-	for event := c.eventSourceMultiplexer.NextEvent(); event != nil; {
+	for event := c.eventSourceMultiplexer.NextEvent(); event != nil; event = c.eventSourceMultiplexer.NextEvent() {
+		c.handleEvent(event)
+	}
 
-		err := c.fsm.Event(event.Name, event.Args...)
-		if err != nil && !isNoTransitionError(err) {
-			log.Errorf("%v\n", err)
-		}
+	return nil
+}
 
-		event = c.eventSourceMultiplexer.NextEvent()
+// handleEvent fires the event in the FSM
+func (c *Character) handleEvent(event *events.Event) {
+	if c.sessions != nil && !c.sessions.acceptEvent(event) {
+		log.Debugf("%s of an ended session is dropped", event.Name)
+		return
 	}
 
-	return nil
+	src := c.fsm.Current()
+	err := c.fsm.Event(c.fsmEvent(event), event.Args...)
+	if isInvalidEventError(err) {
+		log.Debugf("%v\n", err)
+	} else if err != nil && !isNoTransitionError(err) {
+		log.Errorf("%v\n", err)
+	} else if err == nil {
+		transitionCounter.With(event.Name).Inc()
+	}
+	c.publishState(event.Name, src, err == nil)
 }
 
 func (c *Character) start() error {
@@ -142,11 +264,110 @@ func (c *Character) start() error {
 		return startEvent.Err
 	}
 
+	c.publishState("", "", true)
 	return nil
 }
 
+// CurrentState returns the name of the current state.
+// It is safe to call from any goroutine.
+func (c *Character) CurrentState() string {
+	c.snapshotMutex.RLock()
+	defer c.snapshotMutex.RUnlock()
+	return c.currentState
+}
+
+// Context returns a copy of the character context as of the last processed event.
+// It is safe to call from any goroutine.
+func (c *Character) Context() CharacterCtx {
+	c.snapshotMutex.RLock()
+	defer c.snapshotMutex.RUnlock()
+
+	ctx := make(CharacterCtx, len(c.ctxSnapshot))
+	for k, v := range c.ctxSnapshot {
+		ctx[k] = v
+	}
+	return ctx
+}
+
+// InjectEvent queues an event as if it came from one of the event sources.
+// It is safe to call from any goroutine.
+func (c *Character) InjectEvent(event *events.Event) error {
+	return c.injectedEventSource.Push(event)
+}
+
+// ChangeAnimation switches to the animation until the next state update
+func (c *Character) ChangeAnimation(name string) error {
+	return c.playAnimation(name, false)
+}
+
+// Announce fires AnnouncementEvent to play the audio data in a state,
+// e.g. in the announcing state. The announcement is refused if the current
+// state has no transition for it, so it does not play over a conversation.
+func (c *Character) Announce(audioData *sound.AudioData) error {
+	if !c.fsm.Can(AnnouncementEventName) {
+		return fmt.Errorf("Can't announce in state '%s'", c.CurrentState())
+	}
+	return c.InjectEvent(NewAnnouncementEvent(audioData))
+}
+
+// SubscribeTransitions returns a channel receiving FSM transitions and
+// a function to cancel the subscription.
+// Transitions are dropped if the subscriber does not keep up.
+func (c *Character) SubscribeTransitions() (<-chan events.Transition, func()) {
+	c.observersMutex.Lock()
+	defer c.observersMutex.Unlock()
+
+	id := c.observerSeq
+	c.observerSeq++
+	ch := make(chan events.Transition, 16)
+	c.observers[id] = ch
+
+	cancel := func() {
+		c.observersMutex.Lock()
+		defer c.observersMutex.Unlock()
+		if ch, ok := c.observers[id]; ok {
+			delete(c.observers, id)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+func (c *Character) publishState(eventName string, src string, transited bool) {
+	dst := c.fsm.Current()
+
+	c.snapshotMutex.Lock()
+	c.currentState = dst
+	c.ctxSnapshot = make(CharacterCtx, len(c.ctx))
+	for k, v := range c.ctx {
+		c.ctxSnapshot[k] = v
+	}
+	c.snapshotMutex.Unlock()
+
+	if !transited {
+		return
+	}
+
+	transition := events.Transition{
+		Event: eventName,
+		Src:   src,
+		Dst:   dst,
+		Time:  time.Now(),
+	}
+
+	c.observersMutex.Lock()
+	defer c.observersMutex.Unlock()
+	for _, ch := range c.observers {
+		select {
+		case ch <- transition:
+		default:
+		}
+	}
+}
+
 func (c *Character) enterStateCallbacks(e *fsm.Event) {
 	log.Infof("Entry to '%s' state", e.Dst)
+	setCurrentStateMetric(c.states, e.Dst)
 
 	nextState, ok := c.states[e.Dst]
 	if !ok {
@@ -154,10 +375,21 @@ func (c *Character) enterStateCallbacks(e *fsm.Event) {
 		return
 	}
 
-	eventSources, err := nextState.Enter(c.ctx, events.Event{Name: e.Event, Args: e.Args})
+	if c.sessions != nil {
+		c.sessions.enterState(c.ctx, e.Dst)
+	}
+
+	event := events.Event{Name: c.eventName(e.Event), Args: e.Args}
+	c.selectLanguage(&event)
+	runActions(c.enterActions[e.Dst], c.ctx, event)
+
+	eventSources, err := nextState.Enter(c.ctx, event)
 	if err != nil {
 		e.Cancel(err)
 	}
+	if waitTime, ok := c.waitTimes[e.Dst]; ok {
+		eventSources = append(eventSources, newWaitTimer(waitTime))
+	}
 
 	if eventSources != nil {
 		stateEventSources := make([]events.IDEventSource, 0, len(eventSources))
@@ -173,10 +405,12 @@ func (c *Character) leaveStateCallback(e *fsm.Event) {
 	log.Infof("Leave from '%s' state", e.Src)
 
 	if predState, ok := c.states[e.Src]; ok {
-		if !predState.Leave(c.ctx, events.Event{Name: e.Event, Args: e.Args}) {
+		event := events.Event{Name: c.eventName(e.Event), Args: e.Args}
+		if !predState.Leave(c.ctx, event) {
 			e.Cancel()
 			return
 		}
+		runActions(c.leaveActions[e.Src], c.ctx, event)
 
 		for _, idEventSource := range c.stateEventSources {
 			c.eventSourceMultiplexer.RemoveEventSource(idEventSource)
@@ -194,20 +428,28 @@ func (c *Character) updateCharacter(e *fsm.Event, start bool) {
 
 	stateName := c.fsm.Current()
 	if state, ok := c.states[stateName]; ok {
-		var err error
-
-		animation := state.GetAnimation()
-		if start {
-			err = c.animator.Start(animation)
-		} else {
-			err = c.animator.ChangeAnimation(animation)
-		}
-		if err != nil {
+		if err := c.playAnimation(state.GetAnimation(), start); err != nil {
 			e.Cancel(err)
 			return
 		}
 
+		if c.overlays != nil {
+			var overlays []string
+			if overlayState, ok := state.(OverlayState); ok {
+				overlays = overlayState.GetOverlays()
+			}
+			c.overlays.setStateOverlays(overlays)
+		}
+
 		sound := state.GetSound()
+		if captionState, ok := state.(CaptionState); ok && c.captions != nil {
+			var duration time.Duration
+			if sound != nil {
+				duration = time.Duration(sound.Duration() * float64(time.Second))
+			}
+			heard, said := captionState.GetCaptions()
+			c.captions.Show(heard, said, duration)
+		}
 		if sound != nil {
 			eventSources, err := c.soundPlayer.Play(sound)
 			if err != nil {
@@ -221,6 +463,75 @@ func (c *Character) updateCharacter(e *fsm.Event, start bool) {
 	}
 }
 
+// playAnimation starts or changes the animation. A play-once animation is
+// followed by its next animation; the changes requested until then are
+// queued, see followAnimation.
+func (c *Character) playAnimation(animation string, start bool) error {
+	c.animationMutex.Lock()
+	defer c.animationMutex.Unlock()
+
+	if c.following {
+		c.queuedAnimation = animation
+		return nil
+	}
+
+	var err error
+	if start {
+		err = c.animator.Start(animation)
+	} else {
+		err = c.animator.ChangeAnimation(animation)
+	}
+	if err != nil {
+		return err
+	}
+	c.followAnimation(animation)
+	return nil
+}
+
+// followAnimation switches to the next animation of the play-once animation
+// in the background, see playFollowing.
+//
+// animationMutex must be held.
+func (c *Character) followAnimation(animation string) {
+	next, ok := c.manifest.Next(animation)
+	if !ok {
+		return
+	}
+
+	c.following = true
+	go c.playFollowing(animation, next)
+}
+
+// playFollowing changes the animation to the follow-up one and then to the
+// queued one. The changes block until the transitions, they are made without
+// animationMutex so the changes requested meanwhile are queued.
+func (c *Character) playFollowing(from string, to string) {
+	for {
+		// Blocks until the last frame of a play-once animation
+		if err := c.animator.ChangeAnimation(to); err != nil {
+			log.Errorf("Failed to change animation '%s' to '%s': %v", from, to, err)
+		}
+
+		if next, ok := c.manifest.Next(to); ok {
+			from, to = to, next
+			continue
+		}
+
+		c.animationMutex.Lock()
+		queued := c.queuedAnimation
+		c.queuedAnimation = ""
+		if queued == "" {
+			c.following = false
+		}
+		c.animationMutex.Unlock()
+
+		if queued == "" {
+			return
+		}
+		from, to = to, queued
+	}
+}
+
 func (c *Character) addEventSource(eventSource events.EventSource) events.IDEventSource {
 	return c.eventSourceMultiplexer.AddEventSource(eventSource)
 }
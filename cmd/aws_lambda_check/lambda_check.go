@@ -16,9 +16,29 @@ import (
 	"github.com/lithammer/fuzzysearch/fuzzy"
 )
 
-func getCoworkersJson() ([]byte, error) {
+// The request attributes identifying the kiosk, see haspaws.KioskIdentity
+const (
+	siteAttribute     = "Site"
+	kioskIDAttribute  = "KioskId"
+	timeZoneAttribute = "TimeZone"
+	languageAttribute = "Language"
+)
+
+// getCoworkersJson gets the coworkers of the site cached by
+// aws_lambda_refresh or all the cached coworkers if the site has no cache
+func getCoworkersJson(site string) ([]byte, error) {
+	if site != "" {
+		body, err := downloadCoworkersJson("CoworkersCache/" + site)
+		if err == nil && body != nil {
+			return body, nil
+		}
+		fmt.Println("No coworkers of site ", site, err)
+	}
+	return downloadCoworkersJson("CoworkersCache")
+}
+
+func downloadCoworkersJson(keyName string) ([]byte, error) {
 	bucketName := "rmc-haspbot"
-	keyName := "CoworkersCache"
 
 	region := "us-east-1"
 	downloader := s3manager.NewDownloader(session.New(&aws.Config{Region: &region}))
@@ -145,7 +165,7 @@ func processCoworker(event events.LexEvent) (*events.LexResponse, error) {
 	toFind := *nameFirst + " " + *nameLast
 	fmt.Println("searching for ", toFind)
 
-	body, err := getCoworkersJson()
+	body, err := getCoworkersJson(event.RequestAttributes[siteAttribute])
 	if err != nil {
 		fmt.Println("Failed to get data. ", err)
 		return nil, err
@@ -221,7 +241,7 @@ func processCompany(event events.LexEvent) (*events.LexResponse, error) {
 	fmt.Println("ConfirmationStatus: ", event.CurrentIntent.ConfirmationStatus)
 	fmt.Println("Searching for ", toFind)
 
-	body, err := getCoworkersJson()
+	body, err := getCoworkersJson(event.RequestAttributes[siteAttribute])
 	if err != nil {
 		fmt.Println("Failed to get data. ", err)
 		return nil, err
@@ -258,6 +278,9 @@ func processCompany(event events.LexEvent) (*events.LexResponse, error) {
 
 func HandleLambdaEvent(ctx context.Context, event events.LexEvent) (*events.LexResponse, error) {
 	fmt.Println(event)
+	fmt.Printf("Kiosk %s at site %s, time zone %s, language %s\n",
+		event.RequestAttributes[kioskIDAttribute], event.RequestAttributes[siteAttribute],
+		event.RequestAttributes[timeZoneAttribute], event.RequestAttributes[languageAttribute])
 
 	if event.CurrentIntent.Name == "Meeting" {
 		resp, _ := processCoworker(event)
@@ -16,9 +16,14 @@ import (
 	"time"
 )
 
-func updateCoworkersJson(value []byte) error {
+// updateCoworkersJson caches the coworkers of the site or of all the sites
+// if it is empty, see getCoworkersJson of aws_lambda_check
+func updateCoworkersJson(site string, value []byte) error {
 	bucketName := "rmc-haspbot"
 	keyName := "CoworkersCache"
+	if site != "" {
+		keyName += "/" + site
+	}
 
 	// Upload input parameters
 	upParams := &s3manager.UploadInput{
@@ -53,6 +58,8 @@ func HandleCloudWatchEvent(ctx context.Context, event events.CloudWatchEvent) er
 	}
 
 	fmt.Println("Setting headers")
+	// The detail is {"secret": "...", "site": "..."}, the rule of each site
+	// passes the secret of its space; the site is optional
 	data := make(map[string]string)
 	err = json.Unmarshal(event.Detail, &data)
 	if err != nil {
@@ -75,7 +82,7 @@ func HandleCloudWatchEvent(ctx context.Context, event events.CloudWatchEvent) er
 	}
 
 	fmt.Println("Write")
-	err = updateCoworkersJson(filterCoworkers(body))
+	err = updateCoworkersJson(data["site"], filterCoworkers(body))
 	if err != nil {
 		log.Fatal(err)
 	}
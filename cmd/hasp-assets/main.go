@@ -0,0 +1,264 @@
+// Command hasp-assets converts PNG sequences, animated GIFs and sprite sheets
+// into a frame series of the packed image directory, e.g.
+//
+//	hasp-assets -o images -n reading reading-png/
+//	hasp-assets -o images -n giggles --manifest giggles.gif
+//	hasp-assets -o images -n tells --sprite 8x4 --crop 0,40,600,960 tells-sheet.png
+//
+// Videos are converted by extracting their frames to PNG first,
+// e.g. with ffmpeg -i input.mp4 frames/%04d.png.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/rmcsoft/chanim"
+
+	"github.com/rmcsoft/hasp/pixmap"
+)
+
+type options struct {
+	OutputDir  string `short:"o" long:"output-dir" required:"true" description:"The packed image directory"`
+	SeriesName string `short:"n" long:"name"       description:"The frame series name, the name of the first input if empty"`
+
+	PixFormat  string `short:"f" long:"format"     default:"rgb16" choice:"rgb16" choice:"rgb32" description:"The pixel format of the paint engine"`
+	Sprite     string `long:"sprite"               description:"Sprite sheet layout <cols>x<rows>, the sprites are read row by row"`
+	Crop       string `long:"crop"                 description:"The part of the frames to use <x>,<y>,<width>,<height>"`
+	Offset     string `long:"offset"               description:"The position of the cropped frames on the canvas <x>,<y>"`
+	Canvas     string `long:"canvas"               description:"The output frame size <width>x<height>, the cropped size plus the offset if empty"`
+	Background string `long:"background"           default:"#000000" description:"The canvas color and the color under transparent pixels"`
+	NotRotate  bool   `short:"r" long:"not-rotate" description:"Disable image rotate"`
+	Dedup      bool   `short:"d" long:"dedup"      description:"Hard link identical frames to share their memory"`
+	Manifest   bool   `short:"m" long:"manifest"   description:"Write the GIF frame delays to the manifest of the packed image directory"`
+
+	Args struct {
+		Inputs []string `positional-arg-name:"INPUT" required:"1" description:"PNG files, directories of PNG files, a GIF or a sprite sheet"`
+	} `positional-args:"yes"`
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
+
+func parseCmd() options {
+	var opts options
+	var cmdParser = flags.NewParser(&opts, flags.HelpFlag|flags.PassDoubleDash)
+	var err error
+
+	if _, err = cmdParser.Parse(); err != nil {
+		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
+			fmt.Println(flagsErr)
+			os.Exit(0)
+		}
+		fail(err)
+	}
+
+	if opts.OutputDir, err = filepath.Abs(opts.OutputDir); err != nil {
+		fail(err)
+	}
+	if opts.SeriesName == "" {
+		input := filepath.Base(filepath.Clean(opts.Args.Inputs[0]))
+		opts.SeriesName = input[:len(input)-len(filepath.Ext(input))]
+	}
+
+	return opts
+}
+
+func makeLayout(opts options) layout {
+	var l layout
+	var err error
+	if l.crop, err = parseRect(opts.Crop); err != nil {
+		fail(err)
+	}
+	if l.offset, err = parsePoint(opts.Offset); err != nil {
+		fail(err)
+	}
+	if l.canvas, err = parseSize(opts.Canvas); err != nil {
+		fail(err)
+	}
+	if l.background, err = parseColor(opts.Background); err != nil {
+		fail(err)
+	}
+	return l
+}
+
+func pixFormat(opts options) chanim.PixelFormat {
+	if opts.PixFormat == "rgb32" {
+		return chanim.RGB32
+	}
+	return chanim.RGB16
+}
+
+// clearSeriesDir creates the series directory and removes its old frames
+func clearSeriesDir(seriesDir string) {
+	if err := os.MkdirAll(seriesDir, 0755); err != nil {
+		fail(err)
+	}
+	oldFrames, err := filepath.Glob(filepath.Join(seriesDir, "*.ppixmap"))
+	if err != nil {
+		fail(err)
+	}
+	for _, oldFrame := range oldFrames {
+		if err := os.Remove(oldFrame); err != nil {
+			fail(err)
+		}
+	}
+}
+
+// saveFrame saves the frame or links it to the identical frame saved before
+func saveFrame(packedPixmap *chanim.PackedPixmap, outputFile string, saved map[string]string) (linked bool) {
+	if saved != nil {
+		key := fmt.Sprintf("%d:%dx%d:%s", packedPixmap.PixFormat, packedPixmap.Width, packedPixmap.Height, packedPixmap.Data)
+		if first, ok := saved[key]; ok {
+			if err := os.Link(first, outputFile); err == nil {
+				return true
+			}
+		} else {
+			saved[key] = outputFile
+		}
+	}
+
+	if err := packedPixmap.Save(outputFile); err != nil {
+		fail(err)
+	}
+	return false
+}
+
+// manifestFileName is the name of the manifest in the packed image directory,
+// see hasp.AnimationManifestFileName
+const manifestFileName = "manifest.json"
+
+// frameHold is hasp.FrameHold in JSON
+type frameHold struct {
+	Frame    int    `json:"frame"`
+	Duration string `json:"duration"`
+}
+
+// updateManifest sets the frame timing of the series from the GIF delays:
+// the most common delay is the frame duration, the others are holds.
+// The other settings of the manifest are kept as they are.
+func updateManifest(opts options, delays []time.Duration) error {
+	counts := make(map[time.Duration]int)
+	var frameDuration time.Duration
+	for _, delay := range delays {
+		counts[delay]++
+		if counts[delay] > counts[frameDuration] {
+			frameDuration = delay
+		}
+	}
+	if frameDuration == 0 {
+		fmt.Printf("The GIF has no frame delays, the manifest is not changed\n")
+		return nil
+	}
+
+	manifestFile := filepath.Join(opts.OutputDir, manifestFileName)
+	manifest := make(map[string]json.RawMessage)
+	animations := make(map[string]map[string]json.RawMessage)
+	data, err := ioutil.ReadFile(manifestFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("Failed to parse animation manifest '%s': %v", manifestFile, err)
+		}
+		if raw, ok := manifest["animations"]; ok {
+			if err := json.Unmarshal(raw, &animations); err != nil {
+				return fmt.Errorf("Failed to parse animation manifest '%s': %v", manifestFile, err)
+			}
+		}
+	}
+
+	spec := animations[opts.SeriesName]
+	if spec == nil {
+		spec = make(map[string]json.RawMessage)
+	}
+	spec["frameDuration"], _ = json.Marshal(frameDuration.String())
+	var holds []frameHold
+	for i, delay := range delays {
+		if delay != frameDuration && delay != 0 {
+			holds = append(holds, frameHold{Frame: i, Duration: delay.String()})
+		}
+	}
+	delete(spec, "holds")
+	if len(holds) != 0 {
+		spec["holds"], _ = json.Marshal(holds)
+	}
+	animations[opts.SeriesName] = spec
+	manifest["animations"], _ = json.Marshal(animations)
+
+	if data, err = json.MarshalIndent(manifest, "", "  "); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(manifestFile, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s: frameDuration=%v, holds=%d\n", manifestFile, frameDuration, len(holds))
+	return nil
+}
+
+func main() {
+	opts := parseCmd()
+	layout := makeLayout(opts)
+	sprite, err := parseGrid(opts.Sprite)
+	if err != nil {
+		fail(err)
+	}
+
+	src, err := loadSource(opts.Args.Inputs, sprite)
+	if err != nil {
+		fail(err)
+	}
+
+	seriesDir := filepath.Join(opts.OutputDir, opts.SeriesName)
+	clearSeriesDir(seriesDir)
+
+	var saved map[string]string
+	if opts.Dedup {
+		saved = make(map[string]string)
+	}
+
+	var linkedCount int
+	var packedSize int64
+	for i, frame := range src.frames {
+		img, err := layout.apply(frame)
+		if err != nil {
+			fail(fmt.Errorf("Frame %d: %v", i, err))
+		}
+
+		p := pixmap.FromImage(img, pixFormat(opts))
+		if !opts.NotRotate {
+			p = pixmap.Rotate(p)
+		}
+
+		packedPixmap, err := chanim.PackPixmap(p)
+		if err != nil {
+			fail(err)
+		}
+
+		outputFile := filepath.Join(seriesDir, fmt.Sprintf("%04d.ppixmap", i))
+		if saveFrame(packedPixmap, outputFile, saved) {
+			linkedCount++
+		} else {
+			packedSize += int64(len(packedPixmap.Data))
+		}
+	}
+
+	if opts.Manifest && src.delays != nil {
+		if err := updateManifest(opts, src.delays); err != nil {
+			fail(err)
+		}
+	}
+
+	fmt.Printf("---------------------------\n")
+	fmt.Printf("Series=%s\n", seriesDir)
+	fmt.Printf("Frames=%v, linked=%v\n", len(src.frames), linkedCount)
+	fmt.Printf("packedSize=%vM\n", float32(packedSize)/float32(1024*1024))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+)
+
+// grid is the sprite sheet layout, "<cols>x<rows>"
+type grid struct {
+	cols int
+	rows int
+}
+
+func (g grid) empty() bool {
+	return g.cols == 0 && g.rows == 0
+}
+
+func (g grid) String() string {
+	return fmt.Sprintf("%dx%d", g.cols, g.rows)
+}
+
+func parseGrid(s string) (grid, error) {
+	if s == "" {
+		return grid{}, nil
+	}
+	values, err := parseInts(s, "x", 2)
+	if err != nil || values[0] <= 0 || values[1] <= 0 {
+		return grid{}, fmt.Errorf("Invalid grid '%s', expected <cols>x<rows>", s)
+	}
+	return grid{values[0], values[1]}, nil
+}
+
+// parseRect parses "<x>,<y>,<width>,<height>", the empty rectangle if s is empty
+func parseRect(s string) (image.Rectangle, error) {
+	if s == "" {
+		return image.Rectangle{}, nil
+	}
+	values, err := parseInts(s, ",", 4)
+	if err != nil || values[2] <= 0 || values[3] <= 0 {
+		return image.Rectangle{}, fmt.Errorf("Invalid rectangle '%s', expected <x>,<y>,<width>,<height>", s)
+	}
+	return image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3]), nil
+}
+
+// parsePoint parses "<x>,<y>"
+func parsePoint(s string) (image.Point, error) {
+	if s == "" {
+		return image.Point{}, nil
+	}
+	values, err := parseInts(s, ",", 2)
+	if err != nil {
+		return image.Point{}, fmt.Errorf("Invalid point '%s', expected <x>,<y>", s)
+	}
+	return image.Pt(values[0], values[1]), nil
+}
+
+// parseSize parses "<width>x<height>", zero size if s is empty
+func parseSize(s string) (image.Point, error) {
+	if s == "" {
+		return image.Point{}, nil
+	}
+	values, err := parseInts(s, "x", 2)
+	if err != nil || values[0] <= 0 || values[1] <= 0 {
+		return image.Point{}, fmt.Errorf("Invalid size '%s', expected <width>x<height>", s)
+	}
+	return image.Pt(values[0], values[1]), nil
+}
+
+// parseColor parses "#RRGGBB"
+func parseColor(s string) (color.Color, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return nil, fmt.Errorf("Invalid color '%s', expected #RRGGBB", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+func parseInts(s string, sep string, count int) ([]int, error) {
+	parts := strings.Split(s, sep)
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d values", count)
+	}
+	values := make([]int, count)
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// subImage copies the part of the image
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+// layout is how the frames are placed on the display
+type layout struct {
+	// crop is the part of the frame used, the whole frame if empty
+	crop image.Rectangle
+	// offset is the position of the cropped frame on the canvas
+	offset image.Point
+	// canvas is the size of the output frames, the cropped frame size plus
+	// the offset if zero
+	canvas     image.Point
+	background color.Color
+}
+
+// apply crops the frame, places it onto the canvas and drops the alpha channel
+func (l layout) apply(frame image.Image) (image.Image, error) {
+	crop := frame.Bounds()
+	if !l.crop.Empty() {
+		crop = l.crop.Add(frame.Bounds().Min)
+		if !crop.In(frame.Bounds()) {
+			return nil, fmt.Errorf("Crop %v is out of the frame %v", l.crop, frame.Bounds())
+		}
+	}
+
+	size := l.canvas
+	if size == (image.Point{}) {
+		size = crop.Size().Add(l.offset)
+	}
+
+	canvas := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(l.background), image.Point{}, draw.Src)
+	dst := image.Rectangle{Min: l.offset, Max: l.offset.Add(crop.Size())}
+	draw.Draw(canvas, dst, frame, crop.Min, draw.Over)
+	return canvas, nil
+}
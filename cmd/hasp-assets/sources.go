@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// source is the frames of the series in the play order
+type source struct {
+	frames []image.Image
+	// delays are the frame durations of the animated GIF, nil for the others
+	delays []time.Duration
+}
+
+// loadSource loads the inputs: PNG files, directories of PNG files, an
+// animated GIF or a sprite sheet if sprite is set
+func loadSource(inputs []string, sprite grid) (*source, error) {
+	if len(inputs) == 1 && strings.EqualFold(filepath.Ext(inputs[0]), ".gif") {
+		return loadGIF(inputs[0])
+	}
+
+	files, err := pngFiles(inputs)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No PNG file in %v", inputs)
+	}
+
+	src := &source{}
+	for _, fileName := range files {
+		img, err := loadPNG(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		if sprite.empty() {
+			src.frames = append(src.frames, img)
+			continue
+		}
+		sprites, err := sliceSpriteSheet(img, sprite)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+		src.frames = append(src.frames, sprites...)
+	}
+	return src, nil
+}
+
+// pngFiles expands the directories to their sorted PNG files
+func pngFiles(inputs []string) ([]string, error) {
+	var files []string
+	for _, input := range inputs {
+		fileInfo, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !fileInfo.IsDir() {
+			files = append(files, input)
+			continue
+		}
+
+		dirFiles, err := ioutil.ReadDir(input)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, dirFile := range dirFiles {
+			if !dirFile.IsDir() && strings.EqualFold(filepath.Ext(dirFile.Name()), ".png") {
+				names = append(names, filepath.Join(input, dirFile.Name()))
+			}
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	return files, nil
+}
+
+func loadPNG(fileName string) (image.Image, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return img, nil
+}
+
+// loadGIF loads the frames of the animated GIF as they are shown, with the
+// disposal of the previous frames applied
+func loadGIF(fileName string) (*source, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	src := &source{}
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		if g.Disposal != nil && g.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		shown := image.NewRGBA(bounds)
+		copy(shown.Pix, canvas.Pix)
+		src.frames = append(src.frames, shown)
+		src.delays = append(src.delays, time.Duration(g.Delay[i])*10*time.Millisecond)
+
+		if g.Disposal == nil {
+			continue
+		}
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return src, nil
+}
+
+// sliceSpriteSheet cuts the sheet into the grid cells, row by row
+func sliceSpriteSheet(sheet image.Image, sprite grid) ([]image.Image, error) {
+	bounds := sheet.Bounds()
+	if bounds.Dx()%sprite.cols != 0 || bounds.Dy()%sprite.rows != 0 {
+		return nil, fmt.Errorf("Sheet %dx%d can't be divided into %v sprites", bounds.Dx(), bounds.Dy(), sprite)
+	}
+	width := bounds.Dx() / sprite.cols
+	height := bounds.Dy() / sprite.rows
+
+	sprites := make([]image.Image, 0, sprite.cols*sprite.rows)
+	for row := 0; row < sprite.rows; row++ {
+		for col := 0; col < sprite.cols; col++ {
+			min := bounds.Min.Add(image.Pt(col*width, row*height))
+			sprites = append(sprites, subImage(sheet, image.Rectangle{min, min.Add(image.Pt(width, height))}))
+		}
+	}
+	return sprites, nil
+}
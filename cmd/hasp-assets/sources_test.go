@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	red   = color.RGBA{R: 0xff, A: 0xff}
+	green = color.RGBA{G: 0xff, A: 0xff}
+	blue  = color.RGBA{B: 0xff, A: 0xff}
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "hasp-assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// uniform returns the image of the color
+func uniform(width int, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func writePNG(t *testing.T, fileName string, img image.Image) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkColors checks the color of the top left pixel of the frames
+func checkColors(t *testing.T, frames []image.Image, colors ...color.Color) {
+	t.Helper()
+	if len(frames) != len(colors) {
+		t.Fatalf("%d frames, want %d", len(frames), len(colors))
+	}
+	for i, frame := range frames {
+		min := frame.Bounds().Min
+		got := color.RGBAModel.Convert(frame.At(min.X, min.Y))
+		if got != color.RGBAModel.Convert(colors[i]) {
+			t.Errorf("Frame %d is %v, want %v", i, got, colors[i])
+		}
+	}
+}
+
+func TestLoadPNG(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	frames := filepath.Join(dir, "frames")
+	if err := os.Mkdir(frames, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The files of the directory are sorted by name
+	writePNG(t, filepath.Join(frames, "0002.png"), uniform(2, 2, green))
+	writePNG(t, filepath.Join(frames, "0001.png"), uniform(2, 2, red))
+	if err := ioutil.WriteFile(filepath.Join(frames, "readme.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	last := filepath.Join(dir, "last.png")
+	writePNG(t, last, uniform(2, 2, blue))
+
+	src, err := loadSource([]string{frames, last}, grid{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColors(t, src.frames, red, green, blue)
+	if src.delays != nil {
+		t.Errorf("Delays %v of PNG files", src.delays)
+	}
+
+	if _, err := loadSource([]string{filepath.Join(dir, "nowhere")}, grid{}); err == nil {
+		t.Errorf("No error for the missing input")
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.Mkdir(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSource([]string{empty}, grid{}); err == nil {
+		t.Errorf("No error for the directory without PNG files")
+	}
+}
+
+func TestSliceSpriteSheet(t *testing.T) {
+	// 3x2 sheet of 2x4 sprites: red green blue / white red green
+	sheet := image.NewRGBA(image.Rect(0, 0, 6, 8))
+	colors := []color.Color{red, green, blue, white, red, green}
+	for i, c := range colors {
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 2; x++ {
+				sheet.Set(i%3*2+x, i/3*4+y, c)
+			}
+		}
+	}
+
+	sprites, err := sliceSpriteSheet(sheet, grid{3, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColors(t, sprites, colors...)
+	for i, sprite := range sprites {
+		if sprite.Bounds() != image.Rect(0, 0, 2, 4) {
+			t.Errorf("Sprite %d bounds %v", i, sprite.Bounds())
+		}
+		// The sprite does not take the pixels of the next one
+		if c := color.RGBAModel.Convert(sprite.At(1, 3)); c != colors[i] {
+			t.Errorf("Sprite %d has %v", i, c)
+		}
+	}
+
+	if _, err := sliceSpriteSheet(sheet, grid{4, 2}); err == nil {
+		t.Errorf("No error for the sheet not divided into the grid")
+	}
+
+	// The sheet file is sliced by loadSource
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	sheetFile := filepath.Join(dir, "sheet.png")
+	writePNG(t, sheetFile, sheet)
+	src, err := loadSource([]string{sheetFile}, grid{3, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColors(t, src.frames, colors...)
+}
+
+func writeGIF(t *testing.T, fileName string, g *gif.GIF) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadGIF(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	palette := color.Palette{color.Transparent, red, green, blue}
+	frame := func(r image.Rectangle, c uint8) *image.Paletted {
+		img := image.NewPaletted(r, palette)
+		for i := range img.Pix {
+			img.Pix[i] = c
+		}
+		return img
+	}
+	gifFile := filepath.Join(dir, "giggles.gif")
+	writeGIF(t, gifFile, &gif.GIF{
+		Image: []*image.Paletted{
+			frame(image.Rect(0, 0, 2, 2), 1),
+			// The frame over the part of the previous one
+			frame(image.Rect(0, 0, 1, 1), 2),
+			frame(image.Rect(0, 0, 1, 1), 3),
+			frame(image.Rect(1, 1, 2, 2), 3),
+		},
+		Delay:    []int{8, 8, 20, 8},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+		Config:   image.Config{ColorModel: palette, Width: 2, Height: 2},
+	})
+
+	src, err := loadSource([]string{gifFile}, grid{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColors(t, src.frames, red, green, blue, green)
+	// The frames are shown over the previous ones
+	if c := color.RGBAModel.Convert(src.frames[3].At(1, 0)); c != red {
+		t.Errorf("Frame 3 at 1, 0 is %v, want %v", c, red)
+	}
+	want := []time.Duration{80 * time.Millisecond, 80 * time.Millisecond, 200 * time.Millisecond, 80 * time.Millisecond}
+	if !reflect.DeepEqual(src.delays, want) {
+		t.Errorf("Delays %v, want %v", src.delays, want)
+	}
+}
+
+// manifestAnimations returns the animations of the manifest in the directory
+func manifestAnimations(t *testing.T, dir string) map[string]map[string]interface{} {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Animations map[string]map[string]interface{} `json:"animations"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	return manifest.Animations
+}
+
+func TestUpdateManifest(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	opts := options{OutputDir: dir, SeriesName: "giggles"}
+
+	// The most common delay is the frame duration, the others are holds
+	delays := []time.Duration{80 * time.Millisecond, 80 * time.Millisecond, 200 * time.Millisecond, 80 * time.Millisecond}
+	if err := updateManifest(opts, delays); err != nil {
+		t.Fatal(err)
+	}
+	giggles := manifestAnimations(t, dir)["giggles"]
+	want := map[string]interface{}{
+		"frameDuration": "80ms",
+		"holds":         []interface{}{map[string]interface{}{"frame": 2.0, "duration": "200ms"}},
+	}
+	if !reflect.DeepEqual(giggles, want) {
+		t.Errorf("giggles %v, want %v", giggles, want)
+	}
+
+	// The other settings are kept
+	manifest := `{"fallback": {}, "animations": {"giggles": {"mode": "once", "next": "lotus", "holds": [{"frame": 0, "duration": "1s"}]}, "lotus": {"weight": 2}}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, manifestFileName), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateManifest(opts, []time.Duration{100 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	animations := manifestAnimations(t, dir)
+	want = map[string]interface{}{"frameDuration": "100ms", "mode": "once", "next": "lotus"}
+	if !reflect.DeepEqual(animations["giggles"], want) {
+		t.Errorf("giggles %v, want %v", animations["giggles"], want)
+	}
+	if want := map[string]interface{}{"weight": 2.0}; !reflect.DeepEqual(animations["lotus"], want) {
+		t.Errorf("lotus %v, want %v", animations["lotus"], want)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fallback struct {
+		Fallback *json.RawMessage `json:"fallback"`
+	}
+	if err := json.Unmarshal(data, &fallback); err != nil || fallback.Fallback == nil {
+		t.Errorf("No fallback in %s", data)
+	}
+
+	// The GIF without delays does not change the manifest
+	if err := updateManifest(opts, []time.Duration{0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(manifestAnimations(t, dir)["giggles"], want) {
+		t.Errorf("giggles is changed without delays")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, manifestFileName), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateManifest(opts, delays); err == nil {
+		t.Errorf("No error for the invalid manifest")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/rmcsoft/hasp"
+	"github.com/rmcsoft/hasp/schedule"
+)
+
+type lintOptions struct {
+	PackedImageDir string `short:"i" long:"image-dir" description:"Packed image directory to check" required:"true"`
+	DisplayWidth   int    `long:"display-width"  default:"600"  description:"Display width, 0 to skip the frame size check"`
+	DisplayHeight  int    `long:"display-height" default:"1024" description:"Display height, 0 to skip the frame size check"`
+	SchedulePath   string `long:"schedule"       description:"Schedule whose idle animations are checked too (JSON)"`
+	Strict         bool   `long:"strict"         description:"Fail on warnings too"`
+}
+
+// runLint checks the packed image directory against the character.
+// It returns the exit code: 1 if there are errors, 2 if the check failed.
+func runLint(args []string) int {
+	var opts lintOptions
+	parser := flags.NewParser(&opts, flags.HelpFlag|flags.PassDoubleDash)
+	parser.Usage = "lint [OPTIONS]"
+	if _, err := parser.ParseArgs(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	animations, err := characterAnimations(opts.SchedulePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report, err := hasp.LintImageDir(opts.PackedImageDir, hasp.LintParams{
+		Animations:    animations,
+		DisplayWidth:  opts.DisplayWidth,
+		DisplayHeight: opts.DisplayHeight,
+		PixFormat:     pixFormat,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	for _, issue := range report.Issues {
+		fmt.Println(issue)
+	}
+	fmt.Printf("%d series, %d frames, %.1f MiB mapped to memory, %d issues\n",
+		report.SeriesCount, report.FrameCount, float64(report.MemoryFootprint)/(1<<20), len(report.Issues))
+
+	if report.HasErrors() || (opts.Strict && len(report.Issues) > 0) {
+		return 1
+	}
+	return 0
+}
+
+// characterAnimations returns the animations used by the character states and
+// the schedule modes
+func characterAnimations(schedulePath string) ([]string, error) {
+	used := make(map[string]bool)
+	for _, animations := range stateAnimations {
+		for _, animation := range animations {
+			used[animation] = true
+		}
+	}
+
+	if len(schedulePath) != 0 {
+		sched, err := schedule.LoadSchedule(schedulePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, settings := range sched.Modes {
+			for _, animation := range settings.IdleAnimations {
+				used[animation] = true
+			}
+		}
+	}
+
+	animations := make([]string, 0, len(used))
+	for animation := range used {
+		animations = append(animations, animation)
+	}
+	sort.Strings(animations)
+	return animations, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmcsoft/chanim"
+)
+
+// writeImageDir writes the packed image directory of the series of one frame
+func writeImageDir(t *testing.T, series ...string) string {
+	path, err := ioutil.TempDir("", "hasp-lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppixmap, err := chanim.PackPixmap(&chanim.Pixmap{
+		Data:        make([]byte, 4*8*2),
+		Width:       4,
+		Height:      8,
+		BytePerLine: 4 * 2,
+		PixFormat:   pixFormat,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range series {
+		if err := os.Mkdir(filepath.Join(path, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ppixmap.Save(filepath.Join(path, name, "000.ppixmap")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestRunLint(t *testing.T) {
+	animations, err := characterAnimations("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean := writeImageDir(t, animations...)
+	defer os.RemoveAll(clean)
+	// The orphan transition series is a warning
+	warnings := writeImageDir(t, append(animations, "ghost_exit")...)
+	defer os.RemoveAll(warnings)
+	// The missing animation is an error
+	errors := writeImageDir(t, animations[1:]...)
+	defer os.RemoveAll(errors)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"clean", []string{"-i", clean}, 0},
+		{"clean strict", []string{"-i", clean, "--strict"}, 0},
+		{"warnings", []string{"-i", warnings}, 0},
+		{"warnings strict", []string{"-i", warnings, "--strict"}, 1},
+		{"errors", []string{"-i", errors}, 1},
+		{"errors strict", []string{"-i", errors, "--strict"}, 1},
+		{"missing directory", []string{"-i", filepath.Join(clean, "nowhere")}, 2},
+		{"missing schedule", []string{"-i", clean, "--schedule", filepath.Join(clean, "nowhere.json")}, 2},
+		{"no image directory", nil, 2},
+		{"help", []string{"--help"}, 0},
+	}
+	for _, test := range tests {
+		if code := runLint(test.args); code != test.code {
+			t.Errorf("%s: exit code %d, want %d", test.name, code, test.code)
+		}
+	}
+}
@@ -7,8 +7,14 @@ import (
 	"image"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -16,14 +22,24 @@ import (
 	"github.com/rmcsoft/chanim"
 	"github.com/rmcsoft/hasp"
 	atmel "github.com/rmcsoft/hasp/atmel/periph_gpio"
+	"github.com/rmcsoft/hasp/control"
+	"github.com/rmcsoft/hasp/evdev"
 	"github.com/rmcsoft/hasp/events"
 	"github.com/rmcsoft/hasp/haspaws"
+	"github.com/rmcsoft/hasp/locale"
+	"github.com/rmcsoft/hasp/metrics"
+	"github.com/rmcsoft/hasp/offline"
+	"github.com/rmcsoft/hasp/outputs"
+	"github.com/rmcsoft/hasp/schedule"
 	"github.com/rmcsoft/hasp/sound"
+	"github.com/rmcsoft/hasp/touch"
 
 	"github.com/aws/aws-sdk-go-v2/aws/endpoints"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
 	"github.com/aws/aws-sdk-go-v2/service/lexruntimeservice"
 	"github.com/jessevdk/go-flags"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/host"
 )
 
 const (
@@ -51,6 +67,68 @@ type options struct {
 
 	SplashScreenPath string `long:"splash-screen" description:"Image for splash screen (ppixmap format)"`
 
+	MetricsAddr string `long:"metrics-addr" description:"Address to serve Prometheus metrics on (e.g. ':9100'), disabled if empty"`
+
+	ControlAddr      string `long:"control-addr"      description:"Address to serve the control API on (e.g. '127.0.0.1:8090'), disabled if empty"`
+	ControlToken     string `long:"control-token"     description:"Token required by the control API"`
+	AnnouncementsDir string `long:"announcements-dir" description:"Directory with announcement clips (*.pcm, 16 kHz mono S16LE)"`
+
+	SchedulePath string `long:"schedule" description:"Schedule of opening hours, quiet hours and announcements (JSON)"`
+
+	LexTimeout time.Duration `long:"lex-timeout" default:"10s" description:"Time limit of a request to the bot including its retries"`
+	LexRetries int           `long:"lex-retries" default:"1"   description:"Number of the retries of a failed request to the bot"`
+
+	SessionTimeout  time.Duration `long:"session-timeout"   default:"90s" description:"The conversation ends if nothing happens for the time, 0 disables"`
+	SessionMaxTurns int           `long:"session-max-turns" default:"20"  description:"The conversation ends after the utterances of the visitor, 0 disables"`
+
+	Site          string `long:"site"           description:"Site of the kiosk, e.g. the building, sent to the bot"`
+	KioskID       string `long:"kiosk-id"       description:"Identifier of the kiosk sent to the bot (default: the host name)"`
+	TimeZone      string `long:"timezone"       description:"IANA time zone of the kiosk sent to the bot (default: $TZ)"`
+	KioskLanguage string `long:"kiosk-language" description:"Language of the kiosk sent to the bot until another one is selected (default: the first conversation language)"`
+
+	LanguagesPath string `long:"languages" description:"Conversation languages with their wake words, bots and prompts (JSON), the keyword and the prompts are of the only language if empty"`
+
+	UsePresence     bool          `long:"presence"         description:"Greet only visitors who stop in front of the sensors"`
+	PresenceHold    time.Duration `long:"presence-hold"    default:"1.5s" description:"How long the sensors must be active to greet the visitor"`
+	PresenceRelease time.Duration `long:"presence-release" default:"3s"   description:"How long the sensors must be inactive to consider the visitor left"`
+
+	PresenceSensor   string  `long:"presence-sensor"   default:"gpio" choice:"gpio" choice:"pir" choice:"tof" choice:"command" choice:"socket" description:"Sensor detecting the visitors, gpio tracks the walking direction across the two sensors, pir detects the visitor when both sensors are HIGH"`
+	PresenceDistance float64 `long:"presence-distance" default:"1.2" description:"Distance in meters within which the visitor is detected (tof, command, socket)"`
+	I2CBus           string  `long:"i2c-bus"           description:"I2C bus of the distance sensor, the first one if empty"`
+	TofAddr          uint16  `long:"tof-addr"          default:"16" description:"I2C address of the distance sensor"`
+	PresenceCommand  string  `long:"presence-command"  description:"Command printing the sensor values line by line"`
+	PresenceSocket   string  `long:"presence-socket"   description:"Unix socket streaming the sensor values line by line"`
+
+	GpioBackend  string        `long:"gpio-backend"  default:"sysfs" choice:"sysfs" choice:"chardev" description:"Kernel interface of the sensors pins"`
+	GpioSysfs    string        `long:"gpio-sysfs"    default:"/sys/class/gpio" description:"GPIO sysfs directory of the sensors pins (sysfs backend)"`
+	GpioChip     string        `long:"gpio-chip"     default:"/dev/gpiochip0" description:"GPIO chip of the sensors pins, the pin numbers are line offsets (chardev backend)"`
+	GpioDebounce time.Duration `long:"gpio-debounce" description:"Debounce period of the sensors applied by the kernel (chardev backend)"`
+
+	OutputsPath string `long:"outputs" description:"Output pins (LEDs, relays) and their actions by state and event (JSON)"`
+
+	TouchDevice string `long:"touch-device" default:"/dev/input/event0" description:"Touch panel evdev device or a stream recorded from it"`
+	TouchPath   string `long:"touch"        description:"Touch regions by state and their events (JSON), disabled if empty"`
+
+	ScannerDevice string `long:"scanner-device" description:"Evdev device of a barcode scanner acting as a keyboard, the scanned codes are sent as text, disabled if empty"`
+
+	TTSCommand  string        `long:"tts-command"   description:"Text-to-speech command reading the text from stdin and writing WAV or raw samples to stdout (e.g. 'espeak-ng --stdin --stdout')"`
+	TTSRate     int           `long:"tts-rate"      default:"22050" description:"Sample rate of the raw samples of the text-to-speech command"`
+	TTSTimeout  time.Duration `long:"tts-timeout"   default:"10s" description:"Time limit of the text-to-speech command"`
+	MsgSentText string        `long:"msg-sent-text" default:"{{with .Slot \"CoworkerFirstName\"}}I have sent a message to {{.}} {{$.Slot \"CoworkerLastName\"}}.{{else}}{{with .Slot \"AdventCompany\"}}I have sent a message to {{.}}.{{end}}{{end}}" description:"Template of the text spoken by the text-to-speech when the message is sent, executed with the conversation session; the recorded prompt is played if the text is empty"`
+
+	FAQ            string        `long:"faq"             description:"Frequent questions answered on the device before asking the bot (JSON), disabled if empty"`
+	OfflineGrammar string        `long:"offline-grammar" description:"Keyword and regex rules answering offline when the bot is unreachable (JSON), disabled if empty"`
+	ASRCommand     string        `long:"asr-command"     description:"Local speech recognizer command printing the transcript, {wav} is replaced with the speech file (e.g. 'whisper-cli -m ggml-base.en.bin -nt -np -f {wav}')"`
+	ASRSocket      string        `long:"asr-socket"      description:"Unix socket of a local speech recognizer server, used if the command is not set"`
+	ASRTimeout     time.Duration `long:"asr-timeout"     default:"15s" description:"Time limit of the local speech recognizer"`
+
+	Captions              bool          `long:"captions"                description:"Show captions of what the character says and hears"`
+	CaptionsPosition      string        `long:"captions-position"       default:"bottom" choice:"top" choice:"bottom" description:"Edge of the display the captions are shown at"`
+	CaptionsScale         int           `long:"captions-scale"          default:"3" description:"Size of the caption font pixel in pixels"`
+	CaptionsLines         int           `long:"captions-lines"          default:"3" description:"Number of the caption lines shown at once"`
+	CaptionsHeardDuration time.Duration `long:"captions-heard-duration" default:"3s" description:"How long the recognized words of the user are shown"`
+	CaptionsNotRotate     bool          `long:"captions-not-rotate"     description:"Disable caption rotate, for the frames packed with --not-rotate"`
+
 	Config func(s string) error `long:"config" no-ini:"true"`
 }
 
@@ -144,20 +222,119 @@ func makePaintEngine(opts options) chanim.PaintEngine {
 		log.Fatal(err)
 	}
 	showSplashScreen(opts, paintEngine)
-	return paintEngine
+	return hasp.NewInstrumentedPaintEngine(paintEngine)
+}
+
+func loadAnimationManifest(opts options) hasp.AnimationManifest {
+	manifest, err := hasp.LoadAnimationManifest(opts.PackedImageDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return manifest
 }
 
-func makeAnimator(opts options) *chanim.Animator {
+func makeAnimator(opts options, manifest hasp.AnimationManifest, overlays *hasp.Overlays) *chanim.Animator {
 	log.Debug("Making paint engine")
 	paintEngine := makePaintEngine(opts)
 	log.Debug("Creating animator")
-	animator, err := hasp.CreateAnimator(paintEngine, opts.PackedImageDir)
+	animator, err := hasp.CreateAnimatorWithManifest(paintEngine, opts.PackedImageDir, manifest, overlays)
 	if err != nil {
 		log.Fatal(err)
 	}
 	return animator
 }
 
+func makeCaptions(opts options) *hasp.Captions {
+	if !opts.Captions {
+		return nil
+	}
+
+	params := hasp.DefaultCaptionParams
+	params.Position = hasp.CaptionPosition(opts.CaptionsPosition)
+	params.Scale = opts.CaptionsScale
+	params.MaxLines = opts.CaptionsLines
+	params.HeardDuration = opts.CaptionsHeardDuration
+	params.PixFormat = pixFormat
+	params.Rotate = !opts.CaptionsNotRotate
+	return hasp.NewCaptions(params)
+}
+
+func makeTextToSpeech(opts options) sound.TextToSpeech {
+	args := strings.Fields(opts.TTSCommand)
+	if len(args) == 0 {
+		return nil
+	}
+	tts := sound.NewCommandTextToSpeech(sound.AudioFormat{
+		ChannelCount: 1,
+		SampleType:   sound.S16LE,
+		SampleRate:   opts.TTSRate,
+	}, args[0], args[1:]...)
+	tts.Timeout = opts.TTSTimeout
+	return tts
+}
+
+func makeRecognizer(opts options) offline.Recognizer {
+	if args := strings.Fields(opts.ASRCommand); len(args) != 0 {
+		recognizer := offline.NewCommandRecognizer(args[0], args[1:]...)
+		recognizer.Timeout = opts.ASRTimeout
+		return recognizer
+	}
+	if len(opts.ASRSocket) != 0 {
+		recognizer := offline.NewSocketRecognizer(opts.ASRSocket)
+		recognizer.Timeout = opts.ASRTimeout
+		return recognizer
+	}
+	return nil
+}
+
+func makeFAQBackend(opts options, recognizer offline.Recognizer, tts sound.TextToSpeech) haspaws.FAQBackend {
+	if len(opts.FAQ) == 0 {
+		return nil
+	}
+
+	faq, err := offline.LoadFAQ(opts.FAQ)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if recognizer == nil {
+		log.Warn("No local speech recognizer, only the text is answered from the FAQ")
+	}
+
+	backend, err := offline.NewFAQBackend(faq, recognizer, tts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return backend
+}
+
+func makeMsgSentText(opts options) *template.Template {
+	text, err := template.New("msg-sent").Parse(opts.MsgSentText)
+	if err != nil {
+		log.Fatalf("Failed to parse the text of the sent message: %v", err)
+	}
+	return text
+}
+
+func makeOfflineBackend(opts options, recognizer offline.Recognizer, tts sound.TextToSpeech) haspaws.OfflineBackend {
+	if len(opts.OfflineGrammar) == 0 {
+		return nil
+	}
+
+	grammar, err := offline.LoadGrammar(opts.OfflineGrammar)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if recognizer == nil {
+		log.Warn("No local speech recognizer, only the text is answered offline")
+	}
+
+	backend, err := offline.NewBackend(grammar, recognizer, tts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return backend
+}
+
 func makeSoundPlayer(opts options) *sound.SoundPlayer {
 	player, err := sound.NewSoundPlayer(opts.PlayDevice)
 	if err != nil {
@@ -166,10 +343,133 @@ func makeSoundPlayer(opts options) *sound.SoundPlayer {
 	return player
 }
 
-func makeHotWordDetector(opts options) *sound.HotWordDetector {
+func loadLanguages(opts options) *locale.Config {
+	if len(opts.LanguagesPath) == 0 {
+		return nil
+	}
+
+	languages, err := locale.LoadConfig(opts.LanguagesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return languages
+}
+
+// keywords returns the keyword files and the languages they select.
+// The keyword of the default language defaults to the --keyword one.
+func keywords(opts options, languages *locale.Config) (keywordPaths []string, keywordLanguages []string) {
+	if languages == nil {
+		return []string{opts.KeywordPath}, []string{""}
+	}
+
+	for i, language := range languages.Languages {
+		keywordPath := language.Keyword
+		if i == 0 && len(keywordPath) == 0 {
+			keywordPath = opts.KeywordPath
+		}
+		if len(keywordPath) != 0 {
+			keywordPaths = append(keywordPaths, keywordPath)
+			keywordLanguages = append(keywordLanguages, language.Code)
+		}
+	}
+	return keywordPaths, keywordLanguages
+}
+
+// makePrompts loads the prompt of the state in the languages,
+// defaultPath is the prompt of the default language if it has none.
+// The state is silent in the languages without the prompt if defaultPath
+// is empty.
+func makePrompts(languages *locale.Config, state string, defaultPath string) hasp.Prompts {
+	prompts := hasp.Prompts{}
+	if languages != nil {
+		for _, language := range languages.Languages {
+			if path, ok := language.Prompts[state]; ok {
+				prompts[language.Code] = loadAudioData(path)
+			}
+		}
+		if prompt, ok := prompts[languages.Default().Code]; ok {
+			prompts[""] = prompt
+		}
+	}
+	if _, ok := prompts[""]; !ok && defaultPath != "" {
+		prompts[""] = loadAudioData(defaultPath)
+	}
+	return prompts
+}
+
+// conversationStates returns the states left for goodbye when the session
+// ends before the character goes idle by itself: all the states but idle,
+// goodbye and the scheduled ones, see addScheduledStates
+func conversationStates(states hasp.States) []string {
+	var names []string
+	for name := range states {
+		switch name {
+		case "idle", "goodbye", "announcing", "tells-closed":
+		default:
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func makeSessionManager(opts options, captions *hasp.Captions) *hasp.SessionManager {
+	return hasp.NewSessionManager(hasp.SessionManagerParams{
+		TurnStates:        []string{"processing"},
+		ListenStates:      []string{"listens", "type"},
+		IdleStates:        []string{"idle"},
+		InactivityTimeout: opts.SessionTimeout,
+		MaxTurns:          opts.SessionMaxTurns,
+		OnEnd: func(ctx hasp.CharacterCtx, reason string) {
+			if captions != nil {
+				captions.Hide()
+			}
+		},
+	})
+}
+
+func makeKioskIdentity(opts options, languages *locale.Config) haspaws.KioskIdentity {
+	identity := haspaws.KioskIdentity{
+		Site:     opts.Site,
+		KioskID:  opts.KioskID,
+		TimeZone: opts.TimeZone,
+		Language: opts.KioskLanguage,
+	}
+
+	if len(identity.KioskID) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Errorf("Failed to get the host name: %v", err)
+		}
+		identity.KioskID = hostname
+	}
+	if len(identity.TimeZone) == 0 {
+		identity.TimeZone = os.Getenv("TZ")
+	}
+	if len(identity.Language) == 0 && languages != nil {
+		identity.Language = languages.Default().Code
+	}
+
+	log.Infof("Kiosk identity: %+v", identity)
+	return identity
+}
+
+func makeLanguageBots(languages *locale.Config) map[string]haspaws.LexBot {
+	if languages == nil {
+		return nil
+	}
+
+	bots := make(map[string]haspaws.LexBot)
+	for _, language := range languages.Languages {
+		bots[language.Code] = haspaws.LexBot{Name: language.BotName, Alias: language.BotAlias}
+	}
+	return bots
+}
+
+func makeHotWordDetector(opts options, keywordPaths []string) *sound.HotWordDetector {
 	params := sound.HotWordDetectorParams{
 		CaptureDeviceName: opts.CaptureDevice,
-		KeywordPath:       opts.KeywordPath,
+		KeywordPaths:      keywordPaths,
 		ModelPath:         opts.ModelParamPath,
 		DebugSound:        opts.Trace,
 	}
@@ -211,86 +511,436 @@ func loadAudioData(fileName string) *sound.AudioData {
 	return audioData
 }
 
-func makeCharacter(opts options) *hasp.Character {
+func startMetricsServer(opts options) {
+	if len(opts.MetricsAddr) == 0 {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	go func() {
+		log.Infof("Serving metrics on '%s'", opts.MetricsAddr)
+		if err := http.ListenAndServe(opts.MetricsAddr, mux); err != nil {
+			log.Errorf("Metrics server stopped: %v", err)
+		}
+	}()
+}
+
+type controlTarget struct {
+	*hasp.Character
+	clips map[string]*sound.AudioData
+	tts   sound.TextToSpeech
+}
+
+func (t controlTarget) Say(text string) error {
+	if t.tts == nil {
+		return fmt.Errorf("Text-to-speech is not configured")
+	}
+	speech, err := t.tts.Synthesize(text)
+	if err != nil {
+		return err
+	}
+	return t.announce(speech)
+}
+
+func (t controlTarget) PlayClip(name string) error {
+	clip, ok := t.clips[name]
+	if !ok {
+		return fmt.Errorf("Unknown announcement '%s'", name)
+	}
+	return t.announce(clip)
+}
+
+// announce plays the speech in the announcing state, it is refused
+// unless the character is idle
+func (t controlTarget) announce(speech *sound.AudioData) error {
+	if err := t.Announce(speech); err != nil {
+		log.Warnf("Control: %v", err)
+		return control.ErrBusy
+	}
+	return nil
+}
+
+func loadAnnouncements(dir string) map[string]*sound.AudioData {
+	clips := make(map[string]*sound.AudioData)
+	if len(dir) == 0 {
+		return clips
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pcm"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		clips[name] = loadAudioData(file)
+	}
+	return clips
+}
+
+func startControlServer(opts options, character *hasp.Character, tts sound.TextToSpeech) {
+	if len(opts.ControlAddr) == 0 {
+		return
+	}
+
+	server, err := control.NewServer(controlTarget{
+		Character: character,
+		clips:     loadAnnouncements(opts.AnnouncementsDir),
+		tts:       tts,
+	}, opts.ControlToken)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server.RegisterEvent(sound.StopEventName, control.WithoutArgs(func() *events.Event {
+		return sound.NewStopEvent(nil)
+	}))
+
+	go func() {
+		log.Infof("Serving control API on '%s'", opts.ControlAddr)
+		if err := http.ListenAndServe(opts.ControlAddr, server); err != nil {
+			log.Errorf("Control server stopped: %v", err)
+		}
+	}()
+}
+
+func loadOutputs(opts options) *outputs.Config {
+	if len(opts.OutputsPath) == 0 {
+		return nil
+	}
+
+	config, err := outputs.LoadConfig(opts.OutputsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The output pins are exported by the same driver as the sensors
+	pins := sensorsPins(opts)
+	for _, output := range config.Outputs {
+		pins = append(pins, atmel.AtmelGpioPin{Number: output.Number, Name: output.Pin})
+	}
+	if err := hasp.RegisterGpioPins(pins); err != nil {
+		log.Errorf("Will not use output pins: %v", err)
+		return nil
+	}
+	return config
+}
+
+func startOutputs(config *outputs.Config, character *hasp.Character) *outputs.Controller {
+	if config == nil {
+		return nil
+	}
+
+	controller, err := outputs.NewController(config)
+	if err != nil {
+		log.Errorf("Will not use output pins: %v", err)
+		return nil
+	}
+
+	for state, actions := range controller.StateActions() {
+		character.OnEnter(state, hasp.DriveOutputs(controller, actions...))
+	}
+	transitions, _ := character.SubscribeTransitions()
+	go controller.Run(transitions)
+	return controller
+}
+
+func startTouch(opts options, character *hasp.Character) {
+	if len(opts.TouchPath) == 0 {
+		return
+	}
+
+	config, err := touch.LoadConfig(opts.TouchPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	controller := touch.NewController(config, character)
+	device, err := controller.OpenDevice(opts.TouchDevice)
+	if err != nil {
+		log.Errorf("Will not use touch input: %v", err)
+		return
+	}
+
+	go func() {
+		defer device.Close()
+		log.Infof("Reading touch input from '%s'", opts.TouchDevice)
+		if err := controller.Run(evdev.NewEventReader(device)); err != nil {
+			log.Errorf("Touch input stopped: %v", err)
+		}
+	}()
+}
+
+// startScanner sends the scanned codes to the conversation backend,
+// e.g. a meeting invite code
+func startScanner(opts options, character *hasp.Character) {
+	if len(opts.ScannerDevice) == 0 {
+		return
+	}
+
+	device, err := os.Open(opts.ScannerDevice)
+	if err != nil {
+		log.Errorf("Will not use the barcode scanner: %v", err)
+		return
+	}
+	// The scanner is a keyboard, its codes would also be typed into the console
+	if err := evdev.Grab(device); err != nil {
+		log.Warnf("Barcode scanner: %v, the codes also reach the other readers", err)
+	}
+
+	go func() {
+		defer device.Close()
+		log.Infof("Reading barcode scanner '%s'", opts.ScannerDevice)
+		reader := evdev.NewLineReader(evdev.NewEventReader(device))
+		for {
+			line, err := reader.ReadLine()
+			if err != nil {
+				log.Errorf("Barcode scanner stopped: %v", err)
+				return
+			}
+			if len(strings.TrimSpace(line)) == 0 {
+				continue
+			}
+			if err := character.InjectEvent(events.NewTextInputEvent(line, "scanner")); err != nil {
+				log.Errorf("Failed to send the scanned code: %v", err)
+			}
+		}
+	}()
+}
+
+func makeScheduler(opts options, soundPlayer *sound.SoundPlayer) *schedule.Scheduler {
+	if len(opts.SchedulePath) == 0 {
+		return nil
+	}
+
+	sched, err := schedule.LoadSchedule(opts.SchedulePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scheduler := schedule.NewScheduler(sched, schedule.SystemClock{})
+	scheduler.Watch(func(mode schedule.Mode, settings schedule.ModeSettings) {
+		soundPlayer.SetVolume(settings.PlaybackVolume())
+	})
+	return scheduler
+}
+
+// addScheduledStates adds the after-hours reply and the scheduled
+// announcements played in the announcing state
+func addScheduledStates(opts options, languages *locale.Config, states hasp.States,
+	eventDescs hasp.EventDescs) hasp.EventDescs {
+	states["tells-closed"] = hasp.NewTellsHelpStateWithPrompts(
+		stateAnimations["tells-closed"],
+		makePrompts(languages, "tells-closed", "../wavs/closed.wav"),
+	)
+
+	return append(eventDescs,
+		hasp.EventDesc{
+			Name: schedule.AnnouncementDueEventName,
+			Src:  []string{"idle"},
+			Dst:  "announcing",
+		},
+		hasp.EventDesc{
+			Name: schedule.ClosedEventName,
+			Src:  []string{"idle"},
+			Dst:  "tells-closed",
+		},
+		hasp.EventDesc{
+			Name: sound.SoundPlayedEventName,
+			Src:  []string{"tells-closed"},
+			Dst:  "idle",
+		},
+	)
+}
+
+func sensorsPins(opts options) atmel.AtmelGpioPins {
+	return atmel.AtmelGpioPins{
+		atmel.AtmelGpioPin{Number: opts.LeftSensorPin, Name: opts.LeftSensorPort},
+		atmel.AtmelGpioPin{Number: opts.RightSensorPin, Name: opts.RightSensorPort},
+	}
+}
+
+func makePresenceMonitor(opts options) events.PresenceDetector {
+	if !opts.UsePresence {
+		return nil
+	}
+
+	if opts.PresenceSensor == "gpio" {
+		params := events.DefaultPresenceParams
+		params.HoldTime = opts.PresenceHold
+		params.ReleaseTime = opts.PresenceRelease
+		return hasp.NewPresenceMonitor(sensorsPins(opts), params)
+	}
+
+	sensor, err := makePresenceSensor(opts)
+	if err != nil {
+		log.Errorf("Will not use presence detection: %v", err)
+		return nil
+	}
+
+	params := events.DefaultSensorPresenceParams
+	params.MaxDistance = opts.PresenceDistance
+	params.HoldTime = opts.PresenceHold
+	params.ReleaseTime = opts.PresenceRelease
+	return hasp.NewSensorMonitor([]events.PresenceSensor{sensor}, params)
+}
+
+func makePresenceSensor(opts options) (events.PresenceSensor, error) {
+	switch opts.PresenceSensor {
+	case "pir":
+		return hasp.NewGpioPresenceSensor(sensorsPins(opts))
+
+	case "tof":
+		// The GPIO driver must be registered before periph is initialized
+		if err := hasp.RegisterGpioPins(sensorsPins(opts)); err != nil {
+			log.Error(err)
+		}
+		if _, err := host.Init(); err != nil {
+			return nil, err
+		}
+		bus, err := i2creg.Open(opts.I2CBus)
+		if err != nil {
+			return nil, err
+		}
+		params := events.DefaultI2CDistanceParams
+		params.Addr = opts.TofAddr
+		return events.NewI2CDistanceSensor(bus, params), nil
+
+	case "command":
+		args := strings.Fields(opts.PresenceCommand)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Presence command is not specified")
+		}
+		return events.NewCommandSensor(args[0], args[1:]...)
+
+	case "socket":
+		return events.NewUnixSocketSensor(opts.PresenceSocket)
+	}
+	return nil, fmt.Errorf("Unknown presence sensor '%s'", opts.PresenceSensor)
+}
+
+// stateAnimations are the animations of the character states, see makeCharacter
+// and addScheduledStates. They are checked by the lint command.
+var stateAnimations = map[string][]string{
+	"idle":             {"lotus", "reading", "giggles", "reading"},
+	"sensor-triggered": {"silent"},
+	"tells-fullhelp":   {"tells"},
+	"tells-help":       {"tells"},
+	"tells-there":      {"tells"},
+	"tells-aws":        {"tells"},
+	"tells-bye":        {"tells"},
+	"listens":          {"silent"},
+	"processing":       {"silent"},
+	"goodbye":          {"goodbye"},
+	"call":             {"calls2"},
+	"tell-type":        {"tells"},
+	"type":             {"SMS"},
+	"tell-msg-sent":    {"tells"},
+	"announcing":       {"tells"},
+	"tells-closed":     {"tells"},
+}
+
+func makeCharacter(opts options, tts sound.TextToSpeech) *hasp.Character {
 
 	svc := makeAwsSession(opts)
+	recognizer := makeRecognizer(opts)
 	soundPlayer := makeSoundPlayer(opts)
-	hotWordDetector := makeHotWordDetector(opts)
+	languages := loadLanguages(opts)
+	keywordPaths, keywordLanguages := keywords(opts, languages)
+	hotWordDetector := makeHotWordDetector(opts, keywordPaths)
+	scheduler := makeScheduler(opts, soundPlayer)
+	presence := makePresenceMonitor(opts)
+	manifest := loadAnimationManifest(opts)
+	idleAnimations := stateAnimations["idle"]
 
-	inSound := loadAudioData("../wavs/bing-bong.wav")
-	outSound := loadAudioData("../wavs/bong-bing.wav")
+	inSounds := makePrompts(languages, "listens", "../wavs/bing-bong.wav")
+	outSounds := makePrompts(languages, "listens-exit", "../wavs/bong-bing.wav")
 
 	states := hasp.States{
-		"idle": hasp.NewIdleState(
-			[]string{"lotus", "reading", "giggles", "reading"},
-			time.Duration(2)*time.Minute,
-			hotWordDetector,
-			atmel.AtmelGpioPins{
-				atmel.AtmelGpioPin{Number: opts.LeftSensorPin, Name: opts.LeftSensorPort},
-				atmel.AtmelGpioPin{Number: opts.RightSensorPin, Name: opts.RightSensorPort},
-			},
+		"idle": hasp.NewIdleStateWithParams(hasp.IdleStateParams{
+			AvailableAnimations: idleAnimations,
+			AnimationWeights:    manifest.Weights(idleAnimations),
+			AnimationDuration:   time.Duration(2) * time.Minute,
+			HotWordDetector:     hotWordDetector,
+			SensorsPins:         sensorsPins(opts),
+			Scheduler:           scheduler,
+			Presence:            presence,
+		}),
+		"sensor-triggered": hasp.NewTriggeredStateWithParams(hasp.TriggeredStateParams{
+			AvailableAnimation: stateAnimations["sensor-triggered"][0],
+			HotWordDetector:    hotWordDetector,
+			Presence:           presence,
+		}),
+		"tells-fullhelp": hasp.NewTellsHelpStateWithPrompts(
+			stateAnimations["tells-fullhelp"],
+			makePrompts(languages, "tells-fullhelp", "../wavs/fullhelp.wav"),
 		),
-		"sensor-triggered": hasp.NewTriggeredState(
-			"silent",
-			hotWordDetector,
-			atmel.AtmelGpioPins{
-				atmel.AtmelGpioPin{Number: opts.LeftSensorPin, Name: opts.LeftSensorPort},
-				atmel.AtmelGpioPin{Number: opts.RightSensorPin, Name: opts.RightSensorPort},
-			},
-			10*time.Second,
-		),
-		"tells-fullhelp": hasp.NewTellsHelpState(
-			[]string{"tells"},
-			loadAudioData("../wavs/fullhelp.wav"),
+		"tells-help": hasp.NewTellsHelpStateWithPrompts(
+			stateAnimations["tells-help"],
+			makePrompts(languages, "tells-help", "../wavs/hello-help.wav"),
 		),
-		"tells-help": hasp.NewTellsHelpState(
-			[]string{"tells"},
-			loadAudioData("../wavs/hello-help.wav"),
-		),
-		"tells-there": hasp.NewTellsHelpState(
-			[]string{"tells"},
-			loadAudioData("../wavs/still-there.wav"),
+		"tells-there": hasp.NewTellsHelpStateWithPrompts(
+			stateAnimations["tells-there"],
+			makePrompts(languages, "tells-there", "../wavs/still-there.wav"),
 		),
 		"tells-aws": hasp.NewTellsState(
-			[]string{"tells"},
+			stateAnimations["tells-aws"],
 		),
 		"tells-bye": hasp.NewTellsByeState(
-			[]string{"tells"},
+			stateAnimations["tells-bye"],
 		),
-		"listens": hasp.NewListensState(
-			[]string{"silent"},
+		// The chimes of the listening states are played by their actions
+		"listens": hasp.WithOverlays(hasp.NewListensStateWithPrompts(
+			stateAnimations["listens"],
 			hotWordDetector,
 			soundPlayer,
-			inSound,
-			outSound,
-		),
-		"processing": hasp.NewProcessingState(
-			[]string{"silent"},
-			svc,
-			opts.Debug || opts.Trace,
-		),
-		"goodbye": hasp.NewSingleAniState(
-			"goodbye",
+			nil,
+			nil,
+		), "listening"),
+		"processing": hasp.WithOverlays(hasp.NewProcessingStateWithParams(hasp.ProcessingStateParams{
+			AvailableAnimations: stateAnimations["processing"],
+			Lex: haspaws.LexParams{
+				Client:       svc,
+				Identity:     makeKioskIdentity(opts, languages),
+				TextToSpeech: tts,
+				FAQ:          makeFAQBackend(opts, recognizer, tts),
+				Offline:      makeOfflineBackend(opts, recognizer, tts),
+				Timeout:      opts.LexTimeout,
+				MaxRetries:   opts.LexRetries,
+				Debug:        opts.Debug || opts.Trace,
+			},
+			LanguageBots: makeLanguageBots(languages),
+		}), "processing"),
+		"goodbye": hasp.NewSingleAniStateWithPrompts(
+			stateAnimations["goodbye"][0],
+			makePrompts(languages, "goodbye", ""),
 		),
 		"call": hasp.NewTellsState(
-			[]string{"calls2"},
+			stateAnimations["call"],
 		),
 		"tell-type": hasp.NewTellsState(
-			[]string{"tells"},
+			stateAnimations["tell-type"],
 		),
-		"type": hasp.NewListensState(
-			[]string{"SMS"},
+		"type": hasp.NewListensStateWithPrompts(
+			stateAnimations["type"],
 			hotWordDetector,
 			soundPlayer,
-			inSound,
-			outSound,
+			nil,
+			nil,
 		),
-		"tell-msg-sent": hasp.NewTellsHelpState(
-			[]string{"tells"},
-			loadAudioData("../wavs/msg-sent.wav"),
+		"tell-msg-sent": hasp.NewTellsSessionState(hasp.TellsSessionStateParams{
+			AvailableAnimations: stateAnimations["tell-msg-sent"],
+			Prompts:             makePrompts(languages, "tell-msg-sent", "../wavs/msg-sent.wav"),
+			Text:                makeMsgSentText(opts),
+			TextToSpeech:        tts,
+		}),
+		"announcing": hasp.NewAnnouncementState(
+			stateAnimations["announcing"],
+			loadAnnouncements(opts.AnnouncementsDir),
 		),
 	}
 
+	// The conversation transitions are copied to the tests of
+	// hasp.SessionManager, keep them in sync
 	eventDescs := hasp.EventDescs{
 		hasp.EventDesc{
 			Name: sound.HotWordDetectedEventName,
@@ -302,20 +952,30 @@ func makeCharacter(opts options) *hasp.Character {
 			Src:  []string{"idle"},
 			Dst:  "processing",
 		},
+		hasp.EventDesc{
+			Name: events.LanguageSelectedEventName,
+			Src:  []string{"idle", "sensor-triggered"},
+			Dst:  "tells-help",
+		},
 		hasp.EventDesc{
 			Name: events.GpioEventName,
 			Src:  []string{"idle"},
 			Dst:  "sensor-triggered",
 		},
+		hasp.EventDesc{
+			Name: events.VisitorApproachedEventName,
+			Src:  []string{"idle"},
+			Dst:  "sensor-triggered",
+		},
 		hasp.EventDesc{
 			Name: events.StateWaitTimeoutName,
 			Src:  []string{"sensor-triggered"},
 			Dst:  "idle",
 		},
 		hasp.EventDesc{
-			Name: events.StateFullHelpName,
+			Name: events.VisitorLeftEventName,
 			Src:  []string{"sensor-triggered"},
-			Dst:  "tells-fullhelp",
+			Dst:  "idle",
 		},
 		hasp.EventDesc{
 			Name: sound.HotWordDetectedEventName,
@@ -338,6 +998,11 @@ func makeCharacter(opts options) *hasp.Character {
 			Src:  []string{"listens"},
 			Dst:  "processing",
 		},
+		hasp.EventDesc{
+			Name: events.TextInputEventName,
+			Src:  []string{"idle", "sensor-triggered", "listens", "type"},
+			Dst:  "processing",
+		},
 		hasp.EventDesc{
 			Name: sound.StopEventName,
 			Src:  []string{"listens"},
@@ -373,6 +1038,16 @@ func makeCharacter(opts options) *hasp.Character {
 			Src:  []string{"tell-msg-sent"},
 			Dst:  "idle",
 		},
+		hasp.EventDesc{
+			Name: hasp.AnnouncementEventName,
+			Src:  []string{"idle"},
+			Dst:  "announcing",
+		},
+		hasp.EventDesc{
+			Name: sound.SoundPlayedEventName,
+			Src:  []string{"announcing"},
+			Dst:  "idle",
+		},
 		hasp.EventDesc{
 			Name: sound.StopEventName,
 			Src:  []string{"processing"},
@@ -393,6 +1068,11 @@ func makeCharacter(opts options) *hasp.Character {
 			Src:  []string{"goodbye"},
 			Dst:  "idle",
 		},
+		hasp.EventDesc{
+			Name: events.SessionEndedEventName,
+			Src:  conversationStates(states),
+			Dst:  "goodbye",
+		},
 		hasp.EventDesc{
 			Name: sound.SoundEmptyEventName,
 			Src:  []string{"listens"},
@@ -405,13 +1085,53 @@ func makeCharacter(opts options) *hasp.Character {
 		},
 	}
 
+	if scheduler != nil {
+		eventDescs = addScheduledStates(opts, languages, states, eventDescs)
+	}
+
+	// The visitor who is still there after the wait is told the full help
+	waitTimes := map[string]time.Duration{
+		"sensor-triggered": 10 * time.Second,
+	}
+	transitions := hasp.Transitions{
+		hasp.Transition{
+			Name:  events.StateWaitTimeoutName,
+			Src:   []string{"sensor-triggered"},
+			Dst:   "tells-fullhelp",
+			Guard: hasp.VisitorPresent(presence, sensorsPins(opts)),
+		},
+	}
+
 	eventSources := events.EventSources{}
 
-	animator := makeAnimator(opts)
-	character, err := hasp.NewCharacter("idle", states, eventDescs, eventSources, animator, soundPlayer)
+	overlays := hasp.NewOverlays()
+	animator := makeAnimator(opts, manifest, overlays)
+	character, err := hasp.NewCharacterWithParams(hasp.CharacterParams{
+		InitStateName: "idle",
+		States:        states,
+		EventDescs:    eventDescs,
+		Transitions:   transitions,
+		WaitTimes:     waitTimes,
+		EventSources:  eventSources,
+		Animator:      animator,
+		SoundPlayer:   soundPlayer,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
+	for _, state := range []string{"listens", "type"} {
+		character.OnEnter(state, hasp.PlayPrompt(soundPlayer, inSounds))
+		character.OnLeave(state, hasp.PlayPrompt(soundPlayer, outSounds))
+	}
+	character.SetAnimationManifest(manifest)
+	character.SetKeywordLanguages(keywordLanguages)
+	character.SetOverlays(overlays)
+	captions := makeCaptions(opts)
+	if captions != nil {
+		overlays.Attach(captions)
+		character.SetCaptions(captions)
+	}
+	character.SetSessionManager(makeSessionManager(opts, captions))
 
 	if opts.Debug || opts.Trace {
 		character.SetDebug(true)
@@ -426,6 +1146,10 @@ func makeCharacter(opts options) *hasp.Character {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(runLint(os.Args[2:]))
+	}
+
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp:   true,
 		TimestampFormat: "2006-01-02T15:04:05.999",
@@ -457,9 +1181,51 @@ func main() {
 	// Make sure to call Quit before terminating
 	defer sox.Quit()
 
-	character := makeCharacter(opts)
+	err = hasp.SetGpioParams(hasp.GpioParams{
+		Backend:   hasp.GpioBackend(opts.GpioBackend),
+		SysfsRoot: opts.GpioSysfs,
+		Chip:      opts.GpioChip,
+		Debounce:  opts.GpioDebounce,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	startMetricsServer(opts)
+	outputsConfig := loadOutputs(opts)
+
+	tts := makeTextToSpeech(opts)
+	character := makeCharacter(opts, tts)
+	startControlServer(opts, character, tts)
+	startTouch(opts, character)
+	startScanner(opts, character)
+	controller := startOutputs(outputsConfig, character)
+	handleShutdown(controller)
+
 	err = character.Run()
+	shutdown(controller)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// handleShutdown releases the hardware when the process is terminated
+func handleShutdown(controller *outputs.Controller) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Infof("Got %v, shutting down", sig)
+		shutdown(controller)
+		os.Exit(0)
+	}()
+}
+
+func shutdown(controller *outputs.Controller) {
+	if controller != nil {
+		controller.Close()
+	}
+	if err := hasp.CloseGpio(); err != nil {
+		log.Errorf("Failed to release GPIO: %v", err)
+	}
+}
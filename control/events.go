@@ -0,0 +1,86 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rmcsoft/hasp/events"
+)
+
+// EventDecoder makes the event of POST /api/events from its JSON arguments.
+// It fails if the typed data of the event can't be built from them.
+type EventDecoder func(args []json.RawMessage) (*events.Event, error)
+
+// WithoutArgs decodes the events made without arguments, e.g. GoIdle
+func WithoutArgs(newEvent func() *events.Event) EventDecoder {
+	return func(args []json.RawMessage) (*events.Event, error) {
+		if len(args) != 0 {
+			return nil, errors.New("The event takes no arguments")
+		}
+		return newEvent(), nil
+	}
+}
+
+// named makes the event without data
+func named(name string) func() *events.Event {
+	return func() *events.Event {
+		return &events.Event{Name: name}
+	}
+}
+
+// presence makes the presence event of an unknown visitor
+func presence(name string) func() *events.Event {
+	return func() *events.Event {
+		return events.NewPresenceEvent(name, events.DirectionUnknown, 0)
+	}
+}
+
+// decodeTextInput decodes {"text": "I have a delivery", "source": "kiosk"}
+func decodeTextInput(args []json.RawMessage) (*events.Event, error) {
+	var data events.TextInputEventData
+	if err := decodeData(args, &data); err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(data.Text)) == 0 {
+		return nil, errors.New("Text is required")
+	}
+	if len(data.Source) == 0 {
+		data.Source = "control"
+	}
+	return events.NewTextInputEvent(data.Text, data.Source), nil
+}
+
+// decodeLanguageSelected decodes {"language": "es"}
+func decodeLanguageSelected(args []json.RawMessage) (*events.Event, error) {
+	var data events.LanguageSelectedEventData
+	if err := decodeData(args, &data); err != nil {
+		return nil, err
+	}
+	return events.NewLanguageSelectedEvent(data.Language), nil
+}
+
+// decodeData decodes the only argument of the event
+func decodeData(args []json.RawMessage, data interface{}) error {
+	if len(args) != 1 {
+		return errors.New("The event takes one argument")
+	}
+	if err := json.Unmarshal(args[0], data); err != nil {
+		return fmt.Errorf("Invalid event argument: %v", err)
+	}
+	return nil
+}
+
+// defaultEventDecoders are the events of the events package
+// the server injects, see Server.RegisterEvent
+func defaultEventDecoders() map[string]EventDecoder {
+	return map[string]EventDecoder{
+		events.StateGoIdleName:            WithoutArgs(named(events.StateGoIdleName)),
+		events.StateWaitTimeoutName:       WithoutArgs(named(events.StateWaitTimeoutName)),
+		events.VisitorApproachedEventName: WithoutArgs(presence(events.VisitorApproachedEventName)),
+		events.VisitorLeftEventName:       WithoutArgs(presence(events.VisitorLeftEventName)),
+		events.TextInputEventName:         decodeTextInput,
+		events.LanguageSelectedEventName:  decodeLanguageSelected,
+	}
+}
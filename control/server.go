@@ -0,0 +1,305 @@
+package control
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+
+	"github.com/rmcsoft/hasp/events"
+)
+
+// Target is the running character controlled by the Server
+type Target interface {
+	CurrentState() string
+	Context() map[string]interface{}
+	InjectEvent(event *events.Event) error
+	ChangeAnimation(name string) error
+	PlayClip(name string) error
+	SubscribeTransitions() (<-chan events.Transition, func())
+}
+
+// ErrBusy is returned by the target refusing to play or say something
+// in the current state, e.g. while the character is listening
+var ErrBusy = errors.New("The character is busy")
+
+// Speaker is implemented by the targets speaking the text, e.g. with
+// a text-to-speech engine
+type Speaker interface {
+	Say(text string) error
+}
+
+// Server implements the local HTTP/WebSocket control API.
+//
+//	GET  /api/state        - current state and conversation context
+//	POST /api/events       - inject an event: {"name": "GoIdle", "args": []},
+//	                         only the registered events, see RegisterEvent
+//	POST /api/text         - send a text utterance: {"text": "I have a delivery"}
+//	POST /api/animation    - change the animation: {"name": "giggles"}
+//	POST /api/announcement - play a clip: {"name": "closing"}
+//	POST /api/say          - speak the text if the target is a Speaker: {"text": "We close in 10 minutes"}
+//	GET  /api/transitions  - WebSocket stream of FSM transitions
+//
+// Every request must carry the token either as "Authorization: Bearer <token>"
+// or as the "token" query parameter (for WebSocket clients).
+// An announcement or a speech refused by the busy target is 409 Conflict.
+type Server struct {
+	target   Target
+	token    string
+	mux      *http.ServeMux
+	decoders map[string]EventDecoder
+}
+
+// StateReply is the reply to GET /api/state
+type StateReply struct {
+	State   string                 `json:"state"`
+	Context map[string]interface{} `json:"context"`
+}
+
+// EventRequest is the body of POST /api/events
+type EventRequest struct {
+	Name string            `json:"name"`
+	Args []json.RawMessage `json:"args,omitempty"`
+}
+
+// TextRequest is the body of POST /api/text and POST /api/say
+type TextRequest struct {
+	Text string `json:"text"`
+}
+
+// NameRequest is the body of POST /api/animation and POST /api/announcement
+type NameRequest struct {
+	Name string `json:"name"`
+}
+
+// NewServer creates new Server
+func NewServer(target Target, token string) (*Server, error) {
+	if len(token) == 0 {
+		return nil, errors.New("The control API requires a token")
+	}
+
+	s := &Server{
+		target:   target,
+		token:    token,
+		mux:      http.NewServeMux(),
+		decoders: defaultEventDecoders(),
+	}
+	s.mux.HandleFunc("/api/state", s.handleState)
+	s.mux.HandleFunc("/api/events", s.handleEvents)
+	s.mux.HandleFunc("/api/text", s.handleText)
+	s.mux.HandleFunc("/api/animation", s.handleAnimation)
+	s.mux.HandleFunc("/api/announcement", s.handleAnnouncement)
+	s.mux.HandleFunc("/api/say", s.handleSay)
+	s.mux.Handle("/api/transitions", websocket.Server{Handler: s.streamTransitions})
+	return s, nil
+}
+
+// RegisterEvent lets POST /api/events inject the event, e.g. the events of
+// the sound package. The events of the events package that make sense to
+// inject are registered by NewServer.
+func (s *Server) RegisterEvent(name string, decoder EventDecoder) {
+	s.decoders[name] = decoder
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, StateReply{
+		State:   s.target.CurrentState(),
+		Context: jsonSafe(s.target.Context()),
+	})
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var req EventRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if len(req.Name) == 0 {
+		http.Error(w, "Event name is required", http.StatusBadRequest)
+		return
+	}
+
+	decoder, ok := s.decoders[req.Name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Event '%s' can't be injected", req.Name), http.StatusBadRequest)
+		return
+	}
+	event, err := decoder(req.Args)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Event '%s': %v", req.Name, err), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Control: inject event '%s'", req.Name)
+	if err := s.target.InjectEvent(event); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleText(w http.ResponseWriter, r *http.Request) {
+	var req TextRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if len(strings.TrimSpace(req.Text)) == 0 {
+		http.Error(w, "Text is required", http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("Control: text input '%s'", req.Text)
+	if err := s.target.InjectEvent(events.NewTextInputEvent(req.Text, "control")); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleAnimation(w http.ResponseWriter, r *http.Request) {
+	var req NameRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	log.Infof("Control: change animation to '%s'", req.Name)
+	if err := s.target.ChangeAnimation(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAnnouncement(w http.ResponseWriter, r *http.Request) {
+	var req NameRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	log.Infof("Control: play announcement '%s'", req.Name)
+	if err := s.target.PlayClip(req.Name); err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusNotFound))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleSay(w http.ResponseWriter, r *http.Request) {
+	var req TextRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	speaker, ok := s.target.(Speaker)
+	if !ok {
+		http.Error(w, "Text-to-speech is not available", http.StatusNotImplemented)
+		return
+	}
+
+	log.Infof("Control: say '%s'", req.Text)
+	if err := speaker.Say(req.Text); err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) streamTransitions(ws *websocket.Conn) {
+	defer ws.Close()
+
+	transitions, cancel := s.target.SubscribeTransitions()
+	defer cancel()
+
+	// The client is not expected to send anything; reading only detects closing.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}()
+
+	for {
+		select {
+		case transition, ok := <-transitions:
+			if !ok {
+				return
+			}
+			if err := websocket.JSON.Send(ws, transition); err != nil {
+				log.Debugf("Control: transition stream closed: %v", err)
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// errorStatus returns the HTTP status of the target error
+func errorStatus(err error, status int) int {
+	if err == ErrBusy {
+		return http.StatusConflict
+	}
+	return status
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Control: failed to write reply: %v", err)
+	}
+}
+
+// jsonSafe replaces the context values that can't be encoded to JSON
+// with their string representation
+func jsonSafe(ctx map[string]interface{}) map[string]interface{} {
+	safe := make(map[string]interface{}, len(ctx))
+	for k, v := range ctx {
+		if _, err := json.Marshal(v); err != nil {
+			safe[k] = fmt.Sprintf("%v", v)
+		} else {
+			safe[k] = v
+		}
+	}
+	return safe
+}
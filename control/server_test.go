@@ -0,0 +1,291 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/rmcsoft/hasp/events"
+)
+
+const testToken = "secret"
+
+type fakeTarget struct {
+	state       string
+	ctx         map[string]interface{}
+	injected    []*events.Event
+	transitions chan events.Transition
+	cancelled   chan struct{}
+	busy        bool
+}
+
+func newFakeTarget() *fakeTarget {
+	return &fakeTarget{
+		state:       "idle",
+		ctx:         map[string]interface{}{"UserId": "42", "Fn": func() {}},
+		transitions: make(chan events.Transition, 1),
+		cancelled:   make(chan struct{}),
+	}
+}
+
+func (t *fakeTarget) CurrentState() string              { return t.state }
+func (t *fakeTarget) Context() map[string]interface{}   { return t.ctx }
+func (t *fakeTarget) ChangeAnimation(name string) error { return nil }
+
+func (t *fakeTarget) PlayClip(name string) error {
+	if name != "closing" {
+		return errors.New("Unknown announcement")
+	}
+	if t.busy {
+		return ErrBusy
+	}
+	return nil
+}
+
+func (t *fakeTarget) InjectEvent(event *events.Event) error {
+	t.injected = append(t.injected, event)
+	return nil
+}
+
+func (t *fakeTarget) SubscribeTransitions() (<-chan events.Transition, func()) {
+	return t.transitions, func() { close(t.cancelled) }
+}
+
+func newTestServer(t *testing.T) (*Server, *fakeTarget) {
+	target := newFakeTarget()
+	server, err := NewServer(target, testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, target
+}
+
+func request(server http.Handler, method, url, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+func TestNewServerRequiresToken(t *testing.T) {
+	if _, err := NewServer(newFakeTarget(), ""); err == nil {
+		t.Error("Server without a token is created")
+	}
+}
+
+func TestToken(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		url    string
+		token  string
+		status int
+	}{
+		{"no token", "/api/state", "", http.StatusUnauthorized},
+		{"wrong token", "/api/state", "wrong", http.StatusUnauthorized},
+		{"bearer token", "/api/state", testToken, http.StatusOK},
+		{"query token", "/api/state?token=" + testToken, "", http.StatusOK},
+		{"wrong query token", "/api/state?token=wrong", "", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		w := request(server, http.MethodGet, test.url, test.token, "")
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.status)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	w := request(server, http.MethodGet, "/api/state", testToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status %d", w.Code)
+	}
+
+	var reply StateReply
+	if err := json.NewDecoder(w.Body).Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.State != "idle" {
+		t.Errorf("State '%s', want 'idle'", reply.State)
+	}
+	if reply.Context["UserId"] != "42" {
+		t.Errorf("UserId %v, want 42", reply.Context["UserId"])
+	}
+	if _, ok := reply.Context["Fn"].(string); !ok {
+		t.Errorf("The value not encoded to JSON is %v, want its string", reply.Context["Fn"])
+	}
+
+	if w := request(server, http.MethodPost, "/api/state", testToken, ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestInjectEvent(t *testing.T) {
+	server, target := newTestServer(t)
+	server.RegisterEvent("Stop", WithoutArgs(func() *events.Event {
+		return &events.Event{Name: "Stop", Args: []interface{}{"data"}}
+	}))
+
+	tests := []struct {
+		body   string
+		status int
+		name   string
+	}{
+		{`{"name": "GoIdle"}`, http.StatusAccepted, events.StateGoIdleName},
+		{`{"name": "GoIdle", "args": [1]}`, http.StatusBadRequest, ""},
+		{`{"name": "SoundCaptured"}`, http.StatusBadRequest, ""},
+		{`{"name": "SoundCaptured", "args": [{"AudioData": null}]}`, http.StatusBadRequest, ""},
+		{`{"name": ""}`, http.StatusBadRequest, ""},
+		{`{"name": `, http.StatusBadRequest, ""},
+		{`{"name": "TextInput", "args": [{"text": "I have a delivery"}]}`, http.StatusAccepted, events.TextInputEventName},
+		{`{"name": "TextInput", "args": [{"text": " "}]}`, http.StatusBadRequest, ""},
+		{`{"name": "TextInput", "args": ["I have a delivery"]}`, http.StatusBadRequest, ""},
+		{`{"name": "TextInput"}`, http.StatusBadRequest, ""},
+		{`{"name": "LanguageSelected", "args": [{"language": "es"}]}`, http.StatusAccepted, events.LanguageSelectedEventName},
+		{`{"name": "VisitorApproached"}`, http.StatusAccepted, events.VisitorApproachedEventName},
+		{`{"name": "Stop"}`, http.StatusAccepted, "Stop"},
+	}
+	for _, test := range tests {
+		target.injected = nil
+		w := request(server, http.MethodPost, "/api/events", testToken, test.body)
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.body, w.Code, test.status)
+			continue
+		}
+
+		if test.name == "" {
+			if len(target.injected) != 0 {
+				t.Errorf("%s: the event is injected", test.body)
+			}
+			continue
+		}
+		if len(target.injected) != 1 || target.injected[0].Name != test.name {
+			t.Errorf("%s: injected %v, want %s", test.body, target.injected, test.name)
+		}
+	}
+}
+
+func TestInjectedEventData(t *testing.T) {
+	server, target := newTestServer(t)
+
+	body := `{"name": "TextInput", "args": [{"text": "I have a delivery", "source": "kiosk"}]}`
+	if w := request(server, http.MethodPost, "/api/events", testToken, body); w.Code != http.StatusAccepted {
+		t.Fatalf("Status %d", w.Code)
+	}
+	data, err := events.GetTextInputEventData(target.injected[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Text != "I have a delivery" || data.Source != "kiosk" {
+		t.Errorf("Data %+v", data)
+	}
+
+	body = `{"name": "VisitorLeft"}`
+	if w := request(server, http.MethodPost, "/api/events", testToken, body); w.Code != http.StatusAccepted {
+		t.Fatalf("Status %d", w.Code)
+	}
+	if _, err := events.GetPresenceEventData(target.injected[1]); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestText(t *testing.T) {
+	server, target := newTestServer(t)
+
+	if w := request(server, http.MethodPost, "/api/text", testToken, `{"text": ""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Empty text status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := request(server, http.MethodPost, "/api/text", testToken, `{"text": "Hi"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("Status %d", w.Code)
+	}
+	data, err := events.GetTextInputEventData(target.injected[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Text != "Hi" || data.Source != "control" {
+		t.Errorf("Data %+v", data)
+	}
+}
+
+func TestAnnouncement(t *testing.T) {
+	server, target := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		busy   bool
+		status int
+	}{
+		{"closing", false, http.StatusAccepted},
+		{"opening", false, http.StatusNotFound},
+		// The announcement does not play over a conversation
+		{"closing", true, http.StatusConflict},
+	}
+	for _, test := range tests {
+		target.busy = test.busy
+		w := request(server, http.MethodPost, "/api/announcement", testToken, `{"name": "`+test.name+`"}`)
+		if w.Code != test.status {
+			t.Errorf("'%s' (busy %v): status %d, want %d", test.name, test.busy, w.Code, test.status)
+		}
+	}
+}
+
+func TestSayWithoutSpeaker(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	if w := request(server, http.MethodPost, "/api/say", testToken, `{"text": "Hi"}`); w.Code != http.StatusNotImplemented {
+		t.Errorf("Status %d, want %d", w.Code, http.StatusNotImplemented)
+	}
+}
+
+func TestTransitionStream(t *testing.T) {
+	server, target := newTestServer(t)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/transitions?token=" + testToken
+	ws, err := websocket.Dial(url, "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := events.Transition{Event: "GoIdle", Src: "goodbye", Dst: "idle", Time: time.Now()}
+	target.transitions <- sent
+
+	var received events.Transition
+	if err := websocket.JSON.Receive(ws, &received); err != nil {
+		t.Fatal(err)
+	}
+	if received.Event != sent.Event || received.Src != sent.Src || received.Dst != sent.Dst {
+		t.Errorf("Received %+v, want %+v", received, sent)
+	}
+
+	ws.Close()
+	select {
+	case <-target.cancelled:
+	case <-time.After(time.Second):
+		t.Error("The subscription is not cancelled when the client is gone")
+	}
+}
+
+func TestTransitionStreamRequiresToken(t *testing.T) {
+	server, _ := newTestServer(t)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/transitions"
+	if _, err := websocket.Dial(url, "", ts.URL); err == nil {
+		t.Error("Connected without the token")
+	}
+}
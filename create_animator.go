@@ -8,20 +8,24 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func isTransitFrameSeries(frameSeries chanim.FrameSeries) bool {
+func isTransitName(name string) bool {
 	// <AnimationName>_entry - transition frames to entry the animation
-	if strings.HasSuffix(frameSeries.Name, "_entry") {
+	if strings.HasSuffix(name, "_entry") {
 		return true
 	}
 
 	// <AnimationName>_exit  - transition frames to exit the animation
-	if strings.HasSuffix(frameSeries.Name, "_exit") {
+	if strings.HasSuffix(name, "_exit") {
 		return true
 	}
 
 	return false
 }
 
+func isTransitFrameSeries(frameSeries chanim.FrameSeries) bool {
+	return isTransitName(frameSeries.Name)
+}
+
 func isAnimationFrameSeries(frameSeries chanim.FrameSeries) bool {
 	return !isTransitFrameSeries(frameSeries)
 }
@@ -55,10 +59,10 @@ func getAnimationFrames(animation chanim.Animation, allFrameSeries []chanim.Fram
 	return nil
 }
 
-func createAnimations(allFrameSeries []chanim.FrameSeries) (chanim.Animations, error) {
+func createAnimations(allFrameSeries []chanim.FrameSeries, manifest AnimationManifest) (chanim.Animations, error) {
 	animations := make(chanim.Animations, 0)
 	for _, frameSeries := range allFrameSeries {
-		if isAnimationFrameSeries(frameSeries) {
+		if isAnimationFrameSeries(frameSeries) && !manifest.isAuxiliary(frameSeries.Name) {
 			if len(frameSeries.Frames) == 0 {
 				return nil, fmt.Errorf("Animation '%s' has no frame", frameSeries.Name)
 			}
@@ -100,9 +104,10 @@ func createTransitionBetween(from chanim.Animation, to chanim.Animation, allFram
 
 	// exitFrameSeries != nil && entryFrameSeries != nil
 	// transition requires a new series
+	frames := make([]chanim.Frame, 0, len(exitFrameSeries.Frames)+len(entryFrameSeries.Frames))
 	newFrameSeries := chanim.FrameSeries{
 		Name:   fmt.Sprintf("%s -> %s", from.Name, to.Name),
-		Frames: append(exitFrameSeries.Frames, entryFrameSeries.Frames...),
+		Frames: append(append(frames, exitFrameSeries.Frames...), entryFrameSeries.Frames...),
 	}
 	*allFrameSeries = append(*allFrameSeries, newFrameSeries)
 
@@ -125,38 +130,154 @@ func makeTransitionsFrom(from chanim.Animation, animations chanim.Animations, al
 	return transitions
 }
 
-func initTransitionFrames(animations chanim.Animations, allFrameSeries []chanim.FrameSeries) []chanim.FrameSeries {
+// initTransitionFrames attaches the transitions to every other animation to
+// the first and the last frames of the series. framePositions maps the frame
+// indexes of the series to the positions of the frames played, so the last
+// frame of a ping-pong animation is the turning one.
+func initTransitionFrames(animations chanim.Animations, allFrameSeries []chanim.FrameSeries,
+	manifest AnimationManifest, framePositions map[string][][]int) []chanim.FrameSeries {
+
 	for _, animation := range animations {
 		animationFrames := getAnimationFrames(animation, allFrameSeries)
+		positions := framePositions[animation.Name]
 		transitions := makeTransitionsFrom(animation, animations, &allFrameSeries)
 
-		// All animations have two transitional frames - the first and the last
-		firstTransitFrame := &animationFrames[0]
-		firstTransitFrame.Transitions = transitions
-
-		secondTransitFrame := &animationFrames[len(animationFrames)-1]
-		secondTransitFrame.Transitions = transitions
+		// The play-once animations are left only after the last frame.
+		for _, frame := range manifest.sourceFrames(TransitionSpec{}, animation.Name, len(positions)) {
+			for _, pos := range positions[frame] {
+				animationFrames[pos].Transitions = transitions
+			}
+		}
 	}
 
 	return allFrameSeries
 }
 
-// CreateAnimator creates an animator
+// initDeclaredTransitionFrames attaches the transitions declared by the manifest
+// to their source frames. framePositions maps the frame indexes of the series
+// to the positions of the frames played, see applyManifest.
+func initDeclaredTransitionFrames(animations chanim.Animations, allFrameSeries []chanim.FrameSeries,
+	manifest AnimationManifest, framePositions map[string][][]int) ([]chanim.FrameSeries, error) {
+
+	var missing []string
+	for _, from := range animations {
+		animationFrames := getAnimationFrames(from, allFrameSeries)
+		positions := framePositions[from.Name]
+		transitions := make(map[int][]chanim.Transition)
+
+		for _, to := range animations {
+			if from.Name == to.Name {
+				continue
+			}
+
+			spec, ok := manifest.transitionBetween(from.Name, to.Name)
+			if !ok {
+				missing = append(missing, fmt.Sprintf("'%s' -> '%s'", from.Name, to.Name))
+				continue
+			}
+
+			var transition chanim.Transition
+			switch {
+			case spec.Cut:
+				transition = chanim.Transition{DestAnimationName: to.Name}
+			case spec.Bridge != "":
+				transition = chanim.Transition{DestAnimationName: to.Name, FrameSeriesName: spec.Bridge}
+			default:
+				transition = createTransitionBetween(from, to, &allFrameSeries)
+			}
+
+			for _, frame := range manifest.sourceFrames(spec, from.Name, len(positions)) {
+				for _, pos := range positions[frame] {
+					transitions[pos] = append(transitions[pos], transition)
+				}
+			}
+		}
+
+		for pos, frameTransitions := range transitions {
+			animationFrames[pos].Transitions = frameTransitions
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Manifest: no transition %s", strings.Join(missing, ", "))
+	}
+	return allFrameSeries, nil
+}
+
+// applyManifest replaces the frames of the series with the frames played
+// according to the manifest. It returns the positions of the played frames
+// for every frame index of the series.
+func applyManifest(allFrameSeries []chanim.FrameSeries, manifest AnimationManifest) map[string][][]int {
+	framePositions := make(map[string][][]int, len(allFrameSeries))
+	for i := range allFrameSeries {
+		frameSeries := &allFrameSeries[i]
+		frameSeries.Frames, framePositions[frameSeries.Name] =
+			manifest.expandFrames(frameSeries.Name, frameSeries.Frames)
+	}
+	return framePositions
+}
+
+// prepareAnimations creates the animations and their transitions played
+// according to the manifest
+func prepareAnimations(allFrameSeries []chanim.FrameSeries,
+	manifest AnimationManifest) (chanim.Animations, []chanim.FrameSeries, error) {
+
+	if err := manifest.Validate(allFrameSeries); err != nil {
+		return nil, nil, err
+	}
+	framePositions := applyManifest(allFrameSeries, manifest)
+	applyLayers(allFrameSeries, manifest)
+
+	logrus.Debug("Creating animations")
+	animations, err := createAnimations(allFrameSeries, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logrus.Debug("Initializing transition frames")
+	if manifest.HasTransitions() {
+		allFrameSeries, err = initDeclaredTransitionFrames(animations, allFrameSeries, manifest, framePositions)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		allFrameSeries = initTransitionFrames(animations, allFrameSeries, manifest, framePositions)
+	}
+
+	return animations, allFrameSeries, nil
+}
+
+// CreateAnimator creates an animator.
+// The animations are played according to the manifest in the frameSeriesPath
+// if there is one.
 func CreateAnimator(paintEngine chanim.PaintEngine, frameSeriesPath string) (*chanim.Animator, error) {
+	manifest, err := LoadAnimationManifest(frameSeriesPath)
+	if err != nil {
+		return nil, err
+	}
+	return CreateAnimatorWithManifest(paintEngine, frameSeriesPath, manifest, nil)
+}
+
+// CreateAnimatorWithManifest creates an animator playing the animations
+// according to the manifest. The overlays of the manifest are defined in
+// overlays and drawn over every frame if overlays is not nil.
+func CreateAnimatorWithManifest(paintEngine chanim.PaintEngine, frameSeriesPath string,
+	manifest AnimationManifest, overlays *Overlays) (*chanim.Animator, error) {
+
 	logrus.Debug("Loading frames")
 	allFrameSeries, err := LoadFrameSeries(frameSeriesPath)
 	if err != nil {
 		return nil, err
 	}
 
-	logrus.Debug("Creating animations")
-	animations, err := createAnimations(allFrameSeries)
+	animations, allFrameSeries, err := prepareAnimations(allFrameSeries, manifest)
 	if err != nil {
 		return nil, err
 	}
 
-	logrus.Debug("Initializing transition frames")
-	allFrameSeries = initTransitionFrames(animations, allFrameSeries)
+	if overlays != nil {
+		applyOverlays(allFrameSeries, manifest, overlays)
+	}
 
 	logrus.Debug("Making animator")
 	return chanim.NewAnimator(paintEngine, animations, allFrameSeries)
@@ -0,0 +1,122 @@
+// Package evdev reads the Linux input devices, e.g. /dev/input/event0
+package evdev
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The Linux input event types and codes, see linux/input-event-codes.h
+const (
+	EvSyn = 0x00
+	EvKey = 0x01
+	EvAbs = 0x03
+
+	SynReport = 0x00
+
+	BtnTouch = 0x14a
+
+	AbsX            = 0x00
+	AbsY            = 0x01
+	AbsMtSlot       = 0x2f
+	AbsMtPositionX  = 0x35
+	AbsMtPositionY  = 0x36
+	AbsMtTrackingID = 0x39
+)
+
+// InputEvent is struct input_event of the Linux input subsystem
+type InputEvent struct {
+	Time  time.Time
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// EventReader reads the input events from an evdev device, e.g.
+// /dev/input/event0, or from a stream recorded from it
+type EventReader struct {
+	r        io.Reader
+	wordSize int
+	buf      []byte
+}
+
+// NewEventReader creates new EventReader reading the events in the layout
+// of the running kernel
+func NewEventReader(r io.Reader) *EventReader {
+	return NewEventReaderWithWordSize(r, strconv.IntSize/8)
+}
+
+// NewEventReaderWithWordSize creates new EventReader reading the events of
+// the machine with the word size, 4 or 8 bytes. The timestamp of the event is
+// two words, so the streams recorded on 32-bit and 64-bit machines differ.
+func NewEventReaderWithWordSize(r io.Reader, wordSize int) *EventReader {
+	return &EventReader{
+		r:        r,
+		wordSize: wordSize,
+		buf:      make([]byte, 2*wordSize+8),
+	}
+}
+
+// Read reads the next event
+func (r *EventReader) Read() (InputEvent, error) {
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return InputEvent{}, err
+	}
+
+	var sec, usec int64
+	if r.wordSize == 4 {
+		sec = int64(int32(binary.LittleEndian.Uint32(r.buf[0:])))
+		usec = int64(int32(binary.LittleEndian.Uint32(r.buf[4:])))
+	} else {
+		sec = int64(binary.LittleEndian.Uint64(r.buf[0:]))
+		usec = int64(binary.LittleEndian.Uint64(r.buf[8:]))
+	}
+	data := r.buf[2*r.wordSize:]
+	return InputEvent{
+		Time:  time.Unix(sec, usec*int64(time.Microsecond)),
+		Type:  binary.LittleEndian.Uint16(data[0:]),
+		Code:  binary.LittleEndian.Uint16(data[2:]),
+		Value: int32(binary.LittleEndian.Uint32(data[4:])),
+	}, nil
+}
+
+// absInfo is struct input_absinfo
+type absInfo struct {
+	Value      int32
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32
+	Flat       int32
+	Resolution int32
+}
+
+// ReadAbsRange gets the range of the axis of the device
+func ReadAbsRange(device *os.File, axis uint16) (int, int, error) {
+	var info absInfo
+	// EVIOCGABS(axis) = _IOR('E', 0x40 + axis, struct input_absinfo)
+	request := uintptr(2<<30 | unsafe.Sizeof(info)<<16 | 'E'<<8 | (0x40 + uintptr(axis)))
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, device.Fd(), request, uintptr(unsafe.Pointer(&info)))
+	if errno != 0 {
+		return 0, 0, fmt.Errorf("Failed to get the range of axis %d of %s: %v", axis, device.Name(), errno)
+	}
+	return int(info.Minimum), int(info.Maximum), nil
+}
+
+// Grab gets the exclusive access to the device, the other readers, e.g. the
+// console, no longer receive its events. The grab is released when the
+// device is closed.
+func Grab(device *os.File) error {
+	// EVIOCGRAB = _IOW('E', 0x90, int)
+	request := uintptr(1<<30 | 4<<16 | 'E'<<8 | 0x90)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, device.Fd(), request, 1)
+	if errno != 0 {
+		return fmt.Errorf("Failed to grab %s: %v", device.Name(), errno)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package evdev
+
+import (
+	"strings"
+)
+
+// The key codes of the keyboard, see linux/input-event-codes.h
+const (
+	KeyBackspace  = 14
+	KeyEnter      = 28
+	KeyLeftShift  = 42
+	KeyRightShift = 54
+	KeySpace      = 57
+	KeyCapsLock   = 58
+	KeyKPEnter    = 96
+)
+
+// keyValue* are the values of the EV_KEY events
+const (
+	keyValueRelease = 0
+	keyValuePress   = 1
+	keyValueRepeat  = 2
+)
+
+// usKeymap is the characters of the key codes from 2 of the US layout,
+// without and with shift; the space is a key without a character
+var usKeymap = [2]string{
+	"1234567890-=  qwertyuiop[]  asdfghjkl;'` \\zxcvbnm,./         ",
+	"!@#$%^&*()_+  QWERTYUIOP{}  ASDFGHJKL:\"~ |ZXCVBNM<>?         ",
+}
+
+const firstKeymapCode = 2
+
+// keyChar returns the character of the key of the US layout
+func keyChar(code uint16, shift bool) (byte, bool) {
+	if code == KeySpace {
+		return ' ', true
+	}
+	i := int(code) - firstKeymapCode
+	if i < 0 || i >= len(usKeymap[0]) {
+		return 0, false
+	}
+	layer := 0
+	if shift {
+		layer = 1
+	}
+	c := usKeymap[layer][i]
+	return c, c != ' '
+}
+
+// LineReader reads the lines typed on a keyboard device, e.g. the codes of
+// a USB barcode or QR scanner acting as a keyboard. The US layout is assumed.
+type LineReader struct {
+	reader   *EventReader
+	shift    int
+	capsLock bool
+	line     strings.Builder
+}
+
+// NewLineReader creates new LineReader
+func NewLineReader(reader *EventReader) *LineReader {
+	return &LineReader{reader: reader}
+}
+
+// ReadLine reads the keys until Enter and returns the typed line without it
+func (r *LineReader) ReadLine() (string, error) {
+	for {
+		event, err := r.reader.Read()
+		if err != nil {
+			return "", err
+		}
+		if event.Type != EvKey {
+			continue
+		}
+
+		switch event.Code {
+		case KeyLeftShift, KeyRightShift:
+			if event.Value == keyValuePress {
+				r.shift++
+			} else if event.Value == keyValueRelease && r.shift > 0 {
+				r.shift--
+			}
+			continue
+		}
+		if event.Value == keyValueRelease {
+			continue
+		}
+
+		switch event.Code {
+		case KeyEnter, KeyKPEnter:
+			line := r.line.String()
+			r.line.Reset()
+			return line, nil
+		case KeyCapsLock:
+			if event.Value == keyValuePress {
+				r.capsLock = !r.capsLock
+			}
+		case KeyBackspace:
+			line := r.line.String()
+			if len(line) > 0 {
+				r.line.Reset()
+				r.line.WriteString(line[:len(line)-1])
+			}
+		default:
+			shift := r.shift > 0
+			c, ok := keyChar(event.Code, shift)
+			if !ok {
+				continue
+			}
+			if r.capsLock && c >= 'a' && c <= 'z' {
+				c -= 'a' - 'A'
+			} else if r.capsLock && c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			r.line.WriteByte(c)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"errors"
+	"sync"
+)
+
+// ChanEventSource is an event source fed by Push calls.
+// Unlike the multiplexer itself, it is safe to push events from any goroutine.
+type ChanEventSource struct {
+	name      string
+	mutex     sync.Mutex
+	closed    bool
+	eventChan chan *Event
+}
+
+// NewChanEventSource creates new ChanEventSource
+func NewChanEventSource(name string, capacity int) *ChanEventSource {
+	return &ChanEventSource{
+		name:      name,
+		eventChan: make(chan *Event, capacity),
+	}
+}
+
+// Push queues the event. It does not block if the queue is full.
+func (es *ChanEventSource) Push(event *Event) error {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	if es.closed {
+		return errors.New("Event source is closed")
+	}
+
+	select {
+	case es.eventChan <- event:
+		return nil
+	default:
+		return errors.New("Event queue is full")
+	}
+}
+
+func (es *ChanEventSource) Name() string {
+	return es.name
+}
+
+func (es *ChanEventSource) Events() chan *Event {
+	return es.eventChan
+}
+
+func (es *ChanEventSource) Close() {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	if !es.closed {
+		es.closed = true
+		close(es.eventChan)
+	}
+}
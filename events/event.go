@@ -2,8 +2,13 @@ package events
 
 import (
 	log "github.com/sirupsen/logrus"
+
+	"github.com/rmcsoft/hasp/metrics"
 )
 
+var eventQueueDepth = metrics.NewGauge("hasp_event_queue_depth",
+	"Number of events waiting in the event source multiplexer")
+
 // Event is event Description
 type Event struct {
 	Name string
@@ -15,7 +20,6 @@ type Event struct {
 const StateChangedEventName = "StateChanged"
 const StateGoIdleName = "GoIdle"
 const StateWaitTimeoutName = "WaitTimeout"
-const StateFullHelpName = "FullHelp"
 
 // IDEventSource type to identify event sources
 type IDEventSource = uint64
@@ -50,6 +54,7 @@ func NewEventSourceMultiplexer() *EventSourceMultiplexer {
 func (esm *EventSourceMultiplexer) NextEvent() *Event {
 	for {
 		e, ok := <-esm.multiplexer // Get next event
+		eventQueueDepth.Set(float64(len(esm.multiplexer)))
 		if !ok {
 			return nil
 		}
@@ -98,6 +103,7 @@ func (esm *EventSourceMultiplexer) runEventSource(id IDEventSource, eventSource
 	log.Infof("EventSource '%s' running\n", eventSource.Name())
 	for e := range eventSource.Events() {
 		esm.multiplexer <- event{id, e}
+		eventQueueDepth.Set(float64(len(esm.multiplexer)))
 	}
 	log.Infof("EventSource '%s' stopped\n", eventSource.Name())
 }
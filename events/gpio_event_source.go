@@ -1,6 +1,8 @@
 package events
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -11,9 +13,55 @@ const (
 	GpioEventName = "GpioEvent"
 )
 
+// gpioPollPeriod is the sampling period used when the pin does not support
+// edge detection
+const gpioPollPeriod = 500 * time.Millisecond
+
+// GpioTransition is a timestamped level change of a single pin
+type GpioTransition struct {
+	Pin   string
+	Level gpio.Level
+	Time  time.Time
+}
+
+// GpioEventData is the GpioEvent data.
+// It describes the transition that made all the sensors HIGH.
+type GpioEventData GpioTransition
+
 type gpioEventSource struct {
 	eventChan   chan *Event
 	sensorsPins []gpio.PinIO
+
+	transitions chan GpioTransition
+	done        chan struct{}
+	closeOnce   sync.Once
+	watchers    sync.WaitGroup
+}
+
+// NewGpioEvent creates GpioEvent
+func NewGpioEvent(transition GpioTransition) *Event {
+	return &Event{
+		Name: GpioEventName,
+		Args: []interface{}{GpioEventData(transition)},
+	}
+}
+
+// GetGpioEventData gets GpioEvent data
+func GetGpioEventData(event *Event) (*GpioEventData, error) {
+	if event.Name != GpioEventName {
+		return nil, fmt.Errorf("The event must be named %s", GpioEventName)
+	}
+
+	if len(event.Args) != 1 {
+		return nil, fmt.Errorf("Event does not contain data")
+	}
+
+	data, ok := event.Args[0].(GpioEventData)
+	if !ok {
+		return nil, fmt.Errorf("Invalid event data type")
+	}
+
+	return &data, nil
 }
 
 func CheckAllPins(sensorsPins []gpio.PinIO) bool {
@@ -30,11 +78,15 @@ func CheckAllPins(sensorsPins []gpio.PinIO) bool {
 	return true
 }
 
-// NewSingleEventSource creates new gpioEventSource
+// NewGpioEventSource creates new gpioEventSource.
+// The source fires GpioEvent once all the sensors are HIGH. Pins are watched
+// with edge detection, pins without edge support are polled.
 func NewGpioEventSource(sensorsPins []gpio.PinIO) EventSource {
 	es := &gpioEventSource{
 		eventChan:   make(chan *Event),
 		sensorsPins: sensorsPins,
+		transitions: make(chan GpioTransition),
+		done:        make(chan struct{}),
 	}
 
 	if len(es.sensorsPins) > 0 {
@@ -53,17 +105,164 @@ func (es *gpioEventSource) Events() chan *Event {
 }
 
 func (es *gpioEventSource) Close() {
+	es.closeOnce.Do(func() {
+		close(es.done)
+		// Halt wakes up the watchers waiting for an edge
+		for _, pin := range es.sensorsPins {
+			if err := pin.Halt(); err != nil {
+				logrus.Debugf("GPIO %s: %v", pin.Name(), err)
+			}
+		}
+	})
 }
 
 func (es *gpioEventSource) run() {
 	defer close(es.eventChan)
 
-	t := time.NewTicker(500 * time.Millisecond)
+	levels := make([]gpio.Level, len(es.sensorsPins))
+	for i, pin := range es.sensorsPins {
+		levels[i] = es.startWatcher(pin)
+	}
+	defer es.watchers.Wait()
+	defer es.Close()
+
+	if allHigh(levels) {
+		es.send(NewGpioEvent(GpioTransition{
+			Pin:   es.sensorsPins[len(es.sensorsPins)-1].Name(),
+			Level: gpio.High,
+			Time:  time.Now(),
+		}))
+		return
+	}
+
 	for {
-		if CheckAllPins(es.sensorsPins) {
-			es.eventChan <- &Event{Name: GpioEventName}
+		select {
+		case <-es.done:
 			return
+		case tr := <-es.transitions:
+			for i, pin := range es.sensorsPins {
+				if pin.Name() == tr.Pin {
+					levels[i] = tr.Level
+				}
+			}
+			if allHigh(levels) {
+				logrus.Trace("GPIO: ALL HIGH")
+				es.send(NewGpioEvent(tr))
+				return
+			}
 		}
-		<-t.C
 	}
 }
+
+// startWatcher starts watching the pin and returns its current level
+func (es *gpioEventSource) startWatcher(pin gpio.PinIO) gpio.Level {
+	useEdges := true
+	if err := pin.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		logrus.Debugf("GPIO %s: edge detection is unavailable, polling: %v", pin.Name(), err)
+		useEdges = false
+	}
+
+	level := pin.Read()
+	es.watchers.Add(1)
+	go func() {
+		defer es.watchers.Done()
+		if useEdges {
+			es.watchEdges(pin, level)
+		} else {
+			es.poll(pin, level)
+		}
+	}()
+	return level
+}
+
+func (es *gpioEventSource) watchEdges(pin gpio.PinIO, level gpio.Level) {
+	for {
+		select {
+		case <-es.done:
+			return
+		default:
+		}
+
+		if !pin.WaitForEdge(-1) {
+			select {
+			case <-es.done:
+				return
+			default:
+			}
+			// The pin can't wait for the edges anymore, e.g. it is halted
+			logrus.Debugf("GPIO %s: waiting for an edge failed, polling", pin.Name())
+			es.poll(pin, level)
+			return
+		}
+		now := edgeTime(pin, time.Now())
+		if newLevel := pin.Read(); newLevel != level {
+			level = newLevel
+			if !es.notify(GpioTransition{Pin: pin.Name(), Level: level, Time: now}) {
+				return
+			}
+		}
+	}
+}
+
+func (es *gpioEventSource) poll(pin gpio.PinIO, level gpio.Level) {
+	t := time.NewTicker(gpioPollPeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-es.done:
+			return
+		case now := <-t.C:
+			if newLevel := pin.Read(); newLevel != level {
+				level = newLevel
+				if !es.notify(GpioTransition{Pin: pin.Name(), Level: level, Time: now}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (es *gpioEventSource) notify(tr GpioTransition) bool {
+	logrus.Tracef("GPIO: %s is %v at %s", tr.Pin, tr.Level, tr.Time.Format(time.StampMicro))
+	select {
+	case es.transitions <- tr:
+		return true
+	case <-es.done:
+		return false
+	}
+}
+
+func (es *gpioEventSource) send(event *Event) {
+	select {
+	case es.eventChan <- event:
+	case <-es.done:
+	}
+}
+
+// edgeTimestamper is implemented by the pins timestamping the edges,
+// e.g. by the kernel
+type edgeTimestamper interface {
+	LastEdgeTime() time.Time
+}
+
+func edgeTime(pin gpio.PinIO, fallback time.Time) time.Time {
+	if alias, ok := pin.(gpio.RealPin); ok {
+		pin = alias.Real()
+	}
+	if ts, ok := pin.(edgeTimestamper); ok {
+		if t := ts.LastEdgeTime(); !t.IsZero() {
+			return t
+		}
+	}
+	return fallback
+}
+
+func allHigh(levels []gpio.Level) bool {
+	for _, level := range levels {
+		if level == gpio.Low {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+)
+
+// edgePin is a pin with edge detection whose WaitForEdge is woken up by Halt
+type edgePin struct {
+	gpiotest.Pin
+	halt chan struct{}
+}
+
+func newEdgePin(name string, level gpio.Level) *edgePin {
+	return &edgePin{
+		Pin:  gpiotest.Pin{N: name, L: level, EdgesChan: make(chan gpio.Level)},
+		halt: make(chan struct{}, 1),
+	}
+}
+
+// In doesn't flush the edges unlike gpiotest.Pin, the edges are sent by the
+// test only when they are waited for
+func (p *edgePin) In(pull gpio.Pull, edge gpio.Edge) error {
+	return nil
+}
+
+func (p *edgePin) Halt() error {
+	select {
+	case p.halt <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (p *edgePin) WaitForEdge(timeout time.Duration) bool {
+	if timeout != -1 {
+		return p.Pin.WaitForEdge(timeout)
+	}
+	select {
+	case l := <-p.EdgesChan:
+		_ = p.Out(l)
+		return true
+	case <-p.halt:
+		return false
+	}
+}
+
+func receive(t *testing.T, es EventSource) *Event {
+	select {
+	case event := <-es.Events():
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("No event")
+	}
+	return nil
+}
+
+func TestGpioEventSourceAllHigh(t *testing.T) {
+	pins := []*edgePin{newEdgePin("A", gpio.Low), newEdgePin("B", gpio.Low)}
+	es := NewGpioEventSource([]gpio.PinIO{pins[0], pins[1]})
+	defer es.Close()
+
+	pins[0].EdgesChan <- gpio.High
+	select {
+	case event := <-es.Events():
+		t.Fatalf("Event %v while B is Low", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pins[1].EdgesChan <- gpio.High
+	data, err := GetGpioEventData(receive(t, es))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Level != gpio.High {
+		t.Errorf("Level %v, want High", data.Level)
+	}
+}
+
+func TestGpioEventSourceStartsHigh(t *testing.T) {
+	pin := newEdgePin("A", gpio.High)
+	es := NewGpioEventSource([]gpio.PinIO{pin})
+	defer es.Close()
+
+	if _, err := GetGpioEventData(receive(t, es)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGpioEventSourceClose(t *testing.T) {
+	pin := newEdgePin("A", gpio.Low)
+	es := NewGpioEventSource([]gpio.PinIO{pin})
+	// Let the watcher block in WaitForEdge
+	time.Sleep(50 * time.Millisecond)
+	es.Close()
+
+	select {
+	case _, ok := <-es.Events():
+		if ok {
+			t.Error("Event after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The watcher waiting for an edge is not stopped by Close")
+	}
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/i2c"
+)
+
+// I2CDistanceParams describes how the distance is read from the sensor.
+// The defaults are for the Benewake TF-Luna time-of-flight sensor.
+type I2CDistanceParams struct {
+	Addr uint16
+	// Register is the first register of the distance value
+	Register byte
+	// BigEndian is set if the high byte of the distance comes first
+	BigEndian bool
+	// Unit is the distance unit in meters
+	Unit float64
+	// MaxRange is the maximum measurable distance in meters, the object is
+	// not present beyond it
+	MaxRange float64
+}
+
+// DefaultI2CDistanceParams are the params of TF-Luna
+var DefaultI2CDistanceParams = I2CDistanceParams{
+	Addr:     0x10,
+	Register: 0x00,
+	Unit:     0.01,
+	MaxRange: 8,
+}
+
+// I2CDistanceSensor is a time-of-flight distance sensor on the I2C bus
+type I2CDistanceSensor struct {
+	dev    i2c.Dev
+	params I2CDistanceParams
+}
+
+// NewI2CDistanceSensor creates new I2CDistanceSensor
+func NewI2CDistanceSensor(bus i2c.Bus, params I2CDistanceParams) *I2CDistanceSensor {
+	if params.Unit <= 0 {
+		params.Unit = DefaultI2CDistanceParams.Unit
+	}
+	return &I2CDistanceSensor{
+		dev:    i2c.Dev{Bus: bus, Addr: params.Addr},
+		params: params,
+	}
+}
+
+// Name implements PresenceSensor
+func (s *I2CDistanceSensor) Name() string {
+	return fmt.Sprintf("i2c-distance(%s)", s.dev.String())
+}
+
+// Read implements PresenceSensor
+func (s *I2CDistanceSensor) Read() (SensorReading, error) {
+	var buf [2]byte
+	if err := s.dev.Tx([]byte{s.params.Register}, buf[:]); err != nil {
+		return SensorReading{}, err
+	}
+
+	var raw uint16
+	if s.params.BigEndian {
+		raw = binary.BigEndian.Uint16(buf[:])
+	} else {
+		raw = binary.LittleEndian.Uint16(buf[:])
+	}
+
+	distance := float64(raw) * s.params.Unit
+	present := raw > 0 && (s.params.MaxRange <= 0 || distance <= s.params.MaxRange)
+	return SensorReading{
+		Present:  present,
+		Distance: distance,
+		Time:     time.Now(),
+	}, nil
+}
+
+// Close implements PresenceSensor.
+// The bus is owned by the caller.
+func (s *I2CDistanceSensor) Close() error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"fmt"
+)
+
+const (
+	LanguageSelectedEventName = "LanguageSelected"
+)
+
+// LanguageSelectedEventData is the LanguageSelected event data
+type LanguageSelectedEventData struct {
+	// Language is the code of the conversation language, e.g. "es"
+	Language string
+}
+
+// NewLanguageSelectedEvent creates LanguageSelectedEvent
+func NewLanguageSelectedEvent(language string) *Event {
+	return &Event{
+		Name: LanguageSelectedEventName,
+		Args: []interface{}{LanguageSelectedEventData{Language: language}},
+	}
+}
+
+// GetLanguageSelectedEventData gets LanguageSelectedEvent data
+func GetLanguageSelectedEventData(event *Event) (LanguageSelectedEventData, error) {
+	if event.Name != LanguageSelectedEventName {
+		return LanguageSelectedEventData{}, fmt.Errorf("The event must be named %s", LanguageSelectedEventName)
+	}
+
+	if len(event.Args) != 1 {
+		return LanguageSelectedEventData{}, fmt.Errorf("Event does not contain data")
+	}
+
+	data, ok := event.Args[0].(LanguageSelectedEventData)
+	if !ok {
+		return LanguageSelectedEventData{}, fmt.Errorf("Invalid event data type")
+	}
+
+	return data, nil
+}
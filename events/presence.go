@@ -0,0 +1,406 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"periph.io/x/periph/conn/gpio"
+)
+
+const (
+	// VisitorApproachedEventName is emitted when a visitor stops in front of the character
+	VisitorApproachedEventName = "VisitorApproached"
+	// VisitorLeftEventName is emitted when the visitor who approached leaves
+	VisitorLeftEventName = "VisitorLeft"
+	// PassedByEventName is emitted when somebody walks by without stopping
+	PassedByEventName = "PassedBy"
+)
+
+// Direction is the direction of movement in front of the sensors
+type Direction int
+
+const (
+	// DirectionUnknown means both sensors triggered at once or only one did
+	DirectionUnknown Direction = iota
+	// LeftToRight means the left sensor triggered first
+	LeftToRight
+	// RightToLeft means the right sensor triggered first
+	RightToLeft
+)
+
+func (d Direction) String() string {
+	switch d {
+	case LeftToRight:
+		return "left-to-right"
+	case RightToLeft:
+		return "right-to-left"
+	default:
+		return "unknown"
+	}
+}
+
+// PresenceEventData is the data of presence events
+type PresenceEventData struct {
+	Direction Direction
+	// Duration is how long the sensors were active
+	Duration time.Duration
+	// Distance to the visitor in meters, NoDistance if the sensors don't
+	// measure distance
+	Distance float64
+}
+
+// NewPresenceEvent creates one of the presence events
+func NewPresenceEvent(name string, direction Direction, duration time.Duration) *Event {
+	return &Event{
+		Name: name,
+		Args: []interface{}{
+			PresenceEventData{direction, duration, NoDistance},
+		},
+	}
+}
+
+// PresenceDetector detects the visitors in front of the character
+type PresenceDetector interface {
+	// Present reports whether a visitor has stopped in front of the character
+	Present() bool
+	// Rearm announces the visitor who is still there again: VisitorApproached
+	// is emitted once more after the hold time. It is called when the
+	// character returns to idle, the first event may have been dropped by
+	// a busy character.
+	Rearm()
+	// EventSource creates an event source emitting the presence events
+	// until it is closed
+	EventSource() EventSource
+	// Close stops the detection
+	Close()
+}
+
+// GetPresenceEventData gets the presence event data
+func GetPresenceEventData(event *Event) (PresenceEventData, error) {
+	if event.Name != VisitorApproachedEventName &&
+		event.Name != VisitorLeftEventName &&
+		event.Name != PassedByEventName {
+		return PresenceEventData{},
+			fmt.Errorf("The event must be named %s, %s or %s",
+				VisitorApproachedEventName, VisitorLeftEventName, PassedByEventName)
+	}
+
+	if len(event.Args) != 1 {
+		return PresenceEventData{},
+			errors.New("Event does not data")
+	}
+
+	data, ok := event.Args[0].(PresenceEventData)
+	if !ok {
+		return PresenceEventData{},
+			errors.New("Event does not contain presence data")
+	}
+
+	return data, nil
+}
+
+// PresenceParams configures the presence detection
+type PresenceParams struct {
+	// PollPeriod is the sensor sampling period
+	PollPeriod time.Duration
+	// Debounce is how long a sensor level must be stable to be accepted
+	Debounce time.Duration
+	// HoldTime is how long all sensors must be active to consider
+	// that the visitor stopped in front of the character
+	HoldTime time.Duration
+	// ReleaseTime is how long all sensors must be inactive
+	// to consider that the visitor left
+	ReleaseTime time.Duration
+}
+
+// DefaultPresenceParams are reasonable parameters for PIR/IR proximity sensors
+var DefaultPresenceParams = PresenceParams{
+	PollPeriod:  50 * time.Millisecond,
+	Debounce:    150 * time.Millisecond,
+	HoldTime:    1500 * time.Millisecond,
+	ReleaseTime: 3 * time.Second,
+}
+
+type debouncedLevel struct {
+	stable    bool
+	candidate bool
+	since     time.Time
+}
+
+func (l *debouncedLevel) update(now time.Time, active bool, debounce time.Duration) bool {
+	if active != l.candidate {
+		l.candidate = active
+		l.since = now
+	}
+	if l.candidate != l.stable && now.Sub(l.since) >= debounce {
+		l.stable = l.candidate
+	}
+	return l.stable
+}
+
+type presencePhase int
+
+const (
+	phaseAbsent presencePhase = iota
+	// Somebody is in front of the sensors but has not stopped yet
+	phaseEntering
+	// The visitor has stopped in front of the character
+	phasePresent
+)
+
+// presenceTracker turns the samples of the left and right sensors into presence events
+type presenceTracker struct {
+	params PresenceParams
+
+	left, right debouncedLevel
+	phase       presencePhase
+
+	activeSince   time.Time // first activity of the episode
+	allSince      time.Time // all sensors active since, zero if not
+	inactiveSince time.Time // all sensors inactive since, zero if not
+
+	firstSide    Direction
+	leftCleared  bool // the left sensor went inactive while the right one was active
+	rightCleared bool // the right sensor went inactive while the left one was active
+}
+
+func (t *presenceTracker) direction() Direction {
+	switch {
+	case t.firstSide == LeftToRight && t.leftCleared:
+		return LeftToRight
+	case t.firstSide == RightToLeft && t.rightCleared:
+		return RightToLeft
+	default:
+		return t.firstSide
+	}
+}
+
+// rearm makes the present visitor enter again, VisitorApproached is
+// returned once all the sensors stay active for the hold time from now
+func (t *presenceTracker) rearm(now time.Time) {
+	if t.phase != phasePresent {
+		return
+	}
+	t.phase = phaseEntering
+	t.activeSince = now
+	if !t.allSince.IsZero() {
+		t.allSince = now
+	}
+}
+
+func (t *presenceTracker) update(now time.Time, leftActive, rightActive bool) *Event {
+	prevLeft, prevRight := t.left.stable, t.right.stable
+	left := t.left.update(now, leftActive, t.params.Debounce)
+	right := t.right.update(now, rightActive, t.params.Debounce)
+
+	if left && right {
+		if t.allSince.IsZero() {
+			t.allSince = now
+		}
+	} else {
+		t.allSince = time.Time{}
+	}
+
+	if left || right {
+		t.inactiveSince = time.Time{}
+	} else if t.inactiveSince.IsZero() {
+		t.inactiveSince = now
+	}
+
+	switch t.phase {
+	case phaseAbsent:
+		if !left && !right {
+			return nil
+		}
+		t.phase = phaseEntering
+		t.activeSince = now
+		t.leftCleared, t.rightCleared = false, false
+		switch {
+		case left && !right:
+			t.firstSide = LeftToRight
+		case right && !left:
+			t.firstSide = RightToLeft
+		default:
+			t.firstSide = DirectionUnknown
+		}
+		return nil
+
+	case phaseEntering:
+		if prevLeft && !left && right {
+			t.leftCleared = true
+		}
+		if prevRight && !right && left {
+			t.rightCleared = true
+		}
+
+		if !t.allSince.IsZero() && now.Sub(t.allSince) >= t.params.HoldTime {
+			t.phase = phasePresent
+			return NewPresenceEvent(VisitorApproachedEventName, t.firstSide, now.Sub(t.activeSince))
+		}
+		if !t.inactiveSince.IsZero() && now.Sub(t.inactiveSince) >= t.params.ReleaseTime {
+			t.phase = phaseAbsent
+			return NewPresenceEvent(PassedByEventName, t.direction(), t.inactiveSince.Sub(t.activeSince))
+		}
+		return nil
+
+	case phasePresent:
+		if !t.inactiveSince.IsZero() && now.Sub(t.inactiveSince) >= t.params.ReleaseTime {
+			t.phase = phaseAbsent
+			return NewPresenceEvent(VisitorLeftEventName, t.firstSide, t.inactiveSince.Sub(t.activeSince))
+		}
+		return nil
+	}
+	return nil
+}
+
+// PresenceMonitor watches the left and right sensors and emits presence events.
+// It keeps tracking between states, the states get the events through
+// the sources created by EventSource.
+type PresenceMonitor struct {
+	presenceBroadcaster
+
+	left, right gpio.PinIO
+	quit        chan struct{}
+
+	mutex   sync.Mutex
+	tracker presenceTracker
+}
+
+// NewPresenceMonitor creates new PresenceMonitor and starts sampling the pins
+func NewPresenceMonitor(left, right gpio.PinIO, params PresenceParams) *PresenceMonitor {
+	if params.PollPeriod <= 0 {
+		params.PollPeriod = DefaultPresenceParams.PollPeriod
+	}
+
+	m := &PresenceMonitor{
+		presenceBroadcaster: newPresenceBroadcaster(),
+		left:                left,
+		right:               right,
+		quit:                make(chan struct{}),
+		tracker:             presenceTracker{params: params},
+	}
+	go m.run()
+	return m
+}
+
+// Present reports whether a visitor has stopped in front of the character
+func (m *PresenceMonitor) Present() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.tracker.phase == phasePresent
+}
+
+// Rearm implements PresenceDetector
+func (m *PresenceMonitor) Rearm() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tracker.rearm(time.Now())
+}
+
+// Close stops sampling the pins
+func (m *PresenceMonitor) Close() {
+	close(m.quit)
+}
+
+func (m *PresenceMonitor) run() {
+	t := time.NewTicker(m.tracker.params.PollPeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case now := <-t.C:
+			m.sample(now)
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+func (m *PresenceMonitor) sample(now time.Time) {
+	leftActive := m.left != nil && m.left.Read() == gpio.High
+	rightActive := m.right != nil && m.right.Read() == gpio.High
+	m.update(now, leftActive, rightActive)
+}
+
+func (m *PresenceMonitor) update(now time.Time, leftActive, rightActive bool) {
+	m.mutex.Lock()
+	event := m.tracker.update(now, leftActive, rightActive)
+	m.mutex.Unlock()
+
+	if event != nil {
+		m.broadcast(event)
+	}
+}
+
+// presenceBroadcaster delivers the presence events to the event sources
+type presenceBroadcaster struct {
+	sourcesMutex sync.Mutex
+	sources      map[*presenceEventSource]struct{}
+}
+
+func newPresenceBroadcaster() presenceBroadcaster {
+	return presenceBroadcaster{
+		sources: make(map[*presenceEventSource]struct{}),
+	}
+}
+
+// EventSource creates an event source emitting the presence events
+// until it is closed
+func (b *presenceBroadcaster) EventSource() EventSource {
+	es := &presenceEventSource{
+		broadcaster: b,
+		eventChan:   make(chan *Event, 4),
+	}
+
+	b.sourcesMutex.Lock()
+	b.sources[es] = struct{}{}
+	b.sourcesMutex.Unlock()
+	return es
+}
+
+func (b *presenceBroadcaster) broadcast(event *Event) {
+	if data, err := GetPresenceEventData(event); err == nil {
+		logrus.Debugf("Presence: %s, direction=%v, duration=%v, distance=%.2f",
+			event.Name, data.Direction, data.Duration, data.Distance)
+	}
+
+	b.sourcesMutex.Lock()
+	defer b.sourcesMutex.Unlock()
+	for es := range b.sources {
+		select {
+		case es.eventChan <- event:
+		default:
+			logrus.Warnf("Presence: event %s dropped", event.Name)
+		}
+	}
+}
+
+func (b *presenceBroadcaster) removeSource(es *presenceEventSource) {
+	b.sourcesMutex.Lock()
+	defer b.sourcesMutex.Unlock()
+
+	if _, ok := b.sources[es]; ok {
+		delete(b.sources, es)
+		close(es.eventChan)
+	}
+}
+
+type presenceEventSource struct {
+	broadcaster *presenceBroadcaster
+	eventChan   chan *Event
+}
+
+func (es *presenceEventSource) Name() string {
+	return "PresenceEventSource"
+}
+
+func (es *presenceEventSource) Events() chan *Event {
+	return es.eventChan
+}
+
+func (es *presenceEventSource) Close() {
+	es.broadcaster.removeSource(es)
+}
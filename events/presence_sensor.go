@@ -0,0 +1,65 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// NoDistance is the distance of the readings of sensors which don't measure
+// distance
+const NoDistance = -1.0
+
+// SensorReading is a sample of a presence sensor
+type SensorReading struct {
+	// Present is set if the sensor detects somebody
+	Present bool
+	// Distance to the nearest object in meters, NoDistance if unknown
+	Distance float64
+	Time     time.Time
+}
+
+// PresenceSensor is a sensor detecting the visitors, e.g. a PIR sensor,
+// a distance sensor or a camera
+type PresenceSensor interface {
+	Name() string
+	// Read returns the current reading
+	Read() (SensorReading, error)
+	Close() error
+}
+
+// GpioPresenceSensor is the digital sensors connected to the pins.
+// Somebody is present when all the pins are HIGH.
+type GpioPresenceSensor struct {
+	pins []gpio.PinIO
+}
+
+// NewGpioPresenceSensor creates new GpioPresenceSensor
+func NewGpioPresenceSensor(pins ...gpio.PinIO) *GpioPresenceSensor {
+	return &GpioPresenceSensor{pins: pins}
+}
+
+// Name implements PresenceSensor
+func (s *GpioPresenceSensor) Name() string {
+	names := make([]string, len(s.pins))
+	for i, pin := range s.pins {
+		names[i] = pin.Name()
+	}
+	return fmt.Sprintf("gpio(%s)", strings.Join(names, ","))
+}
+
+// Read implements PresenceSensor
+func (s *GpioPresenceSensor) Read() (SensorReading, error) {
+	return SensorReading{
+		Present:  CheckAllPins(s.pins),
+		Distance: NoDistance,
+		Time:     time.Now(),
+	}, nil
+}
+
+// Close implements PresenceSensor
+func (s *GpioPresenceSensor) Close() error {
+	return nil
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+)
+
+func TestGpioPresenceSensor(t *testing.T) {
+	left := &gpiotest.Pin{N: "left"}
+	right := &gpiotest.Pin{N: "right"}
+	sensor := NewGpioPresenceSensor(left, right)
+
+	if name := sensor.Name(); name != "gpio(left,right)" {
+		t.Errorf("Name '%s'", name)
+	}
+
+	tests := []struct {
+		left, right gpio.Level
+		present     bool
+	}{
+		{gpio.Low, gpio.Low, false},
+		{gpio.High, gpio.Low, false},
+		{gpio.Low, gpio.High, false},
+		{gpio.High, gpio.High, true},
+	}
+	for _, test := range tests {
+		left.Out(test.left)
+		right.Out(test.right)
+		reading, err := sensor.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reading.Present != test.present || reading.Distance != NoDistance {
+			t.Errorf("Left %v, right %v: reading %+v, want present %v",
+				test.left, test.right, reading, test.present)
+		}
+	}
+}
+
+// The digital sensors don't measure the distance, the visitor is near
+// whatever MaxDistance is
+func TestDistanceTrackerWithoutDistance(t *testing.T) {
+	tracker := distanceTracker{params: DefaultSensorPresenceParams}
+	now := time.Unix(0, 0)
+
+	var names []string
+	for _, present := range []bool{true, true, true, false, false, false, false} {
+		event := tracker.update(now, SensorReading{Present: present, Distance: NoDistance})
+		if event != nil {
+			names = append(names, event.Name)
+		}
+		now = now.Add(time.Second)
+	}
+	if len(names) != 2 || names[0] != VisitorApproachedEventName || names[1] != VisitorLeftEventName {
+		t.Errorf("Events %v, want [%s %s]", names, VisitorApproachedEventName, VisitorLeftEventName)
+	}
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+var testPresenceParams = PresenceParams{
+	Debounce:    100 * time.Millisecond,
+	HoldTime:    time.Second,
+	ReleaseTime: time.Second,
+}
+
+// feed samples both sensors at the level every 50ms for the duration and
+// returns the names of the events
+func feed(t *presenceTracker, now *time.Time, active bool, duration time.Duration) []string {
+	var names []string
+	for end := now.Add(duration); now.Before(end); *now = now.Add(50 * time.Millisecond) {
+		if event := t.update(*now, active, active); event != nil {
+			names = append(names, event.Name)
+		}
+	}
+	return names
+}
+
+func TestPresenceTrackerApproachesOnce(t *testing.T) {
+	tracker := presenceTracker{params: testPresenceParams}
+	now := time.Unix(0, 0)
+
+	if names := feed(&tracker, &now, true, 3*time.Second); len(names) != 1 || names[0] != VisitorApproachedEventName {
+		t.Fatalf("Events %v, want [%s]", names, VisitorApproachedEventName)
+	}
+	if names := feed(&tracker, &now, true, 3*time.Second); len(names) != 0 {
+		t.Errorf("Events %v of the visitor standing still", names)
+	}
+}
+
+func TestPresenceTrackerRearm(t *testing.T) {
+	tracker := presenceTracker{params: testPresenceParams}
+	now := time.Unix(0, 0)
+
+	feed(&tracker, &now, true, 3*time.Second)
+	tracker.rearm(now)
+	if tracker.phase == phasePresent {
+		t.Fatal("The visitor is still present after rearm")
+	}
+
+	names := feed(&tracker, &now, true, 500*time.Millisecond)
+	if len(names) != 0 {
+		t.Errorf("Events %v before the hold time", names)
+	}
+	names = feed(&tracker, &now, true, time.Second)
+	if len(names) != 1 || names[0] != VisitorApproachedEventName {
+		t.Errorf("Events %v, want [%s]", names, VisitorApproachedEventName)
+	}
+
+	names = feed(&tracker, &now, false, 2*time.Second)
+	if len(names) != 1 || names[0] != VisitorLeftEventName {
+		t.Errorf("Events %v, want [%s]", names, VisitorLeftEventName)
+	}
+}
+
+func TestPresenceTrackerRearmWithoutVisitor(t *testing.T) {
+	tracker := presenceTracker{params: testPresenceParams}
+	now := time.Unix(0, 0)
+
+	tracker.rearm(now)
+	if names := feed(&tracker, &now, false, 3*time.Second); len(names) != 0 {
+		t.Errorf("Events %v without a visitor", names)
+	}
+}
+
+func TestDistanceTrackerRearm(t *testing.T) {
+	tracker := distanceTracker{params: SensorPresenceParams{
+		MaxDistance: 1.2,
+		HoldTime:    time.Second,
+		ReleaseTime: time.Second,
+	}}
+	now := time.Unix(0, 0)
+	near := SensorReading{Present: true, Distance: 0.8}
+	far := SensorReading{Present: true, Distance: 3}
+
+	feed := func(reading SensorReading, duration time.Duration) []string {
+		var names []string
+		for end := now.Add(duration); now.Before(end); now = now.Add(100 * time.Millisecond) {
+			if event := tracker.update(now, reading); event != nil {
+				names = append(names, event.Name)
+			}
+		}
+		return names
+	}
+
+	if names := feed(near, 2*time.Second); len(names) != 1 || names[0] != VisitorApproachedEventName {
+		t.Fatalf("Events %v, want [%s]", names, VisitorApproachedEventName)
+	}
+
+	tracker.rearm(now)
+	if names := feed(near, 500*time.Millisecond); len(names) != 0 {
+		t.Errorf("Events %v before the hold time", names)
+	}
+	if names := feed(near, time.Second); len(names) != 1 || names[0] != VisitorApproachedEventName {
+		t.Errorf("Events %v, want [%s]", names, VisitorApproachedEventName)
+	}
+	if names := feed(far, 2*time.Second); len(names) != 1 || names[0] != VisitorLeftEventName {
+		t.Errorf("Events %v, want [%s]", names, VisitorLeftEventName)
+	}
+}
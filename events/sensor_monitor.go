@@ -0,0 +1,218 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SensorPresenceParams are the params of SensorMonitor
+type SensorPresenceParams struct {
+	PollPeriod time.Duration
+	// MaxDistance is the distance within which the visitor is detected,
+	// 0 means any distance
+	MaxDistance float64
+	// HoldTime is how long the visitor must be detected to approach
+	HoldTime time.Duration
+	// ReleaseTime is how long the visitor must not be detected to leave
+	ReleaseTime time.Duration
+}
+
+// DefaultSensorPresenceParams detect "a person within 1.2 m for 2 s"
+var DefaultSensorPresenceParams = SensorPresenceParams{
+	PollPeriod:  100 * time.Millisecond,
+	MaxDistance: 1.2,
+	HoldTime:    2 * time.Second,
+	ReleaseTime: 3 * time.Second,
+}
+
+// SensorMonitor polls the presence sensors and emits VisitorApproached and
+// VisitorLeft events. The visitor is detected when all
+// the sensors detect somebody within MaxDistance.
+type SensorMonitor struct {
+	presenceBroadcaster
+
+	sensors []PresenceSensor
+	quit    chan struct{}
+
+	mutex   sync.Mutex
+	tracker distanceTracker
+}
+
+// NewSensorMonitor creates new SensorMonitor and starts polling the sensors
+func NewSensorMonitor(sensors []PresenceSensor, params SensorPresenceParams) *SensorMonitor {
+	if params.PollPeriod <= 0 {
+		params.PollPeriod = DefaultSensorPresenceParams.PollPeriod
+	}
+
+	m := &SensorMonitor{
+		presenceBroadcaster: newPresenceBroadcaster(),
+		sensors:             sensors,
+		quit:                make(chan struct{}),
+		tracker:             distanceTracker{params: params},
+	}
+	go m.run()
+	return m
+}
+
+// Present reports whether a visitor has stopped in front of the character
+func (m *SensorMonitor) Present() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.tracker.present
+}
+
+// Rearm implements PresenceDetector
+func (m *SensorMonitor) Rearm() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tracker.rearm(time.Now())
+}
+
+// Close stops polling and closes the sensors
+func (m *SensorMonitor) Close() {
+	close(m.quit)
+	for _, sensor := range m.sensors {
+		if err := sensor.Close(); err != nil {
+			logrus.Errorf("Sensor %s: %v", sensor.Name(), err)
+		}
+	}
+}
+
+func (m *SensorMonitor) run() {
+	t := time.NewTicker(m.tracker.params.PollPeriod)
+	defer t.Stop()
+
+	failing := make(map[string]bool)
+	for {
+		select {
+		case now := <-t.C:
+			m.update(now, m.read(failing))
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// read combines the readings of the sensors
+func (m *SensorMonitor) read(failing map[string]bool) SensorReading {
+	combined := SensorReading{Present: len(m.sensors) > 0, Distance: NoDistance}
+	for _, sensor := range m.sensors {
+		reading, err := sensor.Read()
+		if err != nil {
+			if !failing[sensor.Name()] {
+				logrus.Warnf("Sensor %s: %v", sensor.Name(), err)
+				failing[sensor.Name()] = true
+			}
+			combined.Present = false
+			continue
+		}
+		if failing[sensor.Name()] {
+			logrus.Infof("Sensor %s: recovered", sensor.Name())
+			delete(failing, sensor.Name())
+		}
+
+		combined.Present = combined.Present && reading.Present
+		if reading.Distance != NoDistance &&
+			(combined.Distance == NoDistance || reading.Distance < combined.Distance) {
+			combined.Distance = reading.Distance
+		}
+	}
+	return combined
+}
+
+func (m *SensorMonitor) update(now time.Time, reading SensorReading) {
+	m.mutex.Lock()
+	event := m.tracker.update(now, reading)
+	m.mutex.Unlock()
+
+	if event != nil {
+		m.broadcast(event)
+	}
+}
+
+// distanceTracker turns the combined readings into the presence events
+type distanceTracker struct {
+	params SensorPresenceParams
+
+	present      bool
+	nearSince    time.Time // when the visitor was detected, zero if not detected
+	farSince     time.Time // when the present visitor was lost, zero if not lost
+	presentSince time.Time
+	lastDistance float64 // distance of the present visitor when last seen
+}
+
+func (t *distanceTracker) near(reading SensorReading) bool {
+	if !reading.Present {
+		return false
+	}
+	if t.params.MaxDistance <= 0 || reading.Distance == NoDistance {
+		return true
+	}
+	return reading.Distance <= t.params.MaxDistance
+}
+
+// rearm makes the present visitor approach again, VisitorApproached is
+// returned once the visitor stays near for the hold time from now
+func (t *distanceTracker) rearm(now time.Time) {
+	if !t.present {
+		return
+	}
+	t.present = false
+	t.nearSince = time.Time{}
+	if t.farSince.IsZero() {
+		t.nearSince = now
+	}
+}
+
+// update processes the reading and returns the event to emit or nil
+func (t *distanceTracker) update(now time.Time, reading SensorReading) *Event {
+	near := t.near(reading)
+
+	if !t.present {
+		if !near {
+			t.nearSince = time.Time{}
+			return nil
+		}
+		if t.nearSince.IsZero() {
+			t.nearSince = now
+		}
+		if now.Sub(t.nearSince) < t.params.HoldTime {
+			return nil
+		}
+
+		t.present = true
+		t.presentSince = t.nearSince
+		t.farSince = time.Time{}
+		t.lastDistance = reading.Distance
+		return &Event{
+			Name: VisitorApproachedEventName,
+			Args: []interface{}{
+				PresenceEventData{DirectionUnknown, now.Sub(t.nearSince), reading.Distance},
+			},
+		}
+	}
+
+	if near {
+		t.farSince = time.Time{}
+		t.lastDistance = reading.Distance
+		return nil
+	}
+
+	if t.farSince.IsZero() {
+		t.farSince = now
+	}
+	if now.Sub(t.farSince) < t.params.ReleaseTime {
+		return nil
+	}
+
+	t.present = false
+	t.nearSince = time.Time{}
+	return &Event{
+		Name: VisitorLeftEventName,
+		Args: []interface{}{
+			PresenceEventData{DirectionUnknown, t.farSince.Sub(t.presentSince), t.lastDistance},
+		},
+	}
+}
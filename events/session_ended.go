@@ -0,0 +1,43 @@
+package events
+
+import (
+	"fmt"
+)
+
+const (
+	SessionEndedEventName = "SessionEnded"
+)
+
+// SessionEndedEventData is the SessionEnded event data
+type SessionEndedEventData struct {
+	// Reason is why the conversation session is ended, e.g. "timeout"
+	Reason string
+	// Session is the number of the ended session
+	Session int
+}
+
+// NewSessionEndedEvent creates SessionEndedEvent
+func NewSessionEndedEvent(reason string, session int) *Event {
+	return &Event{
+		Name: SessionEndedEventName,
+		Args: []interface{}{SessionEndedEventData{Reason: reason, Session: session}},
+	}
+}
+
+// GetSessionEndedEventData gets SessionEndedEvent data
+func GetSessionEndedEventData(event *Event) (SessionEndedEventData, error) {
+	if event.Name != SessionEndedEventName {
+		return SessionEndedEventData{}, fmt.Errorf("The event must be named %s", SessionEndedEventName)
+	}
+
+	if len(event.Args) != 1 {
+		return SessionEndedEventData{}, fmt.Errorf("Event does not contain data")
+	}
+
+	data, ok := event.Args[0].(SessionEndedEventData)
+	if !ok {
+		return SessionEndedEventData{}, fmt.Errorf("Invalid event data type")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,163 @@
+package events
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultStreamMaxAge is the age after which the last value of a stream
+// sensor is considered stale
+const DefaultStreamMaxAge = 2 * time.Second
+
+// StreamSensor is a sensor reporting its values line by line, e.g. the output
+// of a camera person detector. A line is one of:
+//
+//	1, 0, true, false, present, absent   presence without distance
+//	1.15                                 distance in meters, 0 means nobody
+type StreamSensor struct {
+	name   string
+	closer io.Closer
+	maxAge time.Duration
+
+	mutex   sync.Mutex
+	reading SensorReading
+	err     error
+}
+
+// NewCommandSensor starts the command and reads the values from its output
+func NewCommandSensor(command string, args ...string) (*StreamSensor, error) {
+	cmd := exec.Command(command, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Failed to start sensor command '%s': %v", command, err)
+	}
+
+	s := newStreamSensor(fmt.Sprintf("command(%s)", command), commandCloser{cmd})
+	go func() {
+		s.readLines(stdout)
+		if err := cmd.Wait(); err != nil {
+			logrus.Errorf("Sensor command '%s' exited: %v", command, err)
+		}
+	}()
+	return s, nil
+}
+
+// NewUnixSocketSensor connects to the Unix socket and reads the values from it
+func NewUnixSocketSensor(path string) (*StreamSensor, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newStreamSensor(fmt.Sprintf("socket(%s)", path), conn)
+	go s.readLines(conn)
+	return s, nil
+}
+
+func newStreamSensor(name string, closer io.Closer) *StreamSensor {
+	return &StreamSensor{
+		name:   name,
+		closer: closer,
+		maxAge: DefaultStreamMaxAge,
+		err:    errors.New("No value yet"),
+	}
+}
+
+// SetMaxAge sets the age after which the last value is considered stale
+func (s *StreamSensor) SetMaxAge(maxAge time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.maxAge = maxAge
+}
+
+// Name implements PresenceSensor
+func (s *StreamSensor) Name() string {
+	return s.name
+}
+
+// Read implements PresenceSensor.
+// It returns the last value reported by the stream.
+func (s *StreamSensor) Read() (SensorReading, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err != nil {
+		return SensorReading{}, s.err
+	}
+	if s.maxAge > 0 && time.Since(s.reading.Time) > s.maxAge {
+		return SensorReading{}, fmt.Errorf("The value is stale, last one at %v",
+			s.reading.Time.Format(time.StampMilli))
+	}
+	return s.reading, nil
+}
+
+// Close implements PresenceSensor
+func (s *StreamSensor) Close() error {
+	return s.closer.Close()
+}
+
+func (s *StreamSensor) readLines(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		reading, err := parseSensorLine(line)
+		if err != nil {
+			logrus.Warnf("Sensor %s: %v", s.name, err)
+			continue
+		}
+		reading.Time = time.Now()
+
+		s.mutex.Lock()
+		s.reading = reading
+		s.err = nil
+		s.mutex.Unlock()
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	s.mutex.Lock()
+	s.err = fmt.Errorf("Sensor stream is closed: %v", err)
+	s.mutex.Unlock()
+}
+
+func parseSensorLine(line string) (SensorReading, error) {
+	switch strings.ToLower(line) {
+	case "1", "true", "present":
+		return SensorReading{Present: true, Distance: NoDistance}, nil
+	case "0", "false", "absent":
+		return SensorReading{Present: false, Distance: NoDistance}, nil
+	}
+
+	distance, err := strconv.ParseFloat(line, 64)
+	if err != nil || distance < 0 {
+		return SensorReading{}, fmt.Errorf("Invalid value '%s'", line)
+	}
+	return SensorReading{Present: distance > 0, Distance: distance}, nil
+}
+
+type commandCloser struct {
+	cmd *exec.Cmd
+}
+
+func (c commandCloser) Close() error {
+	return c.cmd.Process.Kill()
+}
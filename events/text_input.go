@@ -0,0 +1,43 @@
+package events
+
+import (
+	"fmt"
+)
+
+const (
+	TextInputEventName = "TextInput"
+)
+
+// TextInputEventData is the TextInput event data
+type TextInputEventData struct {
+	// Text is the utterance for the conversation backend
+	Text string
+	// Source is where the text comes from, e.g. "touch"
+	Source string
+}
+
+// NewTextInputEvent creates TextInputEvent
+func NewTextInputEvent(text string, source string) *Event {
+	return &Event{
+		Name: TextInputEventName,
+		Args: []interface{}{TextInputEventData{Text: text, Source: source}},
+	}
+}
+
+// GetTextInputEventData gets TextInputEvent data
+func GetTextInputEventData(event *Event) (TextInputEventData, error) {
+	if event.Name != TextInputEventName {
+		return TextInputEventData{}, fmt.Errorf("The event must be named %s", TextInputEventName)
+	}
+
+	if len(event.Args) != 1 {
+		return TextInputEventData{}, fmt.Errorf("Event does not contain data")
+	}
+
+	data, ok := event.Args[0].(TextInputEventData)
+	if !ok {
+		return TextInputEventData{}, fmt.Errorf("Invalid event data type")
+	}
+
+	return data, nil
+}
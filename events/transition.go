@@ -0,0 +1,11 @@
+package events
+
+import "time"
+
+// Transition describes a completed transition of the character FSM
+type Transition struct {
+	Event string    `json:"event"`
+	Src   string    `json:"src"`
+	Dst   string    `json:"dst"`
+	Time  time.Time `json:"time"`
+}
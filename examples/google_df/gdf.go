@@ -13,7 +13,7 @@ import (
 	dialogflowpb "google.golang.org/genproto/googleapis/cloud/dialogflow/v2"
 )
 
-func doTheJob(hwd *sound.HotWordDetector, sessionID string, player *sound.SoundPlayer) {
+func doTheJob(hwd *sound.HotWordDetector, sessionID string, languageCode string, player *sound.SoundPlayer) {
 	projectID := "test-kuxabp"
 
 	soundCapturerEventSource, _ := hwd.StartSoundCapture()
@@ -36,12 +36,14 @@ func doTheJob(hwd *sound.HotWordDetector, sessionID string, player *sound.SoundP
 			audioConfig := dialogflowpb.InputAudioConfig{
 				AudioEncoding:   dialogflowpb.AudioEncoding_AUDIO_ENCODING_LINEAR_16,
 				SampleRateHertz: 16000,
-				LanguageCode:    "en",
+				LanguageCode:    languageCode,
 			}
 			voice := dialogflowpb.VoiceSelectionParams{
-				Name:       "en-US-Standard-E",
 				SsmlGender: dialogflowpb.SsmlVoiceGender_SSML_VOICE_GENDER_FEMALE,
 			}
+			if languageCode == "en" {
+				voice.Name = "en-US-Standard-E"
+			}
 			synthCfg := dialogflowpb.SynthesizeSpeechConfig{
 				Voice:        &voice,
 				Pitch:        4,
@@ -96,6 +98,12 @@ func doTheJob(hwd *sound.HotWordDetector, sessionID string, player *sound.SoundP
 func main() {
 	uid := uuid.NewV4()
 
+	// The language of the agent, e.g. "es"
+	languageCode := "en"
+	if len(os.Args) > 1 {
+		languageCode = os.Args[1]
+	}
+
 	hwd, _ := sound.NewHotWordDetector(
 		sound.HotWordDetectorParams{
 			DebugSound:        true,
@@ -106,5 +114,5 @@ func main() {
 	)
 	player, _ := sound.NewSoundPlayer("default")
 
-	doTheJob(hwd, uid.String(), player)
+	doTheJob(hwd, uid.String(), languageCode, player)
 }
@@ -15,12 +15,13 @@ import (
 
 func main() {
 	// Register your driver in the registry:
-	if err := periph.Register(atmel.AtmelGpioDriver{
+	driver := &atmel.AtmelGpioDriver{
 		Pins: atmel.AtmelGpioPins{
 			atmel.AtmelGpioPin{91, "pioC27"},
 			atmel.AtmelGpioPin{92, "pioC28"},
 		},
-	}); err != nil {
+	}
+	if err := periph.Register(driver); err != nil {
 		log.Fatal(err)
 	}
 	// Initialize normally. Your driver will be loaded:
@@ -0,0 +1,244 @@
+package hasp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	atmel "github.com/rmcsoft/hasp/atmel/periph_gpio"
+	gpiod "github.com/rmcsoft/hasp/gpiod/periph_gpio"
+	"github.com/sirupsen/logrus"
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/host"
+)
+
+// GpioBackend is the kernel interface used to access the sensors pins
+type GpioBackend string
+
+const (
+	// SysfsGpioBackend uses the deprecated /sys/class/gpio interface
+	SysfsGpioBackend GpioBackend = "sysfs"
+	// ChardevGpioBackend uses the GPIO character device (/dev/gpiochipN)
+	ChardevGpioBackend GpioBackend = "chardev"
+)
+
+// GpioParams GPIO backend params
+type GpioParams struct {
+	Backend GpioBackend
+
+	// SysfsRoot is the GPIO sysfs directory used by SysfsGpioBackend,
+	// atmel.DefaultSysfsRoot if empty
+	SysfsRoot string
+
+	// Chip is the GPIO chip used by ChardevGpioBackend. The pin numbers are
+	// the line offsets of the chip.
+	Chip string
+	// Debounce is the debounce period of the sensors applied by the kernel,
+	// ChardevGpioBackend only
+	Debounce time.Duration
+}
+
+var gpioParams = GpioParams{Backend: SysfsGpioBackend}
+
+// SetGpioParams selects the GPIO backend.
+// It must be called before the states using the sensors are created.
+func SetGpioParams(params GpioParams) error {
+	switch params.Backend {
+	case SysfsGpioBackend:
+	case ChardevGpioBackend:
+		if params.Chip == "" {
+			return fmt.Errorf("GPIO chip is not specified")
+		}
+	default:
+		return fmt.Errorf("Unknown GPIO backend '%s'", params.Backend)
+	}
+	gpioParams = params
+	return nil
+}
+
+// gpioDriver is the periph driver of the selected backend
+type gpioDriver interface {
+	periph.Driver
+	Close() error
+}
+
+func newGpioDriver(pins atmel.AtmelGpioPins) gpioDriver {
+	if gpioParams.Backend != ChardevGpioBackend {
+		return &atmel.AtmelGpioDriver{Pins: pins, Root: gpioParams.SysfsRoot}
+	}
+
+	lines := make(gpiod.GpiodPins, len(pins))
+	for i, p := range pins {
+		lines[i] = gpiod.GpiodPin{Offset: p.Number, Name: p.Name}
+	}
+	return &gpiod.GpiodDriver{
+		Chip:     gpioParams.Chip,
+		Pins:     lines,
+		Debounce: gpioParams.Debounce,
+	}
+}
+
+// gpioRegistry is the GPIO pins shared by the states, the presence monitor
+// and the outputs. The driver is loaded once, the input pins are configured
+// once.
+type gpioRegistry struct {
+	mutex  sync.Mutex
+	driver gpioDriver
+	err    error // error of loading the driver, it is not retried
+	inputs map[string]gpio.PinIO
+}
+
+var sharedGpio = gpioRegistry{
+	inputs: make(map[string]gpio.PinIO),
+}
+
+// RegisterGpioPins loads the GPIO driver exporting the pins.
+// The driver is loaded once, so all the pins used by the character, sensors
+// and outputs, must be registered by the first call.
+func RegisterGpioPins(pins atmel.AtmelGpioPins) error {
+	sharedGpio.mutex.Lock()
+	defer sharedGpio.mutex.Unlock()
+	return sharedGpio.register(pins)
+}
+
+// CloseGpio releases the pins and unexports the pins exported by the driver.
+// The GPIO can't be used after that.
+func CloseGpio() error {
+	sharedGpio.mutex.Lock()
+	defer sharedGpio.mutex.Unlock()
+
+	if sharedGpio.driver == nil {
+		return nil
+	}
+	for name, pin := range sharedGpio.inputs {
+		if err := pin.Halt(); err != nil {
+			logrus.Errorf("GPIO %s: %v", name, err)
+		}
+	}
+	sharedGpio.inputs = make(map[string]gpio.PinIO)
+
+	err := sharedGpio.driver.Close()
+	sharedGpio.driver = nil
+	sharedGpio.err = fmt.Errorf("GPIO is closed")
+	return err
+}
+
+// openInputPins returns the input pins, the pins which are not available
+// are nil. It returns nil if the GPIO is not available.
+func openInputPins(pins atmel.AtmelGpioPins) []gpio.PinIO {
+	sharedGpio.mutex.Lock()
+	defer sharedGpio.mutex.Unlock()
+
+	if err := sharedGpio.register(pins); err != nil {
+		logrus.Error(err)
+		return nil
+	}
+
+	inputs := make([]gpio.PinIO, len(pins))
+	for i, p := range pins {
+		inputs[i] = sharedGpio.openInput(p)
+	}
+	return inputs
+}
+
+// register loads the driver on the first call.
+//
+// mutex must be held.
+func (r *gpioRegistry) register(pins atmel.AtmelGpioPins) error {
+	if r.driver == nil && r.err == nil {
+		r.err = r.load(pins)
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	var missing []string
+	for _, p := range pins {
+		if gpioreg.ByName(p.Name) == nil {
+			missing = append(missing, p.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("GPIO pins are not registered: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// load registers the driver with the pins and initializes periph.
+//
+// mutex must be held.
+func (r *gpioRegistry) load(pins atmel.AtmelGpioPins) error {
+	driver := newGpioDriver(pins)
+	if err := periph.Register(driver); err != nil {
+		return err
+	}
+
+	// Initialize normally. Your driver will be loaded:
+	state, err := host.Init()
+	if err != nil {
+		return err
+	}
+
+	if !driverLoaded(state, driver.String()) {
+		err := driverError(state, driver.String())
+		pinErrs, ok := err.(atmel.PinErrors)
+		if !ok {
+			return err
+		}
+		// The other pins are usable
+		for _, pinErr := range pinErrs {
+			logrus.Error(pinErr)
+		}
+	}
+	r.driver = driver
+	return nil
+}
+
+// openInput configures the pin as input once.
+//
+// mutex must be held.
+func (r *gpioRegistry) openInput(p atmel.AtmelGpioPin) gpio.PinIO {
+	if pin, ok := r.inputs[p.Name]; ok {
+		return pin
+	}
+
+	pin := gpioreg.ByName(p.Name)
+	if pin == nil {
+		logrus.Error("Failed to open pin ", p.Name)
+		return nil
+	}
+	err := pin.In(gpio.PullNoChange, gpio.BothEdges)
+	if err != nil {
+		logrus.Debug("GPIO ", p.Name, " has no edge detection: ", err)
+		err = pin.In(gpio.PullNoChange, gpio.NoEdge)
+	}
+	if err != nil {
+		logrus.Error(err)
+		return nil
+	}
+
+	logrus.Debug("GPIO ", p.Name, " is ready")
+	r.inputs[p.Name] = pin
+	return pin
+}
+
+func driverLoaded(state *periph.State, name string) bool {
+	for _, a := range state.Loaded {
+		if name == a.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func driverError(state *periph.State, name string) error {
+	for _, f := range append(state.Failed, state.Skipped...) {
+		if f.D.String() == name {
+			return f.Err
+		}
+	}
+	return fmt.Errorf("Driver %s is not loaded", name)
+}
@@ -0,0 +1,155 @@
+package periph_gpio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// chipOpen opens the GPIO chip character device.
+// It is a variable so the kernel can be replaced by a fake ioctl layer.
+var chipOpen = chipOpenDefault
+
+func chipOpenDefault(path string) (chipIO, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &chipFile{f}, nil
+}
+
+// lineOpen wraps the line request file descriptor returned by the kernel.
+// It is a variable so the kernel can be replaced by a fake ioctl layer.
+var lineOpen = lineOpenDefault
+
+func lineOpenDefault(fd int) (lineIO, error) {
+	epollFd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	f := &lineFile{fd: fd, epollFd: epollFd, wake: [2]int{-1, -1}}
+	if err := syscall.Pipe2(f.wake[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		f.Close()
+		return nil, err
+	}
+	events := []syscall.EpollEvent{
+		{Events: syscall.EPOLLIN, Fd: int32(fd)},
+		{Events: syscall.EPOLLIN, Fd: int32(f.wake[0])},
+	}
+	for i := range events {
+		if err := syscall.EpollCtl(epollFd, syscall.EPOLL_CTL_ADD, int(events[i].Fd), &events[i]); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// ioctler issues the ioctls. The argument is passed as a pointer up to the
+// syscall, so the runtime doesn't move the structure during the call.
+type ioctler interface {
+	Ioctl(op uint, arg unsafe.Pointer) error
+}
+
+type chipIO interface {
+	io.Closer
+	ioctler
+}
+
+type lineIO interface {
+	io.Closer
+	io.Reader
+	ioctler
+
+	// Wait waits until a line event can be read.
+	// It returns 1 if an event is available and errWoken if it is woken up
+	// by Wake.
+	Wait(timeoutms int) (int, error)
+
+	// Wake wakes up Wait. The next Wait returns at once if nobody is waiting.
+	Wake() error
+}
+
+// errWoken is returned by lineIO.Wait woken up by Wake
+var errWoken = errors.New("woken up")
+
+// chipFile is the GPIO chip character device
+type chipFile struct {
+	*os.File
+}
+
+func (f *chipFile) Ioctl(op uint, arg unsafe.Pointer) error {
+	return ioctl(f.Fd(), op, arg)
+}
+
+// lineFile is the line request file descriptor
+type lineFile struct {
+	fd      int
+	epollFd int
+	wake    [2]int // pipe waking up Wait
+}
+
+func (f *lineFile) Ioctl(op uint, arg unsafe.Pointer) error {
+	return ioctl(uintptr(f.fd), op, arg)
+}
+
+func (f *lineFile) Read(b []byte) (int, error) {
+	return syscall.Read(f.fd, b)
+}
+
+func (f *lineFile) Wait(timeoutms int) (int, error) {
+	var events [2]syscall.EpollEvent
+	n, err := syscall.EpollWait(f.epollFd, events[:], timeoutms)
+	if err != nil {
+		return 0, err
+	}
+	ready, woken := 0, false
+	for _, event := range events[:n] {
+		if int(event.Fd) == f.wake[0] {
+			woken = true
+		} else {
+			ready = 1
+		}
+	}
+	if woken {
+		var buf [16]byte
+		for {
+			if n, err := syscall.Read(f.wake[0], buf[:]); n <= 0 || err != nil {
+				break
+			}
+		}
+		if ready == 0 {
+			return 0, errWoken
+		}
+	}
+	return ready, nil
+}
+
+func (f *lineFile) Wake() error {
+	_, err := syscall.Write(f.wake[1], []byte{0})
+	if err == syscall.EAGAIN {
+		// The pipe is full of the wakeups not handled yet
+		return nil
+	}
+	return err
+}
+
+func (f *lineFile) Close() error {
+	for _, fd := range f.wake {
+		if fd >= 0 {
+			syscall.Close(fd)
+		}
+	}
+	syscall.Close(f.epollFd)
+	return syscall.Close(f.fd)
+}
+
+func ioctl(fd uintptr, op uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(op), uintptr(arg)); errno != 0 {
+		return syscall.Errno(errno)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package periph_gpio
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+)
+
+// GpiodPin is the line of the GPIO chip registered under the name
+type GpiodPin struct {
+	Offset int
+	Name   string
+}
+
+type GpiodPins []GpiodPin
+
+// GpiodDriver is the periph driver for the GPIO character device
+// (/dev/gpiochipN).
+type GpiodDriver struct {
+	// Chip is the path of the GPIO chip, e.g. /dev/gpiochip0
+	Chip string
+	Pins GpiodPins
+
+	// Debounce is the debounce period applied by the kernel to the input
+	// lines, disabled if zero
+	Debounce time.Duration
+
+	// Consumer is the label of the line requests, "hasp" if empty
+	Consumer string
+
+	mutex sync.Mutex
+	chip  *chip
+	pins  []*Pin
+}
+
+const (
+	GpiodDriverName = "Gpiod-GPIO-driver"
+
+	defaultConsumer = "hasp"
+)
+
+func (d *GpiodDriver) String() string          { return GpiodDriverName }
+func (d *GpiodDriver) Prerequisites() []string { return nil }
+func (d *GpiodDriver) After() []string         { return nil }
+
+// Init opens the chip and registers the pins.
+// Nothing is left registered or opened if it fails.
+func (d *GpiodDriver) Init() (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	f, err := chipOpen(d.Chip)
+	if err != nil {
+		return false, fmt.Errorf("Failed to open GPIO chip %s: %v", d.Chip, err)
+	}
+
+	var info gpioChipInfo
+	if err := f.Ioctl(gpioGetChipInfoIoctl, unsafe.Pointer(&info)); err != nil {
+		f.Close()
+		return false, fmt.Errorf("Failed to get info of GPIO chip %s: %v", d.Chip, err)
+	}
+	logrus.Debugf("GPIO chip %s: %s, %d lines",
+		cString(info.name[:]), cString(info.label[:]), info.lines)
+
+	consumer := d.Consumer
+	if consumer == "" {
+		consumer = defaultConsumer
+	}
+
+	c := &chip{path: d.Chip, f: f}
+	d.chip = c
+	for _, item := range d.Pins {
+		if item.Offset < 0 || item.Offset >= int(info.lines) {
+			d.close()
+			return false, fmt.Errorf("GPIO chip %s has no line %d (%s)", d.Chip, item.Offset, item.Name)
+		}
+
+		p := &Pin{
+			number:   item.Offset,
+			name:     item.Name,
+			chip:     c,
+			consumer: consumer,
+			debounce: d.Debounce,
+			pull:     gpio.PullNoChange,
+		}
+		if err := gpioreg.Register(p); err != nil {
+			d.close()
+			return false, err
+		}
+		d.pins = append(d.pins, p)
+		if err := gpioreg.RegisterAlias(strconv.Itoa(item.Offset), p.name); err != nil {
+			logrus.Info("Adding alias failed: ", strconv.Itoa(item.Offset), "to", p.name)
+		}
+	}
+	return true, nil
+}
+
+// Close unregisters the pins, releases the requested lines and closes the chip
+func (d *GpiodDriver) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.close()
+}
+
+// close closes the driver, the mutex must be held
+func (d *GpiodDriver) close() error {
+	var firstErr error
+	for _, p := range d.pins {
+		_ = gpioreg.Unregister(strconv.Itoa(p.number))
+		if err := gpioreg.Unregister(p.name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := p.release(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	d.pins = nil
+
+	if d.chip != nil {
+		if err := d.chip.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		d.chip = nil
+	}
+	return firstErr
+}
+
+// chip is the opened GPIO chip shared by its pins
+type chip struct {
+	path string
+	f    chipIO
+}
@@ -0,0 +1,336 @@
+package periph_gpio
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+)
+
+// fakeChip is the GPIO chip of the fake ioctl layer
+type fakeChip struct {
+	mu     sync.Mutex
+	lines  uint32
+	closed bool
+	// requests are the requested lines by their file descriptor
+	requests map[int]*fakeLine
+}
+
+func (c *fakeChip) Ioctl(op uint, arg unsafe.Pointer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch op {
+	case gpioGetChipInfoIoctl:
+		info := (*gpioChipInfo)(arg)
+		copy(info.name[:], "gpiochip0")
+		info.lines = c.lines
+	case gpioV2GetLineIoctl:
+		req := (*gpioV2LineRequest)(arg)
+		req.fd = int32(len(c.requests) + 100)
+		c.requests[int(req.fd)] = &fakeLine{
+			offset: req.offsets[0],
+			flags:  req.config.flags,
+			events: make(chan gpioV2LineEvent, 1),
+			wake:   make(chan struct{}, 1),
+		}
+	default:
+		return syscall.ENOTTY
+	}
+	return nil
+}
+
+func (c *fakeChip) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// line returns the line requested for the offset
+func (c *fakeChip) line(offset uint32) *fakeLine {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, line := range c.requests {
+		if line.offset == offset {
+			return line
+		}
+	}
+	return nil
+}
+
+// fakeLine is the line request of the fake ioctl layer.
+// The edges are sent to events.
+type fakeLine struct {
+	offset uint32
+	events chan gpioV2LineEvent
+	wake   chan struct{}
+
+	mu      sync.Mutex
+	flags   uint64
+	level   bool
+	pending *gpioV2LineEvent
+	closed  bool
+	// closedWaiting is set if the line is closed during Wait
+	closedWaiting bool
+}
+
+func (l *fakeLine) Ioctl(op uint, arg unsafe.Pointer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return syscall.EBADF
+	}
+	switch op {
+	case gpioV2LineSetConfigIoctl:
+		l.flags = (*gpioV2LineConfig)(arg).flags
+	case gpioV2LineGetValuesIoctl:
+		values := (*gpioV2LineValues)(arg)
+		values.bits = 0
+		if l.level {
+			values.bits = 1
+		}
+	case gpioV2LineSetValuesIoctl:
+		l.level = (*gpioV2LineValues)(arg).bits&1 != 0
+	default:
+		return syscall.ENOTTY
+	}
+	return nil
+}
+
+func (l *fakeLine) Read(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pending == nil {
+		return 0, syscall.EAGAIN
+	}
+	buf := (*[1 << 10]byte)(unsafe.Pointer(l.pending))[:gpioV2LineEventSize:gpioV2LineEventSize]
+	l.pending = nil
+	return copy(b, buf), nil
+}
+
+func (l *fakeLine) Wait(timeoutms int) (int, error) {
+	defer func() {
+		l.mu.Lock()
+		l.closedWaiting = l.closedWaiting || l.closed
+		l.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if timeoutms >= 0 {
+		timeout = time.After(time.Duration(timeoutms) * time.Millisecond)
+	}
+	select {
+	case event := <-l.events:
+		l.mu.Lock()
+		l.pending = &event
+		l.mu.Unlock()
+		return 1, nil
+	case <-l.wake:
+		return 0, errWoken
+	case <-timeout:
+		return 0, nil
+	}
+}
+
+func (l *fakeLine) Wake() error {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (l *fakeLine) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errors.New("closed twice")
+	}
+	l.closed = true
+	return nil
+}
+
+func (l *fakeLine) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+// fakeGpio replaces the kernel with a chip of n lines
+func fakeGpio(n uint32) (c *fakeChip, restore func()) {
+	c = &fakeChip{lines: n, requests: make(map[int]*fakeLine)}
+	chipOpen = func(path string) (chipIO, error) {
+		return c, nil
+	}
+	lineOpen = func(fd int) (lineIO, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		line, ok := c.requests[fd]
+		if !ok {
+			return nil, syscall.EBADF
+		}
+		return line, nil
+	}
+	return c, func() {
+		chipOpen = chipOpenDefault
+		lineOpen = lineOpenDefault
+	}
+}
+
+// initDriver initializes the driver of the pins
+func initDriver(t *testing.T, pins GpiodPins) *GpiodDriver {
+	d := &GpiodDriver{Chip: "/dev/gpiochip0", Pins: pins}
+	if ok, err := d.Init(); !ok || err != nil {
+		t.Fatalf("Init: %v, %v", ok, err)
+	}
+	return d
+}
+
+func TestInitBadOffset(t *testing.T) {
+	c, restore := fakeGpio(4)
+	defer restore()
+
+	d := &GpiodDriver{Chip: "/dev/gpiochip0", Pins: GpiodPins{{1, "GPIOD_T1"}, {9, "GPIOD_T9"}}}
+	ok, err := d.Init()
+	if ok || err == nil {
+		t.Fatalf("Init: %v, %v, want the error", ok, err)
+	}
+	if !c.closed {
+		t.Error("The chip is left open")
+	}
+	if p := gpioreg.ByName("GPIOD_T1"); p != nil {
+		t.Error("The pin is left registered")
+	}
+}
+
+func TestPinIn(t *testing.T) {
+	c, restore := fakeGpio(4)
+	defer restore()
+	d := initDriver(t, GpiodPins{{2, "GPIOD_IN"}})
+
+	p := gpioreg.ByName("GPIOD_IN")
+	if err := p.In(gpio.PullUp, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	line := c.line(2)
+	if line == nil {
+		t.Fatal("The line is not requested")
+	}
+	want := gpioV2LineFlagInput | gpioV2LineFlagBiasPullUp | gpioV2LineFlagEdgeMask | gpioV2LineFlagEventClockRealtime
+	if line.flags != want {
+		t.Errorf("Flags %#x, want %#x", line.flags, want)
+	}
+
+	line.mu.Lock()
+	line.level = true
+	line.mu.Unlock()
+	if level := p.Read(); level != gpio.High {
+		t.Errorf("Level %v, want High", level)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !line.isClosed() || !c.closed {
+		t.Error("The line or the chip is left open")
+	}
+	if p := gpioreg.ByName("GPIOD_IN"); p != nil {
+		t.Error("The pin is left registered")
+	}
+}
+
+func TestWaitForEdge(t *testing.T) {
+	c, restore := fakeGpio(4)
+	defer restore()
+	d := initDriver(t, GpiodPins{{3, "GPIOD_EDGE"}})
+	defer d.Close()
+
+	p := gpioreg.ByName("GPIOD_EDGE").(*Pin)
+	if err := p.In(gpio.PullNoChange, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	edgeTime := time.Unix(1500000000, 0)
+	c.line(3).events <- gpioV2LineEvent{
+		timestampNs: uint64(edgeTime.UnixNano()),
+		id:          gpioV2LineEventRisingEdge,
+		offset:      3,
+	}
+	if !p.WaitForEdge(time.Second) {
+		t.Fatal("No edge")
+	}
+	if !p.LastEdgeTime().Equal(edgeTime) {
+		t.Errorf("Edge time %v, want %v", p.LastEdgeTime(), edgeTime)
+	}
+	if p.WaitForEdge(10 * time.Millisecond) {
+		t.Error("Edge without an event")
+	}
+}
+
+func TestHaltWakesWaitForEdge(t *testing.T) {
+	c, restore := fakeGpio(4)
+	defer restore()
+	d := initDriver(t, GpiodPins{{0, "GPIOD_HALT"}})
+	defer d.Close()
+
+	p := gpioreg.ByName("GPIOD_HALT")
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	edges := make(chan bool)
+	go func() {
+		edges <- p.WaitForEdge(-1)
+	}()
+
+	if err := p.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case edge := <-edges:
+		if edge {
+			t.Error("Halt is reported as an edge")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForEdge is not woken up by Halt")
+	}
+	if flags := c.line(0).flags; flags&gpioV2LineFlagEdgeMask != 0 {
+		t.Errorf("Edge detection is enabled after Halt, flags %#x", flags)
+	}
+}
+
+func TestCloseWhileWaiting(t *testing.T) {
+	c, restore := fakeGpio(4)
+	defer restore()
+	d := initDriver(t, GpiodPins{{1, "GPIOD_CLOSE"}})
+
+	p := gpioreg.ByName("GPIOD_CLOSE")
+	if err := p.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	line := c.line(1)
+	edges := make(chan bool)
+	go func() {
+		edges <- p.WaitForEdge(-1)
+	}()
+	// Let the pin wait for an edge
+	time.Sleep(10 * time.Millisecond)
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-edges:
+	case <-time.After(time.Second):
+		t.Fatal("WaitForEdge is not woken up by Close")
+	}
+	if !line.isClosed() {
+		t.Error("The line is left open")
+	}
+	if line.closedWaiting {
+		t.Error("The line is closed while waiting for an edge")
+	}
+}
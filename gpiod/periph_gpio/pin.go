@@ -0,0 +1,376 @@
+package periph_gpio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/pin"
+)
+
+// Pin represents one line of the GPIO chip.
+type Pin struct {
+	number   int
+	name     string
+	chip     *chip
+	consumer string
+	debounce time.Duration
+
+	// wait is held for reading while waiting for an edge, see release
+	wait sync.RWMutex
+
+	mu        sync.Mutex
+	line      lineIO    // line request; nil until the pin is configured
+	direction direction // Cache of the last configured direction
+	pull      gpio.Pull // Cache of the last pull used.
+	edge      gpio.Edge // Cache of the last edge used.
+	lastEdge  time.Time // Kernel timestamp of the last edge
+
+	// noRealtimeClock is set if the kernel can't timestamp the edges with
+	// CLOCK_REALTIME (before 5.11), the time of reading is used then
+	noRealtimeClock bool
+}
+
+// String implements conn.Resource.
+func (p *Pin) String() string {
+	return p.name
+}
+
+// Halt implements conn.Resource.
+//
+// It stops edge detection if enabled and wakes up WaitForEdge.
+func (p *Pin) Halt() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.line != nil {
+		_ = p.line.Wake()
+	}
+	if p.edge == gpio.NoEdge || p.direction != dIn {
+		return nil
+	}
+	if err := p.configure(gpioV2LineFlagInput|pullFlags(p.pull), 0); err != nil {
+		return p.wrap(err)
+	}
+	p.edge = gpio.NoEdge
+	return nil
+}
+
+// Name implements pin.Pin.
+func (p *Pin) Name() string {
+	return p.name
+}
+
+// Number implements pin.Pin.
+func (p *Pin) Number() int {
+	return p.number
+}
+
+// Function implements pin.Pin.
+func (p *Pin) Function() string {
+	return string(p.Func())
+}
+
+// Func implements pin.PinFunc.
+func (p *Pin) Func() pin.Func {
+	info := gpioV2LineInfo{offset: uint32(p.number)}
+	if err := p.chip.f.Ioctl(gpioV2GetLineInfoIoctl, unsafe.Pointer(&info)); err != nil {
+		return pin.Func("ERR")
+	}
+	switch {
+	case info.flags&gpioV2LineFlagInput != 0:
+		if p.Read() {
+			return gpio.IN_HIGH
+		}
+		return gpio.IN_LOW
+	case info.flags&gpioV2LineFlagOutput != 0:
+		if p.Read() {
+			return gpio.OUT_HIGH
+		}
+		return gpio.OUT_LOW
+	}
+	return pin.Func("ERR")
+}
+
+// SupportedFuncs implements pin.PinFunc.
+func (p *Pin) SupportedFuncs() []pin.Func {
+	return []pin.Func{gpio.IN, gpio.OUT}
+}
+
+// SetFunc implements pin.PinFunc.
+func (p *Pin) SetFunc(f pin.Func) error {
+	switch f {
+	case gpio.IN:
+		return p.In(gpio.PullNoChange, gpio.NoEdge)
+	case gpio.OUT_HIGH:
+		return p.Out(gpio.High)
+	case gpio.OUT, gpio.OUT_LOW:
+		return p.Out(gpio.Low)
+	default:
+		return p.wrap(errors.New("unsupported function"))
+	}
+}
+
+// In implements gpio.PinIn.
+//
+// The edges are timestamped by the kernel, see LastEdgeTime.
+func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pull == gpio.PullNoChange {
+		pull = p.pull
+	}
+	flags := gpioV2LineFlagInput | pullFlags(pull)
+	switch edge {
+	case gpio.RisingEdge:
+		flags |= gpioV2LineFlagEdgeRising
+	case gpio.FallingEdge:
+		flags |= gpioV2LineFlagEdgeFalling
+	case gpio.BothEdges:
+		flags |= gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+	}
+
+	var err error
+	if edge != gpio.NoEdge && !p.noRealtimeClock {
+		err = p.configure(flags|gpioV2LineFlagEventClockRealtime, 0)
+		if err == syscall.EINVAL {
+			// CLOCK_REALTIME timestamps are supported since Linux 5.11
+			p.noRealtimeClock = true
+			err = p.configure(flags, 0)
+		}
+	} else {
+		err = p.configure(flags, 0)
+	}
+	if err != nil {
+		return p.wrap(err)
+	}
+	p.direction = dIn
+	p.pull = pull
+	p.edge = edge
+	return nil
+}
+
+// Read implements gpio.PinIn.
+func (p *Pin) Read() gpio.Level {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.line == nil {
+		return gpio.Low
+	}
+
+	values := gpioV2LineValues{mask: 1}
+	if err := p.line.Ioctl(gpioV2LineGetValuesIoctl, unsafe.Pointer(&values)); err != nil {
+		return gpio.Low
+	}
+	return values.bits&1 != 0
+}
+
+// WaitForEdge implements gpio.PinIn.
+//
+// It returns false if it is woken up by Halt. WaitForEdge must not be called
+// concurrently.
+func (p *Pin) WaitForEdge(timeout time.Duration) bool {
+	// The line is closed by release only after the wait
+	p.wait.RLock()
+	defer p.wait.RUnlock()
+
+	p.mu.Lock()
+	line := p.line
+	p.mu.Unlock()
+	if line == nil {
+		return false
+	}
+
+	var ms int
+	if timeout == -1 {
+		ms = -1
+	} else {
+		ms = int(timeout / time.Millisecond)
+	}
+	start := time.Now()
+	for {
+		if nr, err := line.Wait(ms); err == nil {
+			return nr == 1 && p.readEvent(line)
+		} else if err != syscall.EINTR {
+			return false
+		}
+		// A signal occurred.
+		if timeout == -1 {
+			continue
+		}
+		ms = int((timeout - time.Since(start)) / time.Millisecond)
+		if ms <= 0 {
+			return false
+		}
+	}
+}
+
+// LastEdgeTime returns the kernel timestamp of the last edge returned by
+// WaitForEdge.
+func (p *Pin) LastEdgeTime() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastEdge
+}
+
+// Pull implements gpio.PinIn.
+func (p *Pin) Pull() gpio.Pull {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pull
+}
+
+// DefaultPull implements gpio.PinIn.
+//
+// It returns gpio.PullNoChange since the default bias depends on the board.
+func (p *Pin) DefaultPull() gpio.Pull {
+	return gpio.PullNoChange
+}
+
+// Out implements gpio.PinOut.
+func (p *Pin) Out(l gpio.Level) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var bits uint64
+	if l == gpio.High {
+		bits = 1
+	}
+	if p.direction == dOut {
+		values := gpioV2LineValues{bits: bits, mask: 1}
+		if err := p.line.Ioctl(gpioV2LineSetValuesIoctl, unsafe.Pointer(&values)); err != nil {
+			return p.wrap(err)
+		}
+		return nil
+	}
+
+	if err := p.configure(gpioV2LineFlagOutput, bits); err != nil {
+		return p.wrap(err)
+	}
+	p.direction = dOut
+	p.edge = gpio.NoEdge
+	return nil
+}
+
+// PWM implements gpio.PinOut.
+//
+// This is not supported by the GPIO character device.
+func (p *Pin) PWM(gpio.Duty, physic.Frequency) error {
+	return p.wrap(errors.New("pwm is not supported via gpio character device"))
+}
+
+//
+
+// configure requests the line or changes the configuration of the requested
+// line.
+//
+// lock must be held.
+func (p *Pin) configure(flags uint64, outputValue uint64) error {
+	config := gpioV2LineConfig{flags: flags}
+	if flags&gpioV2LineFlagOutput != 0 {
+		config.attrs[config.numAttrs] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIDOutputValues, value: outputValue},
+			mask: 1,
+		}
+		config.numAttrs++
+	}
+	if flags&gpioV2LineFlagInput != 0 && p.debounce > 0 {
+		config.attrs[config.numAttrs] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIDDebounce, value: uint64(p.debounce / time.Microsecond)},
+			mask: 1,
+		}
+		config.numAttrs++
+	}
+
+	if p.line != nil {
+		return p.line.Ioctl(gpioV2LineSetConfigIoctl, unsafe.Pointer(&config))
+	}
+
+	req := gpioV2LineRequest{
+		config:   config,
+		numLines: 1,
+	}
+	req.offsets[0] = uint32(p.number)
+	copy(req.consumer[:len(req.consumer)-1], p.consumer)
+	if err := p.chip.f.Ioctl(gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		return err
+	}
+	line, err := lineOpen(int(req.fd))
+	if err != nil {
+		return err
+	}
+	p.line = line
+	return nil
+}
+
+// release releases the requested line.
+// It wakes up WaitForEdge and closes the line once the wait is over.
+func (p *Pin) release() error {
+	p.mu.Lock()
+	line := p.line
+	if line == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	p.line = nil
+	p.direction = dUnknown
+	p.edge = gpio.NoEdge
+	_ = line.Wake()
+	p.mu.Unlock()
+
+	p.wait.Lock()
+	defer p.wait.Unlock()
+	if err := line.Close(); err != nil {
+		return p.wrap(err)
+	}
+	return nil
+}
+
+// readEvent reads the pending edge event and records its timestamp
+func (p *Pin) readEvent(line lineIO) bool {
+	var event gpioV2LineEvent
+	buf := (*[1 << 10]byte)(unsafe.Pointer(&event))[:gpioV2LineEventSize:gpioV2LineEventSize]
+	if n, err := line.Read(buf); err != nil || n != gpioV2LineEventSize {
+		return false
+	}
+
+	p.mu.Lock()
+	if p.noRealtimeClock {
+		p.lastEdge = time.Now()
+	} else {
+		p.lastEdge = time.Unix(0, int64(event.timestampNs))
+	}
+	p.mu.Unlock()
+	return true
+}
+
+func (p *Pin) wrap(err error) error {
+	return fmt.Errorf("gpiod (%s, %s line %d): %v", p, p.chip.path, p.number, err)
+}
+
+func pullFlags(pull gpio.Pull) uint64 {
+	switch pull {
+	case gpio.Float:
+		return gpioV2LineFlagBiasDisabled
+	case gpio.PullDown:
+		return gpioV2LineFlagBiasPullDown
+	case gpio.PullUp:
+		return gpioV2LineFlagBiasPullUp
+	}
+	return 0
+}
+
+//
+
+type direction int
+
+const (
+	dUnknown direction = 0
+	dIn      direction = 1
+	dOut     direction = 2
+)
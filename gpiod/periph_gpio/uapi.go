@@ -0,0 +1,131 @@
+package periph_gpio
+
+import (
+	"unsafe"
+)
+
+// The GPIO character device ABI (uapi v2) as defined in linux/gpio.h
+
+const (
+	gpioMaxNameSize      = 32
+	gpioV2LinesMax       = 64
+	gpioV2LineNumAttrMax = 10
+)
+
+const (
+	gpioV2LineFlagUsed               uint64 = 1 << 0
+	gpioV2LineFlagActiveLow          uint64 = 1 << 1
+	gpioV2LineFlagInput              uint64 = 1 << 2
+	gpioV2LineFlagOutput             uint64 = 1 << 3
+	gpioV2LineFlagEdgeRising         uint64 = 1 << 4
+	gpioV2LineFlagEdgeFalling        uint64 = 1 << 5
+	gpioV2LineFlagOpenDrain          uint64 = 1 << 6
+	gpioV2LineFlagOpenSource         uint64 = 1 << 7
+	gpioV2LineFlagBiasPullUp         uint64 = 1 << 8
+	gpioV2LineFlagBiasPullDown       uint64 = 1 << 9
+	gpioV2LineFlagBiasDisabled       uint64 = 1 << 10
+	gpioV2LineFlagEventClockRealtime uint64 = 1 << 11
+
+	gpioV2LineFlagBiasMask = gpioV2LineFlagBiasPullUp | gpioV2LineFlagBiasPullDown | gpioV2LineFlagBiasDisabled
+	gpioV2LineFlagEdgeMask = gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+)
+
+const (
+	gpioV2LineAttrIDFlags        uint32 = 1
+	gpioV2LineAttrIDOutputValues uint32 = 2
+	gpioV2LineAttrIDDebounce     uint32 = 3
+)
+
+const (
+	gpioV2LineEventRisingEdge  uint32 = 1
+	gpioV2LineEventFallingEdge uint32 = 2
+)
+
+type gpioChipInfo struct {
+	name  [gpioMaxNameSize]byte
+	label [gpioMaxNameSize]byte
+	lines uint32
+}
+
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	// flags, values or debounce_period_us depending on id
+	value uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [gpioV2LineNumAttrMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [gpioV2LinesMax]uint32
+	consumer        [gpioMaxNameSize]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineInfo struct {
+	name     [gpioMaxNameSize]byte
+	consumer [gpioMaxNameSize]byte
+	offset   uint32
+	numAttrs uint32
+	flags    uint64
+	attrs    [gpioV2LineNumAttrMax]gpioV2LineAttribute
+	padding  [4]uint32
+}
+
+type gpioV2LineEvent struct {
+	timestampNs uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+const (
+	iocWrite = 1
+	iocRead  = 2
+)
+
+func ioc(dir, nr, size uintptr) uint {
+	const gpioIocType = 0xB4
+	return uint(dir<<30 | size<<16 | gpioIocType<<8 | nr)
+}
+
+var (
+	gpioGetChipInfoIoctl     = ioc(iocRead, 0x01, unsafe.Sizeof(gpioChipInfo{}))
+	gpioV2GetLineInfoIoctl   = ioc(iocRead|iocWrite, 0x05, unsafe.Sizeof(gpioV2LineInfo{}))
+	gpioV2GetLineIoctl       = ioc(iocRead|iocWrite, 0x07, unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2LineSetConfigIoctl = ioc(iocRead|iocWrite, 0x0D, unsafe.Sizeof(gpioV2LineConfig{}))
+	gpioV2LineGetValuesIoctl = ioc(iocRead|iocWrite, 0x0E, unsafe.Sizeof(gpioV2LineValues{}))
+	gpioV2LineSetValuesIoctl = ioc(iocRead|iocWrite, 0x0F, unsafe.Sizeof(gpioV2LineValues{}))
+	gpioV2LineEventSize      = int(unsafe.Sizeof(gpioV2LineEvent{}))
+)
+
+// cString converts the NUL terminated buffer to string
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
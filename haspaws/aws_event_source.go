@@ -5,11 +5,13 @@ import "C"
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/krig/go-sox"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -17,13 +19,100 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/lexruntimeservice"
 	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/metrics"
 	"github.com/rmcsoft/hasp/sound"
 )
 
+var (
+	lexRequestDurationHistogram = metrics.NewHistogram("hasp_lex_request_duration_seconds",
+		"Latency of runtime.lex requests", metrics.DefaultDurationBuckets)
+	lexRequestErrorCounter = metrics.NewCounter("hasp_lex_request_errors_total",
+		"Number of failed runtime.lex requests")
+)
+
+// lexTextReplyFormat is the format of the speech replied to the text,
+// Lex replies with 16 kHz mono PCM
+var lexTextReplyFormat = sound.AudioFormat{
+	ChannelCount: 1,
+	SampleType:   sound.S16LE,
+	SampleRate:   16000,
+}
+
+// OfflineBackend answers the utterance when runtime.lex is unreachable.
+// It returns the event of the reply as the ones of LexEventSource.
+type OfflineBackend interface {
+	Reply(audioData *sound.AudioData, text string) *events.Event
+}
+
+// FAQBackend answers the frequent questions on the device before
+// runtime.lex is requested. It returns the event of the answer as the ones
+// of LexEventSource or nil to pass the utterance to runtime.lex, and the
+// transcript of the utterance if it is known.
+type FAQBackend interface {
+	Answer(audioData *sound.AudioData, text string) (*events.Event, string)
+}
+
+// LexBot is the bot of runtime.lex and its alias
+type LexBot struct {
+	Name  string
+	Alias string
+}
+
+// DefaultLexBot is the bot requested if LexParams.Bot is not set
+var DefaultLexBot = LexBot{Name: "HASPBot", Alias: "$LATEST"}
+
+const (
+	// DefaultLexTimeout is the time limit of the request if
+	// LexParams.Timeout is not set
+	DefaultLexTimeout = 10 * time.Second
+	// DefaultLexMaxRetries is the number of the retries of the failed
+	// request made by NewLexEventSource
+	DefaultLexMaxRetries = 1
+)
+
+// LexParams are the parameters of the requests to runtime.lex
+type LexParams struct {
+	Client *lexruntimeservice.Client
+	// Bot is optional, e.g. the bot of the conversation language.
+	// DefaultLexBot is used if it is not set.
+	Bot LexBot
+	// Identity is sent as the request attributes
+	Identity KioskIdentity
+	// Session is optional. If set, its attributes are sent and it is
+	// updated with the reply before the reply event is sent; it must not
+	// be used until then.
+	Session *Session
+	// TextToSpeech speaks the replies without audio, e.g. the messages set
+	// by a Lambda only; they are not spoken if nil
+	TextToSpeech sound.TextToSpeech
+	// FAQ is optional. If set, the utterances it answers are not sent;
+	// it is not asked in the middle of a dialog of the Session.
+	FAQ FAQBackend
+	// Offline is optional. If set, it answers when the request fails
+	// instead of saying goodbye.
+	Offline OfflineBackend
+	// Timeout is the time limit of the request including its retries and
+	// the reading of the reply, DefaultLexTimeout if it is not set
+	Timeout time.Duration
+	// MaxRetries caps the retries of the failed request, the request is not
+	// retried if it is 0
+	MaxRetries int
+	Debug      bool
+}
+
 type awsLexRuntime struct {
 	eventChan          chan *events.Event
 	lrs                *lexruntimeservice.Client
+	bot                LexBot
+	identity           KioskIdentity
+	session            *Session
+	tts                sound.TextToSpeech
+	faq                FAQBackend
+	offline            OfflineBackend
+	timeout            time.Duration
+	maxRetries         int
 	audioData          *sound.AudioData
+	text               string
 	repliedAudioFormat sound.AudioFormat
 	userId             string
 	debug              bool
@@ -32,19 +121,68 @@ type awsLexRuntime struct {
 // NewLexEventSource creates LexEventSource
 func NewLexEventSource(lrs *lexruntimeservice.Client,
 	audioData *sound.AudioData, userId string, debug bool) (events.EventSource, error) {
-	h := &awsLexRuntime{
-		eventChan:          make(chan *events.Event),
-		lrs:                lrs,
-		audioData:          audioData,
-		userId:             userId,
-		repliedAudioFormat: audioData.Format(),
-		debug:              debug,
+	return NewLexEventSourceWithParams(LexParams{
+		Client:     lrs,
+		MaxRetries: DefaultLexMaxRetries,
+		Debug:      debug,
+	}, audioData, userId)
+}
+
+// NewLexEventSourceWithParams creates LexEventSource posting the speech
+func NewLexEventSourceWithParams(params LexParams,
+	audioData *sound.AudioData, userId string) (events.EventSource, error) {
+	if audioData == nil {
+		return nil, errors.New("No speech to post")
 	}
 
+	h := newLexRuntime(params, userId)
+	h.audioData = audioData
+	h.repliedAudioFormat = audioData.Format()
+
 	go h.run()
 	return h, nil
 }
 
+// NewLexTextEventSource creates LexEventSource posting the text utterance,
+// e.g. typed, tapped or scanned. The reply is spoken as for the speech.
+func NewLexTextEventSource(params LexParams, text string, userId string) (events.EventSource, error) {
+	h := newLexRuntime(params, userId)
+	h.text = text
+	h.repliedAudioFormat = lexTextReplyFormat
+
+	go h.run()
+	return h, nil
+}
+
+func newLexRuntime(params LexParams, userId string) *awsLexRuntime {
+	bot := params.Bot
+	if bot.Name == "" {
+		bot.Name = DefaultLexBot.Name
+	}
+	if bot.Alias == "" {
+		bot.Alias = DefaultLexBot.Alias
+	}
+	timeout := params.Timeout
+	if timeout <= 0 {
+		timeout = DefaultLexTimeout
+	}
+
+	return &awsLexRuntime{
+		eventChan:  make(chan *events.Event),
+		lrs:        params.Client,
+		bot:        bot,
+		identity:   params.Identity,
+		session:    params.Session,
+		tts:        params.TextToSpeech,
+		faq:        params.FAQ,
+		offline:    params.Offline,
+		timeout:    timeout,
+		maxRetries: params.MaxRetries,
+		userId:     userId,
+		debug:      params.Debug,
+	}
+}
+
 func (h *awsLexRuntime) Name() string {
 	return "AwsLexRuntime"
 }
@@ -57,39 +195,57 @@ func (h *awsLexRuntime) Close() {
 }
 
 func (h *awsLexRuntime) sendRequest() ([]byte, *lexruntimeservice.PostContentResponse, error) {
-	req := h.lrs.PostContentRequest(
-		&lexruntimeservice.PostContentInput{
-			BotAlias:    aws.String("$LATEST"),
-			BotName:     aws.String("HASPBot"),
-			ContentType: aws.String(h.audioData.Mime()),
-			UserId:      aws.String(h.userId),
-			InputStream: h.makeInputStream(),
-			Accept:      aws.String("audio/pcm"),
-		})
+	input := &lexruntimeservice.PostContentInput{
+		BotAlias:          aws.String(h.bot.Alias),
+		BotName:           aws.String(h.bot.Name),
+		ContentType:       aws.String(h.contentType()),
+		UserId:            aws.String(h.userId),
+		InputStream:       h.makeInputStream(),
+		Accept:            aws.String("audio/pcm"),
+		RequestAttributes: jsonValue(h.identity.Attributes()),
+	}
+	if h.session != nil {
+		input.SessionAttributes = jsonValue(h.session.Attributes)
+	}
+	req := h.lrs.PostContentRequest(input)
+	req.Retryer = aws.DefaultRetryer{NumMaxRetries: h.maxRetries}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
 
 	log.Debug("Sending request to runtime.lex")
-	resp, err := req.Send(context.TODO())
+	sendTime := time.Now()
+	resp, err := req.Send(ctx)
+	lexRequestDurationHistogram.ObserveDuration(time.Since(sendTime))
 	if err != nil {
+		lexRequestErrorCounter.Inc()
 		log.Errorf("Failed to send request to runtime.lex: %v", err)
 
 		return nil, nil, fmt.Errorf("Failed to send request to runtime.lex: %v", err)
 	}
 
-	log.Trace("Response runtime.lex: %v", resp)
+	log.Tracef("Response runtime.lex: %v", resp)
 	if resp.InputTranscript != nil {
-		log.Infof("InputTranscript: ", *resp.InputTranscript)
+		log.Infof("InputTranscript: %s", *resp.InputTranscript)
 	}
 	if resp.Message != nil {
-		log.Infof("Message: ", *resp.Message)
+		log.Infof("Message: %s", *resp.Message)
 	}
 
+	// The text-only reply is spoken by the text-to-speech, see repliedSpeech
+	hasMessage := len(aws.StringValue(resp.Message)) != 0
 	if resp.AudioStream == nil {
+		if hasMessage {
+			return nil, resp, nil
+		}
+		lexRequestErrorCounter.Inc()
 		log.Errorf("Response from runtime.lex does not contain AudioStream")
 		return nil, nil, fmt.Errorf("Response from runtime.lex does not contain AudioStream")
 	}
 
 	samples, err := ioutil.ReadAll(resp.AudioStream)
-	if err != nil || len(samples) == 0 {
+	if err != nil || (len(samples) == 0 && !hasMessage) {
+		lexRequestErrorCounter.Inc()
 		log.Errorf("Unable to read audio data from the runtime.lex response")
 		return nil, nil, fmt.Errorf("Unable to read audio data from the runtime.lex response")
 	}
@@ -107,10 +263,26 @@ func (h *awsLexRuntime) sendRequest() ([]byte, *lexruntimeservice.PostContentRes
 func (h *awsLexRuntime) run() {
 	defer close(h.eventChan)
 
+	// The transcript of the speech is kept to not recognize it once again
+	// offline, the speech itself is still sent to runtime.lex
+	transcript := h.text
+	if h.faq != nil && (h.session == nil || !h.session.InDialog()) {
+		var answer *events.Event
+		if answer, transcript = h.faq.Answer(h.audioData, h.text); answer != nil {
+			h.eventChan <- answer
+			return
+		}
+	}
+
 	samples, resp, err := h.sendRequest()
+	if err != nil && h.offline != nil {
+		log.Warn("runtime.lex is unreachable, answering offline")
+		h.eventChan <- h.offline.Reply(h.audioData, transcript)
+		return
+	}
 	if err != nil {
 		log.Error(" ============ >>>>>>>>>>>> AWS error!!! Giving up.")
-		h.gotStop(nil) // TODO: Reaction to an error
+		h.gotStop(AwsRepliedEventData{}) // TODO: Reaction to an error
 		return
 		/*
 			// NOT-A-FIX! This workaround is here just to understand the problem better!
@@ -122,17 +294,25 @@ func (h *awsLexRuntime) run() {
 				samples, resp, err = h.sendRequest()
 				if err != nil {
 					log.Error(" ===>>> 3 errors already!!! Giving up")
-					h.gotStop(nil) // TODO: Reaction to an error
+					h.gotStop(AwsRepliedEventData{}) // TODO: Reaction to an error
 					return
 				}
 			}
 		*/
 	}
-	repliedSpeech := sound.NewAudioData(h.repliedAudioFormat, samples)
+	if h.session != nil {
+		h.session.update(resp)
+	}
+
+	replied := AwsRepliedEventData{
+		RepliedSpeech:   h.repliedSpeech(samples, aws.StringValue(resp.Message)),
+		InputTranscript: aws.StringValue(resp.InputTranscript),
+		Message:         aws.StringValue(resp.Message),
+	}
 
 	if resp.IntentName == nil {
 		log.Debug("GOT: EMPTY Intent; State=", resp.DialogState)
-		h.gotReply(repliedSpeech)
+		h.gotReply(replied)
 		return
 	}
 
@@ -140,52 +320,68 @@ func (h *awsLexRuntime) run() {
 	switch *resp.IntentName {
 	case "StopInteraction", "NoThankYou":
 		log.Debug("stopping...")
-		h.gotStop(repliedSpeech)
+		h.gotStop(replied)
 	case "Hell":
 		log.Debug("stopping...")
-		h.gotStop(nil)
+		h.gotStop(AwsRepliedEventData{})
 	case "AxeOso", "Catawba", "Codescape", "DontKnowTheLastName", "Event", "Goodbye", "ThankYou", "TourSubscription", "TradeLore":
 		if resp.DialogState == "Fulfilled" {
 			log.Debug("stopping...")
-			h.gotStop(repliedSpeech)
+			h.gotStop(replied)
 		} else {
 			log.Debug("reply...")
-			h.gotReply(repliedSpeech)
+			h.gotReply(replied)
 		}
 	case "Company", "ContactAdvent", "HowCanIhelpYou", "Delivery", "Chatter", "NoNameMeeting", "NoNameDelivery", "RepeatPhoneNumber", "SmthUnclear", "Mistake", "WebsitePhoneNumber", "WhatIsYourName":
 		log.Debug("reply...")
-		h.gotReply(repliedSpeech)
+		h.gotReply(replied)
 
 	case "Meeting":
 		if resp.DialogState == "ConfirmIntent" {
 			log.Debug("meeting confirmation...")
-			h.gotConfirmation(repliedSpeech)
+			h.gotConfirmation(replied)
 		} else if resp.DialogState == "Fulfilled" {
 			log.Debug("meeting fullfilled...")
-			h.gotCall(repliedSpeech)
+			h.gotCall(replied)
 		} else {
 			log.Debug("reply...")
-			h.gotReply(repliedSpeech)
+			h.gotReply(replied)
 		}
 	default:
 		log.Debug("reply...")
-		h.gotReply(repliedSpeech)
+		h.gotReply(replied)
 	}
 }
 
-func (h *awsLexRuntime) gotReply(data *sound.AudioData) {
+// repliedSpeech returns the samples replied or the speech synthesized for
+// the message if there are none
+func (h *awsLexRuntime) repliedSpeech(samples []byte, message string) *sound.AudioData {
+	if len(samples) != 0 || len(message) == 0 || h.tts == nil {
+		return sound.NewAudioData(h.repliedAudioFormat, samples)
+	}
+
+	log.Debug("Synthesizing the reply without audio")
+	speech, err := h.tts.Synthesize(message)
+	if err != nil {
+		log.Errorf("Failed to synthesize the reply: %v", err)
+		return sound.NewAudioData(h.repliedAudioFormat, nil)
+	}
+	return speech
+}
+
+func (h *awsLexRuntime) gotReply(data AwsRepliedEventData) {
 	h.eventChan <- NewAwsRepliedEvent(data)
 }
 
-func (h *awsLexRuntime) gotStop(repliedSpeech *sound.AudioData) {
-	h.eventChan <- sound.NewStopEvent(repliedSpeech)
+func (h *awsLexRuntime) gotStop(data AwsRepliedEventData) {
+	h.eventChan <- sound.NewStopEventWithText(data.RepliedSpeech, data.InputTranscript, data.Message)
 }
 
-func (h *awsLexRuntime) gotCall(data *sound.AudioData) {
+func (h *awsLexRuntime) gotCall(data AwsRepliedEventData) {
 	h.eventChan <- NewAwsRepliedEventState(data, AwsRepliedCallEventName)
 }
 
-func (h *awsLexRuntime) gotConfirmation(data *sound.AudioData) {
+func (h *awsLexRuntime) gotConfirmation(data AwsRepliedEventData) {
 	h.eventChan <- NewAwsRepliedEventState(data, AwsRepliedTypeEventName)
 }
 
@@ -251,7 +447,18 @@ func preprocessSamplesWithSox(audioSamples []byte) []byte {
 	return buf.Bytes()
 }
 
+func (h *awsLexRuntime) contentType() string {
+	if h.audioData == nil {
+		return "text/plain; charset=utf-8"
+	}
+	return h.audioData.Mime()
+}
+
 func (h *awsLexRuntime) makeInputStream() io.ReadSeeker {
+	if h.audioData == nil {
+		return strings.NewReader(h.text)
+	}
+
 	audioSamples := h.audioData.Samples()
 
 	processedSamples := preprocessSamplesWithSox(audioSamples)
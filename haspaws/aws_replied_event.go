@@ -10,6 +10,10 @@ import (
 
 type AwsRepliedEventData struct {
 	RepliedSpeech *sound.AudioData
+	// InputTranscript is what the bot recognized in the user speech
+	InputTranscript string
+	// Message is the text of the replied speech
+	Message string
 }
 
 const (
@@ -20,20 +24,15 @@ const (
 )
 
 // NewAwsRepliedEvent creates RepliedEvent
-func NewAwsRepliedEvent(repliedSpeech *sound.AudioData) *events.Event {
-	return &events.Event{
-		Name: AwsRepliedEventName,
-		Args: []interface{}{
-			AwsRepliedEventData{repliedSpeech},
-		},
-	}
+func NewAwsRepliedEvent(data AwsRepliedEventData) *events.Event {
+	return NewAwsRepliedEventState(data, AwsRepliedEventName)
 }
 
-func NewAwsRepliedEventState(repliedSpeech *sound.AudioData, name string) *events.Event {
+func NewAwsRepliedEventState(data AwsRepliedEventData, name string) *events.Event {
 	return &events.Event{
 		Name: name,
 		Args: []interface{}{
-			AwsRepliedEventData{repliedSpeech},
+			data,
 		},
 	}
 }
@@ -0,0 +1,36 @@
+package haspaws
+
+// The names of the request attributes of KioskIdentity
+const (
+	SiteAttribute     = "Site"
+	KioskIDAttribute  = "KioskId"
+	TimeZoneAttribute = "TimeZone"
+	LanguageAttribute = "Language"
+)
+
+// KioskIdentity identifies the kiosk and its location for the fulfillment of
+// the bot, e.g. to look up the members of the site only
+type KioskIdentity struct {
+	Site     string
+	KioskID  string
+	TimeZone string
+	// Language is the conversation language
+	Language string
+}
+
+// Attributes returns the request attributes of the identity,
+// the empty ones are omitted
+func (k KioskIdentity) Attributes() map[string]string {
+	attributes := make(map[string]string)
+	for name, value := range map[string]string{
+		SiteAttribute:     k.Site,
+		KioskIDAttribute:  k.KioskID,
+		TimeZoneAttribute: k.TimeZone,
+		LanguageAttribute: k.Language,
+	} {
+		if value != "" {
+			attributes[name] = value
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,99 @@
+package haspaws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lexruntimeservice"
+)
+
+// Session is the conversation with runtime.lex carried across the turns
+type Session struct {
+	// Attributes are the session attributes. They are sent with each
+	// request and replaced with the replied ones.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Intent, Slots, DialogState and SlotToElicit are of the last reply
+	Intent       string            `json:"intent,omitempty"`
+	Slots        map[string]string `json:"slots,omitempty"`
+	DialogState  string            `json:"dialogState,omitempty"`
+	SlotToElicit string            `json:"slotToElicit,omitempty"`
+	// Turns is the number of the utterances of the visitor
+	Turns int `json:"turns"`
+}
+
+// NewSession creates new Session
+func NewSession() *Session {
+	return &Session{
+		Attributes: make(map[string]string),
+		Slots:      make(map[string]string),
+	}
+}
+
+// Clone returns a copy of the session
+func (s *Session) Clone() *Session {
+	clone := *s
+	clone.Attributes = copyStrings(s.Attributes)
+	clone.Slots = copyStrings(s.Slots)
+	return &clone
+}
+
+// Slot returns the value of the slot, it is empty if the slot is not filled
+func (s *Session) Slot(name string) string {
+	return s.Slots[name]
+}
+
+// Attribute returns the value of the session attribute
+func (s *Session) Attribute(name string) string {
+	return s.Attributes[name]
+}
+
+// InDialog reports whether the bot is in the middle of an intent, e.g.
+// eliciting a slot. The dialog is over once the intent is fulfilled.
+func (s *Session) InDialog() bool {
+	return s.DialogState != "" && s.DialogState != string(lexruntimeservice.DialogStateFulfilled)
+}
+
+// update takes the attributes, the intent and the slots of the reply
+func (s *Session) update(resp *lexruntimeservice.PostContentResponse) {
+	s.Attributes = jsonStrings(resp.SessionAttributes)
+	s.Intent = aws.StringValue(resp.IntentName)
+	s.Slots = jsonStrings(resp.Slots)
+	s.DialogState = string(resp.DialogState)
+	s.SlotToElicit = aws.StringValue(resp.SlotToElicit)
+}
+
+// jsonValue converts the attributes to send, it is nil if there are none
+func jsonValue(attributes map[string]string) aws.JSONValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+	value := make(aws.JSONValue, len(attributes))
+	for k, v := range attributes {
+		value[k] = v
+	}
+	return value
+}
+
+// jsonStrings converts the JSON object of Lex to strings,
+// the null values of the unfilled slots are dropped
+func jsonStrings(value aws.JSONValue) map[string]string {
+	result := make(map[string]string, len(value))
+	for k, v := range value {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			result[k] = v
+		default:
+			result[k] = fmt.Sprint(v)
+		}
+	}
+	return result
+}
+
+func copyStrings(m map[string]string) map[string]string {
+	clone := make(map[string]string, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
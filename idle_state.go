@@ -1,6 +1,9 @@
 package hasp
 
 import (
+	"fmt"
+	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -8,100 +11,142 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/schedule"
 	"github.com/rmcsoft/hasp/sound"
-	"periph.io/x/periph"
 	"periph.io/x/periph/conn/gpio"
-	"periph.io/x/periph/conn/gpio/gpioreg"
-	"periph.io/x/periph/host"
 )
 
 type idleState struct {
 	availableAnimations []string
+	animationWeights    map[string]float64
+	random              *rand.Rand
 	animationDuration   time.Duration
 	currentAnimation    int
 	hotWordDetector     *sound.HotWordDetector
 	sensorsPins         []gpio.PinIO
+	scheduler           *schedule.Scheduler
+	presence            events.PresenceDetector
 }
 
-func atmelLoaded(state *periph.State) bool {
-	for _, a := range state.Loaded {
-		if atmel.AtmelGpioDriverName == a.String() {
-			return true
-		}
-	}
-	return false
-}
+// IdleStateParams IdleState params
+type IdleStateParams struct {
+	AvailableAnimations []string
+	AnimationDuration   time.Duration
+	HotWordDetector     *sound.HotWordDetector
+	SensorsPins         atmel.AtmelGpioPins
 
-var isAtmelDriverLoaded = false
+	// AnimationWeights is optional. If set, the idle animations are picked
+	// at random with the given relative weights (1 if missing) instead of
+	// round-robin, see AnimationManifest.Weights.
+	AnimationWeights map[string]float64
 
-func loadAtmelPeriph(sensorsPins atmel.AtmelGpioPins) []gpio.PinIO {
-	if !isAtmelDriverLoaded {
-		if err := periph.Register(atmel.AtmelGpioDriver{
-			Pins: sensorsPins,
-		}); err != nil {
-			logrus.Error(err)
-			logrus.Info("Will not use GPIO sensors detection")
-			return nil
-		}
+	// Scheduler is optional. If set, the idle animations and the hot word
+	// handling are taken from the current mode settings, and scheduled
+	// announcements are emitted while the state is active.
+	Scheduler *schedule.Scheduler
 
-		// Initialize normally. Your driver will be loaded:
-		state, err := host.Init()
-		if err != nil {
-			logrus.Error(err)
-			return nil
-		}
-
-		if !atmelLoaded(state) {
-			logrus.Info("Will not use GPIO sensors detection")
-			return nil
-		}
-		isAtmelDriverLoaded = true
-	}
-
-	atmelPins := make([]gpio.PinIO, len(sensorsPins))
-	for i := 0; i < len(sensorsPins); i++ {
-		atmelPins[i] = gpioreg.ByName(sensorsPins[i].Name)
-		if atmelPins[i] == nil {
-			logrus.Error("Failed to open pin ", sensorsPins[i].Name)
-		} else {
-			err := atmelPins[i].In(gpio.PullNoChange, gpio.NoEdge)
-			if err != nil {
-				logrus.Error(err)
-			} else {
-				logrus.Debug("GPIO ", sensorsPins[i].Name, " is ready")
-			}
-		}
-	}
-	return atmelPins
+	// Presence is optional. If set, the state gets presence events
+	// instead of the GpioEvent fired when all the sensors are HIGH.
+	Presence events.PresenceDetector
 }
 
 // NewIdleState creates new IdleState
 func NewIdleState(availableAnimations []string, animationDuration time.Duration,
 	hotWordDetector *sound.HotWordDetector, sensorsPins atmel.AtmelGpioPins) State {
+	return NewIdleStateWithParams(IdleStateParams{
+		AvailableAnimations: availableAnimations,
+		AnimationDuration:   animationDuration,
+		HotWordDetector:     hotWordDetector,
+		SensorsPins:         sensorsPins,
+	})
+}
 
-	atmelPins := loadAtmelPeriph(sensorsPins)
+// NewIdleStateWithParams creates new IdleState
+func NewIdleStateWithParams(params IdleStateParams) State {
+	var atmelPins []gpio.PinIO
+	if params.Presence == nil {
+		atmelPins = openInputPins(params.SensorsPins)
+	}
 
 	return &idleState{
-		availableAnimations: availableAnimations,
-		animationDuration:   animationDuration,
-		hotWordDetector:     hotWordDetector,
+		availableAnimations: params.AvailableAnimations,
+		animationWeights:    params.AnimationWeights,
+		random:              rand.New(rand.NewSource(time.Now().UnixNano())),
+		animationDuration:   params.AnimationDuration,
+		hotWordDetector:     params.HotWordDetector,
 		sensorsPins:         atmelPins,
+		scheduler:           params.Scheduler,
+		presence:            params.Presence,
 	}
 }
 
-func (s *idleState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
-	detectorEventSource, err := s.hotWordDetector.StartDetect()
-	if err != nil {
-		return nil, err
+// NewPresenceMonitor creates PresenceMonitor watching the left and right sensors.
+// It returns nil if the sensors are not available.
+func NewPresenceMonitor(sensorsPins atmel.AtmelGpioPins, params events.PresenceParams) events.PresenceDetector {
+	atmelPins := openInputPins(sensorsPins)
+	if len(atmelPins) != 2 || atmelPins[0] == nil || atmelPins[1] == nil {
+		logrus.Info("Will not use presence detection")
+		return nil
 	}
+	return events.NewPresenceMonitor(atmelPins[0], atmelPins[1], params)
+}
 
-	return events.EventSources{
-		events.NewGpioEventSource(s.sensorsPins),
+// NewGpioPresenceSensor creates the presence sensor of the digital sensors,
+// e.g. PIR sensors, connected to the pins
+func NewGpioPresenceSensor(sensorsPins atmel.AtmelGpioPins) (events.PresenceSensor, error) {
+	atmelPins := openInputPins(sensorsPins)
+	if len(atmelPins) == 0 {
+		return nil, fmt.Errorf("GPIO is not available")
+	}
+	for i, pin := range atmelPins {
+		if pin == nil {
+			return nil, fmt.Errorf("GPIO pin %s is not available", sensorsPins[i].Name)
+		}
+	}
+	return events.NewGpioPresenceSensor(atmelPins...), nil
+}
+
+// NewSensorMonitor creates SensorMonitor polling the sensors.
+// It returns nil if there are no sensors.
+func NewSensorMonitor(sensors []events.PresenceSensor, params events.SensorPresenceParams) events.PresenceDetector {
+	if len(sensors) == 0 {
+		logrus.Info("Will not use presence detection")
+		return nil
+	}
+	return events.NewSensorMonitor(sensors, params)
+}
+
+func (s *idleState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
+	// The next visitor is greeted in the default language
+	delete(ctx, CtxLanguage)
+
+	sources := events.EventSources{
 		&changeAnimationEventSource{
 			period: s.animationDuration,
 		},
-		detectorEventSource,
-	}, nil
+	}
+	if s.presence != nil {
+		// The visitor who approached while the character was busy
+		// is greeted now
+		s.presence.Rearm()
+		sources = append(sources, s.presence.EventSource())
+	} else {
+		sources = append(sources, events.NewGpioEventSource(s.sensorsPins))
+	}
+
+	if s.scheduler == nil {
+		detectorEventSource, err := s.hotWordDetector.StartDetect()
+		if err != nil {
+			return nil, err
+		}
+		return append(sources, detectorEventSource), nil
+	}
+
+	return append(sources,
+		newScheduledHotWordEventSource(s.hotWordDetector, s.scheduler),
+		schedule.NewModeChangeEventSource(s.scheduler),
+		schedule.NewAnnouncementEventSource(s.scheduler),
+	), nil
 }
 
 func (s *idleState) Leave(ctx CharacterCtx, event events.Event) bool {
@@ -109,11 +154,52 @@ func (s *idleState) Leave(ctx CharacterCtx, event events.Event) bool {
 }
 
 func (s *idleState) GetAnimation() string {
-	animation := s.availableAnimations[s.currentAnimation]
-	s.currentAnimation = (s.currentAnimation + 1) % len(s.availableAnimations)
+	availableAnimations := s.availableAnimations
+	if s.scheduler != nil {
+		if modeAnimations := s.scheduler.CurrentSettings().IdleAnimations; len(modeAnimations) > 0 {
+			availableAnimations = modeAnimations
+		}
+	}
+
+	if s.animationWeights != nil {
+		if animation, ok := s.pickWeighted(availableAnimations); ok {
+			return animation
+		}
+	}
+
+	s.currentAnimation = s.currentAnimation % len(availableAnimations)
+	animation := availableAnimations[s.currentAnimation]
+	s.currentAnimation = (s.currentAnimation + 1) % len(availableAnimations)
 	return animation
 }
 
+// pickWeighted picks a random animation.
+// It returns false if all the weights are zero.
+func (s *idleState) pickWeighted(availableAnimations []string) (string, bool) {
+	weight := func(animation string) float64 {
+		if w, ok := s.animationWeights[animation]; ok {
+			return w
+		}
+		return 1
+	}
+
+	total := 0.0
+	for _, animation := range availableAnimations {
+		total += weight(animation)
+	}
+	if total <= 0 {
+		return "", false
+	}
+
+	r := s.random.Float64() * total
+	for _, animation := range availableAnimations {
+		if r -= weight(animation); r < 0 {
+			return animation, true
+		}
+	}
+	return availableAnimations[len(availableAnimations)-1], true
+}
+
 func (s *idleState) GetSound() *sound.AudioData {
 	return nil
 }
@@ -141,3 +227,104 @@ func (c *changeAnimationEventSource) Events() chan *events.Event {
 func (c *changeAnimationEventSource) Close() {
 	atomic.StoreInt32(&c.stopFlag, 1)
 }
+
+// scheduledHotWordEventSource runs hot word detection according to the current
+// schedule mode: hot words are ignored if the mode disables them and are renamed
+// if the mode sets its own hot word event.
+type scheduledHotWordEventSource struct {
+	detector  *sound.HotWordDetector
+	scheduler *schedule.Scheduler
+	eventChan chan *events.Event
+
+	mutex   sync.Mutex
+	closed  bool
+	session events.EventSource
+}
+
+func newScheduledHotWordEventSource(detector *sound.HotWordDetector,
+	scheduler *schedule.Scheduler) events.EventSource {
+
+	es := &scheduledHotWordEventSource{
+		detector:  detector,
+		scheduler: scheduler,
+		eventChan: make(chan *events.Event),
+	}
+	go es.run()
+	return es
+}
+
+func (es *scheduledHotWordEventSource) Name() string {
+	return "ScheduledHotWordDetector"
+}
+
+func (es *scheduledHotWordEventSource) Events() chan *events.Event {
+	return es.eventChan
+}
+
+func (es *scheduledHotWordEventSource) Close() {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	es.closed = true
+	if es.session != nil {
+		es.session.Close()
+		es.session = nil
+	}
+}
+
+func (es *scheduledHotWordEventSource) startSession() events.EventSource {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	if es.closed {
+		return nil
+	}
+
+	session, err := es.detector.StartDetect()
+	if err != nil {
+		logrus.Errorf("Failed to start hot word detection: %v", err)
+		return nil
+	}
+	es.session = session
+	return session
+}
+
+func (es *scheduledHotWordEventSource) finishSession(session events.EventSource) {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	if es.session == session {
+		es.session.Close()
+		es.session = nil
+	}
+}
+
+func (es *scheduledHotWordEventSource) run() {
+	defer close(es.eventChan)
+
+	for {
+		session := es.startSession()
+		if session == nil {
+			return
+		}
+
+		ignored := false
+		for event := range session.Events() {
+			settings := es.scheduler.CurrentSettings()
+			if settings.DisableHotWord {
+				logrus.Info("Hot word ignored by the schedule mode")
+				ignored = true
+				continue
+			}
+			if len(settings.HotWordEvent) != 0 {
+				event = &events.Event{Name: settings.HotWordEvent, Args: event.Args}
+			}
+			es.eventChan <- event
+		}
+		es.finishSession(session)
+
+		if !ignored {
+			return
+		}
+	}
+}
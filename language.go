@@ -0,0 +1,69 @@
+package hasp
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/sound"
+)
+
+const (
+	// CtxLanguage is the code of the conversation language.
+	// It is not set while the default language is talked.
+	CtxLanguage = "Language"
+)
+
+// Language returns the conversation language of the context,
+// it is empty for the default language
+func Language(ctx CharacterCtx) string {
+	language, _ := ctx[CtxLanguage].(string)
+	return language
+}
+
+// Prompts are the speeches of a prompt keyed by language,
+// the speech of the empty language is the default one
+type Prompts map[string]*sound.AudioData
+
+// Get returns the speech of the language or the default speech
+func (p Prompts) Get(language string) *sound.AudioData {
+	if speech, ok := p[language]; ok {
+		return speech
+	}
+	return p[""]
+}
+
+// SetKeywordLanguages sets the languages selected by the keywords of the
+// hot word detector, see sound.HotWordDetectorParams.KeywordPaths.
+// The empty language is the default one.
+func (c *Character) SetKeywordLanguages(languages []string) {
+	c.keywordLanguages = languages
+}
+
+// selectLanguage stores the language selected by the event in the context:
+// the language of the detected keyword or of LanguageSelectedEvent.
+// It is called when the event has led to a state, before the state is
+// entered, so the events rejected by the FSM don't switch the language.
+func (c *Character) selectLanguage(event *events.Event) {
+	language, ok := "", false
+	switch event.Name {
+	case events.LanguageSelectedEventName:
+		if data, err := events.GetLanguageSelectedEventData(event); err == nil {
+			language, ok = data.Language, true
+		}
+	case sound.HotWordDetectedEventName, sound.HotWordWithDataDetectedEventName:
+		data, err := sound.GetHotWordDetectedEventData(event)
+		if err == nil && data.Keyword >= 0 && data.Keyword < len(c.keywordLanguages) {
+			language, ok = c.keywordLanguages[data.Keyword], true
+		}
+	}
+	if !ok || language == Language(c.ctx) {
+		return
+	}
+
+	log.Infof("Conversation language: '%s'", language)
+	if language == "" {
+		delete(c.ctx, CtxLanguage)
+	} else {
+		c.ctx[CtxLanguage] = language
+	}
+}
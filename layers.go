@@ -0,0 +1,238 @@
+package hasp
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/rmcsoft/chanim"
+)
+
+// offsetPaintEngine draws at the offset
+type offsetPaintEngine struct {
+	chanim.PaintEngine
+	offset image.Point
+}
+
+func (p offsetPaintEngine) Clear(rect image.Rectangle) error {
+	return p.PaintEngine.Clear(rect.Add(p.offset))
+}
+
+func (p offsetPaintEngine) DrawPixmap(top image.Point, pixmap *chanim.Pixmap) error {
+	return p.PaintEngine.DrawPixmap(top.Add(p.offset), pixmap)
+}
+
+func (p offsetPaintEngine) DrawPackedPixmap(top image.Point, pixmap *chanim.PackedPixmap) error {
+	return p.PaintEngine.DrawPackedPixmap(top.Add(p.offset), pixmap)
+}
+
+// drawAt draws the frame of a layer at the offset
+func drawAt(paintEngine chanim.PaintEngine, frame *chanim.Frame, offset image.Point) error {
+	return frame.Draw(offsetPaintEngine{paintEngine, offset})
+}
+
+// layerOperation draws a frame of the layer over the frame
+type layerOperation struct {
+	frame  chanim.Frame
+	offset image.Point
+}
+
+func (o *layerOperation) Draw(paintEngine chanim.PaintEngine) error {
+	return drawAt(paintEngine, &o.frame, o.offset)
+}
+
+// appendDrawOperation appends the operation to a copy of the operations,
+// the frames repeated by the manifest share them
+func appendDrawOperation(operations []chanim.DrawOperation, operation chanim.DrawOperation) []chanim.DrawOperation {
+	result := make([]chanim.DrawOperation, len(operations), len(operations)+1)
+	copy(result, operations)
+	return append(result, operation)
+}
+
+func findFrameSeries(name string, allFrameSeries []chanim.FrameSeries) *chanim.FrameSeries {
+	for i := range allFrameSeries {
+		if allFrameSeries[i].Name == name {
+			return &allFrameSeries[i]
+		}
+	}
+	return nil
+}
+
+// applyLayers draws the layers over the frames of the series.
+// The frame i of the series gets the frame i of the layer in a loop.
+func applyLayers(allFrameSeries []chanim.FrameSeries, manifest AnimationManifest) {
+	for i := range allFrameSeries {
+		frameSeries := &allFrameSeries[i]
+		for _, layer := range manifest.Animations[frameSeries.Name].Layers {
+			layerFrames := findFrameSeries(layer.Series, allFrameSeries).Frames
+			for j := range frameSeries.Frames {
+				frame := &frameSeries.Frames[j]
+				frame.DrawOperations = appendDrawOperation(frame.DrawOperations, &layerOperation{
+					frame:  chanim.Frame{DrawOperations: layerFrames[j%len(layerFrames)].DrawOperations},
+					offset: image.Pt(layer.X, layer.Y),
+				})
+			}
+		}
+	}
+}
+
+type overlay struct {
+	frames []chanim.Frame
+	offset image.Point
+	next   int
+}
+
+// Overlays are the layers shown over any animation at runtime, e.g. the
+// "listening" icon or the "network down" badge. The overlays are defined by
+// the manifest and drawn over every frame by CreateAnimatorWithManifest.
+// The animated overlays are played in a loop.
+//
+// The overlays are not cleared when they are hidden, so the frames under
+// them are expected to cover the display.
+type Overlays struct {
+	mutex   sync.Mutex
+	defined map[string]*overlay
+	// Shown by the current state, see OverlayState
+	stateShown []string
+	// Shown until hidden
+	shown []string
+	// Drawn over the overlays, see Attach
+	attached []chanim.DrawOperation
+}
+
+// NewOverlays creates new Overlays
+func NewOverlays() *Overlays {
+	return &Overlays{
+		defined: make(map[string]*overlay),
+	}
+}
+
+func (o *Overlays) define(name string, frames []chanim.Frame, offset image.Point) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.defined[name] = &overlay{frames: frames, offset: offset}
+}
+
+// Show shows the overlay until it is hidden
+func (o *Overlays) Show(name string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if _, ok := o.defined[name]; !ok {
+		return fmt.Errorf("Unknown overlay '%s'", name)
+	}
+	for _, shown := range o.shown {
+		if shown == name {
+			return nil
+		}
+	}
+	o.shown = append(o.shown, name)
+	return nil
+}
+
+// Hide hides the overlay shown by Show
+func (o *Overlays) Hide(name string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for i, shown := range o.shown {
+		if shown == name {
+			o.shown = append(o.shown[:i:i], o.shown[i+1:]...)
+			return
+		}
+	}
+}
+
+// Shown returns the overlays shown by the state and by Show
+func (o *Overlays) Shown() []string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	shown := make([]string, 0, len(o.stateShown)+len(o.shown))
+	shown = append(shown, o.stateShown...)
+	return append(shown, o.shown...)
+}
+
+// Attach draws the operation over the overlays on every frame, e.g. Captions
+func (o *Overlays) Attach(operation chanim.DrawOperation) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.attached = append(o.attached, operation)
+}
+
+// setStateOverlays replaces the overlays shown by the state.
+// The overlays not defined by the manifest are ignored.
+func (o *Overlays) setStateOverlays(names []string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.stateShown = names
+}
+
+// Draw implements chanim.DrawOperation, it draws the next frame of the
+// shown overlays and the attached operations
+func (o *Overlays) Draw(paintEngine chanim.PaintEngine) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for _, names := range [][]string{o.stateShown, o.shown} {
+		for _, name := range names {
+			ov, ok := o.defined[name]
+			if !ok {
+				continue
+			}
+			frame := &ov.frames[ov.next]
+			ov.next = (ov.next + 1) % len(ov.frames)
+			if err := drawAt(paintEngine, frame, ov.offset); err != nil {
+				return err
+			}
+		}
+	}
+	for _, operation := range o.attached {
+		if err := operation.Draw(paintEngine); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOverlays defines the overlays of the manifest and draws them over
+// every frame
+func applyOverlays(allFrameSeries []chanim.FrameSeries, manifest AnimationManifest, overlays *Overlays) {
+	for name, spec := range manifest.Overlays {
+		frames := findFrameSeries(spec.Series, allFrameSeries).Frames
+		overlays.define(name, frames, image.Pt(spec.X, spec.Y))
+	}
+
+	for i := range allFrameSeries {
+		if manifest.isLayer(allFrameSeries[i].Name) {
+			continue
+		}
+		frames := allFrameSeries[i].Frames
+		for j := range frames {
+			frames[j].DrawOperations = appendDrawOperation(frames[j].DrawOperations, overlays)
+		}
+	}
+}
+
+// OverlayState is implemented by the states showing overlays while they
+// are active
+type OverlayState interface {
+	GetOverlays() []string
+}
+
+type overlaidState struct {
+	State
+	overlays []string
+}
+
+// WithOverlays makes the state show the overlays while it is active
+func WithOverlays(state State, overlays ...string) State {
+	return &overlaidState{
+		State:    state,
+		overlays: overlays,
+	}
+}
+
+func (s *overlaidState) GetOverlays() []string {
+	return s.overlays
+}
@@ -0,0 +1,229 @@
+package hasp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rmcsoft/chanim"
+)
+
+// LintSeverity is the severity of LintIssue
+type LintSeverity int
+
+const (
+	// LintWarning is a problem that does not break the character
+	LintWarning LintSeverity = iota
+	// LintError is a problem that breaks the character at runtime
+	LintError
+)
+
+func (s LintSeverity) String() string {
+	if s == LintError {
+		return "error"
+	}
+	return "warning"
+}
+
+// LintIssue is a problem found in the packed image directory
+type LintIssue struct {
+	Severity LintSeverity
+	// Series is the frame series, empty for the image directory issues
+	Series string
+	// File is the frame file, empty for the series issues
+	File    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	switch {
+	case i.File != "":
+		return fmt.Sprintf("%v: %s/%s: %s", i.Severity, i.Series, i.File, i.Message)
+	case i.Series != "":
+		return fmt.Sprintf("%v: %s: %s", i.Severity, i.Series, i.Message)
+	}
+	return fmt.Sprintf("%v: %s", i.Severity, i.Message)
+}
+
+// LintParams LintImageDir params
+type LintParams struct {
+	// Animations are the animations used by the character states
+	Animations []string
+	// DisplayWidth and DisplayHeight are the display size, not checked if 0
+	DisplayWidth  int
+	DisplayHeight int
+	// PixFormat is the pixel format of the paint engine
+	PixFormat chanim.PixelFormat
+}
+
+// LintReport is the result of LintImageDir
+type LintReport struct {
+	Issues      []LintIssue
+	SeriesCount int
+	FrameCount  int
+	// MemoryFootprint is the size of the frames mapped to memory in bytes
+	MemoryFootprint int64
+}
+
+// HasErrors reports whether there are LintError issues
+func (r *LintReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *LintReport) add(severity LintSeverity, series string, file string, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, LintIssue{
+		Severity: severity,
+		Series:   series,
+		File:     file,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+type frameSize struct {
+	width  int
+	height int
+}
+
+func (s frameSize) String() string {
+	return fmt.Sprintf("%dx%d", s.width, s.height)
+}
+
+// LintImageDir checks the packed image directory the way CreateAnimator loads
+// it, and the animations used by the character against it.
+// It returns an error only if the directory can't be read.
+func LintImageDir(path string, params LintParams) (*LintReport, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LintReport{}
+	var orderedNames []string
+	seriesNames := make(map[string]bool)
+	seriesSizes := make(map[string]frameSize)
+	sizeCounts := make(map[frameSize]int)
+	for _, fileInfo := range files {
+		if !fileInfo.IsDir() {
+			continue
+		}
+		name := fileInfo.Name()
+		orderedNames = append(orderedNames, name)
+		seriesNames[name] = true
+		report.SeriesCount++
+
+		size, ok := lintFrameSeries(report, filepath.Join(path, name), name, params)
+		if ok {
+			seriesSizes[name] = size
+			sizeCounts[size]++
+		}
+	}
+
+	// The series of other size than most of them are likely exported wrong
+	var commonSize frameSize
+	for size, count := range sizeCounts {
+		if count > sizeCounts[commonSize] {
+			commonSize = size
+		}
+	}
+	for _, name := range orderedNames {
+		if size, ok := seriesSizes[name]; ok && size != commonSize {
+			report.add(LintWarning, name, "", "frame size %v differs from %v of most series", size, commonSize)
+		}
+	}
+
+	for _, name := range orderedNames {
+		if !isTransitName(name) {
+			continue
+		}
+		animation := strings.TrimSuffix(strings.TrimSuffix(name, "_entry"), "_exit")
+		if !seriesNames[animation] || isTransitName(animation) {
+			report.add(LintWarning, name, "", "orphan transition series, there is no animation '%s'", animation)
+		}
+	}
+
+	manifest := lintAnimations(report, path)
+	for _, animation := range params.Animations {
+		if !seriesNames[animation] || isTransitName(animation) || manifest.isAuxiliary(animation) {
+			report.add(LintError, "", "", "unknown animation '%s' used by the character", animation)
+		}
+	}
+
+	return report, nil
+}
+
+// lintFrameSeries checks the frames of the series.
+// It returns the size of the first frame.
+func lintFrameSeries(report *LintReport, path string, name string, params LintParams) (frameSize, bool) {
+	ppixmapFiles, err := frameFiles(path)
+	if err != nil {
+		report.add(LintError, name, "", "%v", err)
+		return frameSize{}, false
+	}
+	if len(ppixmapFiles) == 0 {
+		report.add(LintError, name, "", "series has no frame")
+		return frameSize{}, false
+	}
+
+	var first frameSize
+	loaded := false
+	for _, ppixmapFile := range ppixmapFiles {
+		fileName := filepath.Base(ppixmapFile)
+		if fileInfo, err := os.Stat(ppixmapFile); err == nil {
+			report.MemoryFootprint += fileInfo.Size()
+		}
+
+		ppixmap, err := chanim.LoadPackedPixmap(ppixmapFile)
+		if err != nil {
+			report.add(LintError, name, fileName, "invalid frame: %v", err)
+			continue
+		}
+		report.FrameCount++
+
+		size := frameSize{ppixmap.Width, ppixmap.Height}
+		if !loaded {
+			first = size
+			loaded = true
+		} else if size != first {
+			report.add(LintWarning, name, fileName, "frame size %v differs from %v of the first frame", size, first)
+		}
+
+		if ppixmap.PixFormat != params.PixFormat {
+			report.add(LintError, name, fileName, "pixel format is %d bits, the display is %d bits",
+				chanim.GetPixelDepth(ppixmap.PixFormat), chanim.GetPixelDepth(params.PixFormat))
+		}
+		if params.DisplayWidth > 0 && params.DisplayHeight > 0 &&
+			(size.width > params.DisplayWidth || size.height > params.DisplayHeight) {
+			report.add(LintError, name, fileName, "frame %v is bigger than the display %dx%d",
+				size, params.DisplayWidth, params.DisplayHeight)
+		}
+	}
+	return first, loaded
+}
+
+// lintAnimations loads the frame series with the manifest as CreateAnimator
+// does. It returns the manifest.
+func lintAnimations(report *LintReport, path string) AnimationManifest {
+	manifest, err := LoadAnimationManifest(path)
+	if err != nil {
+		report.add(LintError, "", "", "%v", err)
+		return manifest
+	}
+
+	allFrameSeries, err := LoadFrameSeries(path)
+	if err != nil {
+		report.add(LintError, "", "", "failed to load frame series: %v", err)
+		return manifest
+	}
+
+	if _, _, err := prepareAnimations(allFrameSeries, manifest); err != nil {
+		report.add(LintError, "", "", "failed to make animations: %v", err)
+	}
+	return manifest
+}
@@ -0,0 +1,264 @@
+package hasp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmcsoft/chanim"
+)
+
+// testImageDir is the packed image directory removed by the test
+type testImageDir struct {
+	t    *testing.T
+	path string
+}
+
+func newTestImageDir(t *testing.T) *testImageDir {
+	path, err := ioutil.TempDir("", "hasp-lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testImageDir{t, path}
+}
+
+func (d *testImageDir) Remove() {
+	os.RemoveAll(d.path)
+}
+
+// AddSeries adds the series of the frames of the size
+func (d *testImageDir) AddSeries(name string, frameCount int, width int, height int, pixFormat chanim.PixelFormat) {
+	d.t.Helper()
+	for i := 0; i < frameCount; i++ {
+		d.AddFrame(name, fmt.Sprintf("%03d.ppixmap", i), width, height, pixFormat)
+	}
+	if frameCount == 0 {
+		d.AddFile(name, "", nil)
+	}
+}
+
+// AddFrame adds the frame to the series
+func (d *testImageDir) AddFrame(series string, file string, width int, height int, pixFormat chanim.PixelFormat) {
+	d.t.Helper()
+	pixSize := chanim.GetPixelSize(pixFormat)
+	ppixmap, err := chanim.PackPixmap(&chanim.Pixmap{
+		Data:        make([]byte, width*height*pixSize),
+		Width:       width,
+		Height:      height,
+		BytePerLine: width * pixSize,
+		PixFormat:   pixFormat,
+	})
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	d.AddFile(series, "", nil)
+	if err := ppixmap.Save(filepath.Join(d.path, series, file)); err != nil {
+		d.t.Fatal(err)
+	}
+}
+
+// AddFile adds the file to the series, only the series directory if the file
+// is empty
+func (d *testImageDir) AddFile(series string, file string, data []byte) {
+	d.t.Helper()
+	if err := os.MkdirAll(filepath.Join(d.path, series), 0755); err != nil {
+		d.t.Fatal(err)
+	}
+	if file == "" {
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(d.path, series, file), data, 0644); err != nil {
+		d.t.Fatal(err)
+	}
+}
+
+// findIssue reports whether there is the issue of the severity and the series
+// whose file and message match
+func findIssue(report *LintReport, severity LintSeverity, series string, file string, message string) bool {
+	for _, issue := range report.Issues {
+		if issue.Severity == severity && issue.Series == series && issue.File == file &&
+			strings.Contains(issue.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLintImageDir(t *testing.T) {
+	dir := newTestImageDir(t)
+	defer dir.Remove()
+	dir.AddSeries("lotus", 3, 4, 8, chanim.RGB16)
+	dir.AddSeries("lotus_exit", 1, 4, 8, chanim.RGB16)
+	dir.AddSeries("silent", 2, 4, 8, chanim.RGB16)
+
+	report, err := LintImageDir(dir.path, LintParams{
+		Animations:    []string{"lotus", "silent"},
+		DisplayWidth:  4,
+		DisplayHeight: 8,
+		PixFormat:     chanim.RGB16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 0 || report.HasErrors() {
+		t.Errorf("Issues %v", report.Issues)
+	}
+	if report.SeriesCount != 3 || report.FrameCount != 6 {
+		t.Errorf("%d series and %d frames, want 3 and 6", report.SeriesCount, report.FrameCount)
+	}
+	if report.MemoryFootprint == 0 {
+		t.Errorf("No memory footprint")
+	}
+
+	if _, err := LintImageDir(filepath.Join(dir.path, "nowhere"), LintParams{}); err == nil {
+		t.Errorf("No error for the missing directory")
+	}
+}
+
+func TestLintImageDirIssues(t *testing.T) {
+	type issue struct {
+		severity LintSeverity
+		series   string
+		file     string
+		message  string
+	}
+
+	tests := []struct {
+		name   string
+		add    func(dir *testImageDir)
+		issues []issue
+		errors bool
+	}{
+		{
+			name: "missing animation series",
+			add: func(dir *testImageDir) {
+				dir.AddSeries("lotus", 2, 4, 8, chanim.RGB16)
+			},
+			issues: []issue{{LintError, "", "", "unknown animation 'silent'"}},
+			errors: true,
+		},
+		{
+			name: "transition series used as animation",
+			add: func(dir *testImageDir) {
+				dir.AddSeries("lotus", 2, 4, 8, chanim.RGB16)
+				dir.AddSeries("silent_entry", 1, 4, 8, chanim.RGB16)
+			},
+			issues: []issue{
+				{LintWarning, "silent_entry", "", "orphan transition series"},
+				{LintError, "", "", "unknown animation 'silent'"},
+			},
+			errors: true,
+		},
+		{
+			name: "frame size mismatch",
+			add: func(dir *testImageDir) {
+				dir.AddSeries("lotus", 2, 4, 8, chanim.RGB16)
+				dir.AddFrame("lotus", "002.ppixmap", 4, 6, chanim.RGB16)
+				dir.AddSeries("silent", 2, 4, 8, chanim.RGB16)
+				dir.AddSeries("giggles", 2, 4, 8, chanim.RGB16)
+				dir.AddSeries("reading", 2, 2, 8, chanim.RGB16)
+			},
+			issues: []issue{
+				{LintWarning, "lotus", "002.ppixmap", "frame size 4x6 differs from 4x8 of the first frame"},
+				{LintWarning, "reading", "", "frame size 2x8 differs from 4x8 of most series"},
+			},
+		},
+		{
+			name: "frame bigger than display",
+			add: func(dir *testImageDir) {
+				dir.AddSeries("lotus", 1, 4, 8, chanim.RGB16)
+				dir.AddSeries("silent", 1, 4, 8, chanim.RGB16)
+				dir.AddFrame("silent", "001.ppixmap", 4, 10, chanim.RGB16)
+			},
+			issues: []issue{{LintError, "silent", "001.ppixmap", "frame 4x10 is bigger than the display 4x8"}},
+			errors: true,
+		},
+		{
+			name: "wrong pixel format",
+			add: func(dir *testImageDir) {
+				dir.AddSeries("lotus", 2, 4, 8, chanim.RGB16)
+				dir.AddSeries("silent", 1, 4, 8, chanim.RGB32)
+			},
+			issues: []issue{{LintError, "silent", "000.ppixmap", "pixel format is 32 bits, the display is 16 bits"}},
+			errors: true,
+		},
+		{
+			name: "corrupt frame",
+			add: func(dir *testImageDir) {
+				dir.AddSeries("lotus", 2, 4, 8, chanim.RGB16)
+				dir.AddSeries("silent", 1, 4, 8, chanim.RGB16)
+				dir.AddFile("silent", "001.ppixmap", []byte("not a pixmap"))
+			},
+			issues: []issue{
+				{LintError, "silent", "001.ppixmap", "invalid frame"},
+				{LintError, "", "", "failed to load frame series"},
+			},
+			errors: true,
+		},
+		{
+			name: "empty series",
+			add: func(dir *testImageDir) {
+				dir.AddSeries("lotus", 2, 4, 8, chanim.RGB16)
+				dir.AddSeries("silent", 0, 0, 0, chanim.RGB16)
+				dir.AddFile("silent", "readme.txt", []byte("no frames"))
+			},
+			issues: []issue{{LintError, "silent", "", "series has no frame"}},
+			errors: true,
+		},
+		{
+			name: "invalid manifest",
+			add: func(dir *testImageDir) {
+				dir.AddSeries("lotus", 2, 4, 8, chanim.RGB16)
+				dir.AddSeries("silent", 2, 4, 8, chanim.RGB16)
+				dir.AddFile("", AnimationManifestFileName, []byte(`{"animations": {"lotus": {"mode": "once", "next": "giggles"}}}`))
+			},
+			issues: []issue{{LintError, "", "", "failed to make animations"}},
+			errors: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := newTestImageDir(t)
+			defer dir.Remove()
+			test.add(dir)
+
+			report, err := LintImageDir(dir.path, LintParams{
+				Animations:    []string{"lotus", "silent"},
+				DisplayWidth:  4,
+				DisplayHeight: 8,
+				PixFormat:     chanim.RGB16,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, issue := range test.issues {
+				if !findIssue(report, issue.severity, issue.series, issue.file, issue.message) {
+					t.Errorf("No %v '%s' for %s/%s in %v", issue.severity, issue.message, issue.series, issue.file, report.Issues)
+				}
+			}
+			if report.HasErrors() != test.errors {
+				t.Errorf("HasErrors %v, want %v: %v", report.HasErrors(), test.errors, report.Issues)
+			}
+		})
+	}
+}
+
+func TestLintReportHasErrors(t *testing.T) {
+	tests := []struct {
+		issues []LintIssue
+		errors bool
+	}{
+		{nil, false},
+		{[]LintIssue{{Severity: LintWarning}}, false},
+		{[]LintIssue{{Severity: LintWarning}, {Severity: LintError}}, true},
+	}
+	for _, test := range tests {
+		report := &LintReport{Issues: test.issues}
+		if report.HasErrors() != test.errors {
+			t.Errorf("HasErrors of %v: %v, want %v", test.issues, report.HasErrors(), test.errors)
+		}
+	}
+}
@@ -10,13 +10,21 @@ type listensState struct {
 	currentAnimation    int
 	detector            *sound.HotWordDetector
 	soundPlayer         *sound.SoundPlayer
-	enterSoundData      *sound.AudioData
-	exitSoundData       *sound.AudioData
+	enterSoundData      Prompts
+	exitSoundData       Prompts
 }
 
 // NewListensState creates new ListensState
 func NewListensState(availableAnimations []string, detector *sound.HotWordDetector,
 	soundPlayer *sound.SoundPlayer, enterSoundData *sound.AudioData, exitSoundData *sound.AudioData) State {
+	return NewListensStateWithPrompts(availableAnimations, detector, soundPlayer,
+		Prompts{"": enterSoundData}, Prompts{"": exitSoundData})
+}
+
+// NewListensStateWithPrompts creates new ListensState playing the enter and
+// exit sounds of the conversation language
+func NewListensStateWithPrompts(availableAnimations []string, detector *sound.HotWordDetector,
+	soundPlayer *sound.SoundPlayer, enterSoundData Prompts, exitSoundData Prompts) State {
 	return &listensState{
 		availableAnimations: availableAnimations,
 		detector:            detector,
@@ -27,7 +35,7 @@ func NewListensState(availableAnimations []string, detector *sound.HotWordDetect
 }
 
 func (s *listensState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
-	s.soundPlayer.PlaySync(s.enterSoundData)
+	s.soundPlayer.PlaySync(s.enterSoundData.Get(Language(ctx)))
 
 	soundCapturerEventSource, err := s.detector.StartSoundCapture()
 	if err != nil {
@@ -40,7 +48,7 @@ func (s *listensState) Enter(ctx CharacterCtx, event events.Event) (events.Event
 }
 
 func (s *listensState) Leave(ctx CharacterCtx, event events.Event) bool {
-	s.soundPlayer.PlaySync(s.exitSoundData)
+	s.soundPlayer.PlaySync(s.exitSoundData.Get(Language(ctx)))
 	return true
 }
 
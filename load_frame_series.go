@@ -10,12 +10,21 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func loadFrames(path string) ([]chanim.Frame, error) {
+// frameFiles returns the sorted frame files of the series
+func frameFiles(path string) ([]string, error) {
 	ppixmapFiles, err := filepath.Glob(filepath.Join(path, "*.ppixmap"))
 	if err != nil {
 		return nil, err
 	}
 	sort.Strings(ppixmapFiles)
+	return ppixmapFiles, nil
+}
+
+func loadFrames(path string) ([]chanim.Frame, error) {
+	ppixmapFiles, err := frameFiles(path)
+	if err != nil {
+		return nil, err
+	}
 
 	frames := make([]chanim.Frame, 0, len(ppixmapFiles))
 	for _, ppixmapFile := range ppixmapFiles {
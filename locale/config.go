@@ -0,0 +1,98 @@
+package locale
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Language is the configuration of a conversation language
+type Language struct {
+	// Code is the language code kept for the conversation, e.g. "es"
+	Code string `json:"code"`
+	// Keyword is the Porcupine keyword file of the wake word selecting the
+	// language. The language is selected by touch only if it is not set.
+	Keyword string `json:"keyword,omitempty"`
+	// BotName and BotAlias are the bot of runtime.lex talking the language,
+	// the default ones are used if not set
+	BotName  string `json:"botName,omitempty"`
+	BotAlias string `json:"botAlias,omitempty"`
+	// Prompts are the WAV files of the prompts keyed by state,
+	// the prompts of the default language are used for the missing ones.
+	// "listens" and "listens-exit" are the sounds of entering and leaving
+	// the listens state.
+	Prompts map[string]string `json:"prompts,omitempty"`
+}
+
+// Config is the conversation languages. The first one is the default
+// language talked until another one is selected. For example:
+//
+//	{
+//	  "languages": [
+//	    {"code": "en", "keyword": "../keywords/hey_hasp.ppn"},
+//	    {"code": "es", "keyword": "../keywords/hola_hasp.ppn", "botAlias": "Spanish",
+//	     "prompts": {
+//	       "tells-help":     "../wavs/es/hello-help.wav",
+//	       "tells-fullhelp": "../wavs/es/fullhelp.wav",
+//	       "tells-there":    "../wavs/es/still-there.wav",
+//	       "tells-closed":   "../wavs/es/closed.wav",
+//	       "tell-msg-sent":  "../wavs/es/msg-sent.wav",
+//	       "listens":        "../wavs/es/listening.wav",
+//	       "listens-exit":   "../wavs/es/heard.wav",
+//	       "goodbye":        "../wavs/es/goodbye.wav"
+//	     }}
+//	  ]
+//	}
+type Config struct {
+	Languages []Language `json:"languages"`
+}
+
+// LoadConfig loads Config from the JSON file
+func LoadConfig(fileName string) (*Config, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse languages config '%s': %v", fileName, err)
+	}
+	return config, nil
+}
+
+// ParseConfig parses Config from JSON
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks there are languages and their codes are unique
+func (c *Config) Validate() error {
+	if len(c.Languages) == 0 {
+		return fmt.Errorf("No languages")
+	}
+
+	codes := make(map[string]bool)
+	for i, language := range c.Languages {
+		if language.Code == "" {
+			return fmt.Errorf("Language %d has no code", i)
+		}
+		if codes[language.Code] {
+			return fmt.Errorf("Language '%s' is duplicated", language.Code)
+		}
+		codes[language.Code] = true
+	}
+	return nil
+}
+
+// Default returns the default language
+func (c *Config) Default() Language {
+	return c.Languages[0]
+}
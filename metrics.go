@@ -0,0 +1,61 @@
+package hasp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rmcsoft/chanim"
+	"github.com/rmcsoft/hasp/metrics"
+)
+
+var (
+	currentStateGauge = metrics.NewGaugeVec("hasp_fsm_state",
+		"Current state of the character FSM (1 for the current state)", "state")
+	transitionCounter = metrics.NewCounterVec("hasp_fsm_transitions_total",
+		"Number of FSM transitions by event", "event")
+	drawnFrameCounter = metrics.NewCounter("hasp_animation_frames_total",
+		"Number of animation frames drawn")
+	frameRateGauge = metrics.NewGauge("hasp_animation_frame_rate",
+		"Animation frames drawn during the last second")
+)
+
+func setCurrentStateMetric(states States, current string) {
+	for stateName := range states {
+		if stateName == current {
+			currentStateGauge.With(stateName).Set(1)
+		} else {
+			currentStateGauge.With(stateName).Set(0)
+		}
+	}
+}
+
+type instrumentedPaintEngine struct {
+	chanim.PaintEngine
+
+	mutex       sync.Mutex
+	periodStart time.Time
+	periodCount int
+}
+
+// NewInstrumentedPaintEngine wraps the paint engine to collect the frame rate metrics
+func NewInstrumentedPaintEngine(paintEngine chanim.PaintEngine) chanim.PaintEngine {
+	return &instrumentedPaintEngine{
+		PaintEngine: paintEngine,
+		periodStart: time.Now(),
+	}
+}
+
+func (p *instrumentedPaintEngine) End() error {
+	err := p.PaintEngine.End()
+	drawnFrameCounter.Inc()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.periodCount++
+	if elapsed := time.Since(p.periodStart); elapsed >= time.Second {
+		frameRateGauge.Set(float64(p.periodCount) / elapsed.Seconds())
+		p.periodStart = time.Now()
+		p.periodCount = 0
+	}
+	return err
+}
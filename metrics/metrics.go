@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultDurationBuckets are histogram buckets (in seconds) suitable for
+// request latencies and sound durations
+var DefaultDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 7.5, 10, 15, 30}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	f *family
+	s *series
+}
+
+// Inc increments the counter by 1
+func (c Counter) Inc() {
+	c.f.add(c.s, 1)
+}
+
+// Add adds the given value to the counter. The value must be non-negative.
+func (c Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("Counter cannot decrease in value")
+	}
+	c.f.add(c.s, delta)
+}
+
+// Gauge is a value that can arbitrarily go up and down
+type Gauge struct {
+	f *family
+	s *series
+}
+
+// Set sets the gauge to the given value
+func (g Gauge) Set(value float64) {
+	g.f.set(g.s, value)
+}
+
+// Add adds the given value to the gauge
+func (g Gauge) Add(delta float64) {
+	g.f.add(g.s, delta)
+}
+
+// Inc increments the gauge by 1
+func (g Gauge) Inc() {
+	g.f.add(g.s, 1)
+}
+
+// Dec decrements the gauge by 1
+func (g Gauge) Dec() {
+	g.f.add(g.s, -1)
+}
+
+// Histogram samples observations and counts them in buckets
+type Histogram struct {
+	f *family
+	s *series
+}
+
+// Observe adds a single observation to the histogram
+func (h Histogram) Observe(value float64) {
+	h.f.observe(h.s, value)
+}
+
+// ObserveDuration adds the duration in seconds to the histogram
+func (h Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+// CounterVec is a set of counters partitioned by label values
+type CounterVec struct {
+	f *family
+}
+
+// With returns the counter for the given label values
+func (v CounterVec) With(labelValues ...string) Counter {
+	return Counter{v.f, v.f.get(labelValues)}
+}
+
+// GaugeVec is a set of gauges partitioned by label values
+type GaugeVec struct {
+	f *family
+}
+
+// With returns the gauge for the given label values
+func (v GaugeVec) With(labelValues ...string) Gauge {
+	return Gauge{v.f, v.f.get(labelValues)}
+}
+
+// HistogramVec is a set of histograms partitioned by label values
+type HistogramVec struct {
+	f *family
+}
+
+// With returns the histogram for the given label values
+func (v HistogramVec) With(labelValues ...string) Histogram {
+	return Histogram{v.f, v.f.get(labelValues)}
+}
+
+// NewCounter registers a new counter in the registry
+func (r *Registry) NewCounter(name, help string) Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// NewCounterVec registers a new counter set in the registry
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) CounterVec {
+	return CounterVec{r.register(newFamily(counterKind, name, help, labelNames))}
+}
+
+// NewGauge registers a new gauge in the registry
+func (r *Registry) NewGauge(name, help string) Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// NewGaugeVec registers a new gauge set in the registry
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) GaugeVec {
+	return GaugeVec{r.register(newFamily(gaugeKind, name, help, labelNames))}
+}
+
+// NewHistogram registers a new histogram in the registry
+func (r *Registry) NewHistogram(name, help string, buckets []float64) Histogram {
+	return r.NewHistogramVec(name, help, buckets).With()
+}
+
+// NewHistogramVec registers a new histogram set in the registry
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) HistogramVec {
+	f := newFamily(histogramKind, name, help, labelNames)
+	f.buckets = append([]float64(nil), buckets...)
+	sort.Float64s(f.buckets)
+	return HistogramVec{r.register(f)}
+}
+
+// NewCounter registers a new counter in DefaultRegistry
+func NewCounter(name, help string) Counter {
+	return DefaultRegistry.NewCounter(name, help)
+}
+
+// NewCounterVec registers a new counter set in DefaultRegistry
+func NewCounterVec(name, help string, labelNames ...string) CounterVec {
+	return DefaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+// NewGauge registers a new gauge in DefaultRegistry
+func NewGauge(name, help string) Gauge {
+	return DefaultRegistry.NewGauge(name, help)
+}
+
+// NewGaugeVec registers a new gauge set in DefaultRegistry
+func NewGaugeVec(name, help string, labelNames ...string) GaugeVec {
+	return DefaultRegistry.NewGaugeVec(name, help, labelNames...)
+}
+
+// NewHistogram registers a new histogram in DefaultRegistry
+func NewHistogram(name, help string, buckets []float64) Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets)
+}
+
+// NewHistogramVec registers a new histogram set in DefaultRegistry
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) HistogramVec {
+	return DefaultRegistry.NewHistogramVec(name, help, buckets, labelNames...)
+}
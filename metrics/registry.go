@@ -0,0 +1,264 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type metricKind int
+
+const (
+	counterKind metricKind = iota
+	gaugeKind
+	histogramKind
+)
+
+func (k metricKind) String() string {
+	switch k {
+	case counterKind:
+		return "counter"
+	case gaugeKind:
+		return "gauge"
+	case histogramKind:
+		return "histogram"
+	default:
+		panic("Invalid metricKind")
+	}
+}
+
+// Registry is a set of metrics exposed together
+type Registry struct {
+	mutex    sync.Mutex
+	families map[string]*family
+}
+
+// DefaultRegistry is the registry used by the package-level constructors
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates new Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+func (r *Registry) register(f *family) *family {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if registered, ok := r.families[f.name]; ok {
+		if registered.kind != f.kind {
+			panic(fmt.Sprintf("Metric '%s' is already registered as %v", f.name, registered.kind))
+		}
+		return registered
+	}
+	r.families[f.name] = f
+	return f
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]*family, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mutex.Unlock()
+
+	cw := &countingWriter{w: w}
+	for _, f := range families {
+		f.write(cw)
+		if cw.err != nil {
+			break
+		}
+	}
+	return cw.n, cw.err
+}
+
+// Handler returns the http.Handler serving the registry metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := r.WriteTo(w); err != nil {
+			log.Warnf("Failed to write the metrics: %v", err)
+		}
+	})
+}
+
+// Handler returns the http.Handler serving DefaultRegistry metrics
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// Histogram only
+	bucketCounts []uint64
+	count        uint64
+}
+
+type family struct {
+	mutex      sync.Mutex
+	name       string
+	help       string
+	kind       metricKind
+	labelNames []string
+	buckets    []float64
+	series     map[string]*series
+}
+
+func newFamily(kind metricKind, name, help string, labelNames []string) *family {
+	return &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		series:     make(map[string]*series),
+	}
+}
+
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("Metric '%s' expects %d label values, got %d",
+			f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{
+			labelValues: append([]string(nil), labelValues...),
+		}
+		if f.kind == histogramKind {
+			s.bucketCounts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) add(s *series, delta float64) {
+	f.mutex.Lock()
+	s.value += delta
+	f.mutex.Unlock()
+}
+
+func (f *family) set(s *series, value float64) {
+	f.mutex.Lock()
+	s.value = value
+	f.mutex.Unlock()
+}
+
+func (f *family) observe(s *series, value float64) {
+	f.mutex.Lock()
+	for i, upperBound := range f.buckets {
+		if value <= upperBound {
+			s.bucketCounts[i]++
+		}
+	}
+	s.count++
+	s.value += value
+	f.mutex.Unlock()
+}
+
+func (f *family) write(cw *countingWriter) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	cw.printf("# HELP %s %s\n", f.name, escapeHelp(f.help))
+	cw.printf("# TYPE %s %v\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != histogramKind {
+			cw.printf("%s%s %s\n", f.name, f.formatLabels(s.labelValues, "", 0), formatValue(s.value))
+			continue
+		}
+
+		for i, upperBound := range f.buckets {
+			cw.printf("%s_bucket%s %d\n", f.name,
+				f.formatLabels(s.labelValues, "le", upperBound), s.bucketCounts[i])
+		}
+		cw.printf("%s_bucket%s %d\n", f.name,
+			f.formatLabels(s.labelValues, "le", math.Inf(1)), s.count)
+		cw.printf("%s_sum%s %s\n", f.name, f.formatLabels(s.labelValues, "", 0), formatValue(s.value))
+		cw.printf("%s_count%s %d\n", f.name, f.formatLabels(s.labelValues, "", 0), s.count)
+	}
+}
+
+func (f *family) formatLabels(labelValues []string, extraName string, extraValue float64) string {
+	if len(labelValues) == 0 && extraName == "" {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labelValues)+1)
+	for i, labelValue := range labelValues {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", f.labelNames[i], escapeLabelValue(labelValue)))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, formatValue(extraValue)))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "Number of requests", "path", "code")
+	requests.With("/api/state", "200").Inc()
+	requests.With("/api/state", "200").Add(2)
+	requests.With("/api/event", "400").Inc()
+
+	temperature := r.NewGaugeVec("test_temperature", "Temperature\nof the \\board", "sensor")
+	temperature.With(`cpu "main"`).Set(42.5)
+	temperature.With("back\\slash\nnew line").Dec()
+
+	// The buckets are sorted
+	latency := r.NewHistogram("test_latency_seconds", "Latency", []float64{1, 0.5})
+	for _, v := range []float64{0.25, 0.75, 3} {
+		latency.Observe(v)
+	}
+
+	r.NewGauge("test_empty", "No series")
+
+	want := `# HELP test_empty No series
+# TYPE test_empty gauge
+test_empty 0
+# HELP test_latency_seconds Latency
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.5"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 4
+test_latency_seconds_count 3
+# HELP test_requests_total Number of requests
+# TYPE test_requests_total counter
+test_requests_total{path="/api/event",code="400"} 1
+test_requests_total{path="/api/state",code="200"} 3
+# HELP test_temperature Temperature\nof the \\board
+# TYPE test_temperature gauge
+test_temperature{sensor="back\\slash\nnew line"} -1
+test_temperature{sensor="cpu \"main\""} 42.5
+`
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Got:\n%s\nwant:\n%s", got, want)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("%d bytes are written, want %d", n, buf.Len())
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	durations := r.NewHistogramVec("test_duration_seconds", "Duration", []float64{1}, "state")
+	durations.With("listens").Observe(1)
+	durations.With("listens").Observe(1.5)
+
+	want := `# HELP test_duration_seconds Duration
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{state="listens",le="1"} 1
+test_duration_seconds_bucket{state="listens",le="+Inf"} 2
+test_duration_seconds_sum{state="listens"} 2.5
+test_duration_seconds_count{state="listens"} 2
+`
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Total").Inc()
+	// The same metric is returned by name
+	r.NewCounter("test_total", "Total").Inc()
+
+	var buf bytes.Buffer
+	r.WriteTo(&buf)
+	if want := "# HELP test_total Total\n# TYPE test_total counter\ntest_total 2\n"; buf.String() != want {
+		t.Errorf("Got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("No panic registering a counter as a gauge")
+		}
+	}()
+	r.NewGauge("test_total", "Total")
+}
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("Broken pipe")
+}
+
+func TestWriteToError(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_a_total", "A")
+	r.NewCounter("test_b_total", "B")
+
+	w := &failingWriter{}
+	if _, err := r.WriteTo(w); err == nil {
+		t.Errorf("No error")
+	}
+	if w.writes != 1 {
+		t.Errorf("%d writes after the error, want none", w.writes-1)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Total").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type %s", contentType)
+	}
+	if want := "# HELP test_total Total\n# TYPE test_total counter\ntest_total 1\n"; w.Body.String() != want {
+		t.Errorf("Got:\n%s\nwant:\n%s", w.Body.String(), want)
+	}
+}
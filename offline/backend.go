@@ -0,0 +1,170 @@
+// Package offline answers the visitors on the device: a local recognizer
+// transcribes the speech, the frequent questions are answered without a
+// round trip to the conversation backend and a grammar of keyword and regex
+// rules picks the canned reply when the backend is unreachable.
+package offline
+
+import (
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/haspaws"
+	"github.com/rmcsoft/hasp/sound"
+)
+
+// replyAudioRate is the sample rate of the reply audio files
+const replyAudioRate = 16000
+
+// Backend implements haspaws.OfflineBackend
+type Backend struct {
+	replier
+	grammar *Grammar
+}
+
+// NewBackend creates new Backend and loads the reply audio of the grammar.
+// The recognizer is needed for the speech, the text-to-speech is needed for
+// the replies without audio; both are optional.
+func NewBackend(grammar *Grammar, recognizer Recognizer, tts sound.TextToSpeech) (*Backend, error) {
+	r, err := newReplier(grammar.dir, grammar.replies(), recognizer, tts)
+	if err != nil {
+		return nil, err
+	}
+	return &Backend{replier: r, grammar: grammar}, nil
+}
+
+// Reply implements haspaws.OfflineBackend. The speech is transcribed if
+// there is no text.
+func (b *Backend) Reply(audioData *sound.AudioData, text string) *events.Event {
+	transcript := b.transcript(audioData, text)
+	reply, ok := b.match(transcript)
+	if !ok {
+		log.Infof("Offline: no reply to '%s'", transcript)
+		return sound.NewStopEvent(nil)
+	}
+	return b.replyEvent(reply, transcript)
+}
+
+// match returns the reply of the rule matching the transcript or the
+// fallback reply
+func (b *Backend) match(transcript string) (Reply, bool) {
+	if transcript != "" {
+		if rule, ok := b.grammar.Match(transcript); ok {
+			log.Infof("Offline: matched intent '%s'", rule.Intent)
+			return rule.Reply, true
+		}
+	}
+	if b.grammar.Fallback != nil {
+		return *b.grammar.Fallback, true
+	}
+	return Reply{}, false
+}
+
+// FAQBackend implements haspaws.FAQBackend
+type FAQBackend struct {
+	replier
+	faq *FAQ
+}
+
+// NewFAQBackend creates new FAQBackend and loads the reply audio of the FAQ.
+// Without the recognizer only the text utterances are answered.
+func NewFAQBackend(faq *FAQ, recognizer Recognizer, tts sound.TextToSpeech) (*FAQBackend, error) {
+	r, err := newReplier(faq.dir, faq.replies(), recognizer, tts)
+	if err != nil {
+		return nil, err
+	}
+	return &FAQBackend{replier: r, faq: faq}, nil
+}
+
+// Answer implements haspaws.FAQBackend. The speech is transcribed if there
+// is no text.
+func (b *FAQBackend) Answer(audioData *sound.AudioData, text string) (*events.Event, string) {
+	transcript := b.transcript(audioData, text)
+	if transcript == "" {
+		return nil, ""
+	}
+
+	entry, ok := b.faq.Match(transcript)
+	if !ok {
+		log.Debugf("FAQ: no answer to '%s'", transcript)
+		return nil, transcript
+	}
+	log.Infof("FAQ: matched intent '%s'", entry.Intent)
+	return b.replyEvent(entry.Reply, transcript), transcript
+}
+
+// replier transcribes the utterances and speaks the replies
+type replier struct {
+	recognizer Recognizer
+	tts        sound.TextToSpeech
+	audio      map[string]*sound.AudioData
+}
+
+func newReplier(dir string, replies []Reply, recognizer Recognizer, tts sound.TextToSpeech) (replier, error) {
+	r := replier{
+		recognizer: recognizer,
+		tts:        tts,
+		audio:      make(map[string]*sound.AudioData),
+	}
+
+	for _, reply := range replies {
+		if reply.Audio == "" {
+			continue
+		}
+		path := reply.Audio
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		audioData, err := sound.LoadMonoS16LEFromPCM(path, replyAudioRate)
+		if err != nil {
+			return replier{}, err
+		}
+		r.audio[reply.Audio] = audioData
+	}
+	return r, nil
+}
+
+// transcript returns the text or the transcript of the speech, it is
+// empty if there is no recognizer
+func (r *replier) transcript(audioData *sound.AudioData, text string) string {
+	if text != "" || audioData == nil || r.recognizer == nil {
+		return text
+	}
+	transcript, err := r.recognizer.Recognize(audioData)
+	if err != nil {
+		log.Errorf("Offline: %v", err)
+		return ""
+	}
+	log.Infof("Offline: recognized '%s'", transcript)
+	return transcript
+}
+
+// replyEvent returns the event of the reply as the ones of LexEventSource
+func (r *replier) replyEvent(reply Reply, transcript string) *events.Event {
+	speech := r.speech(reply)
+	if reply.End {
+		return sound.NewStopEventWithText(speech, transcript, reply.Text)
+	}
+	return haspaws.NewAwsRepliedEvent(haspaws.AwsRepliedEventData{
+		RepliedSpeech:   speech,
+		InputTranscript: transcript,
+		Message:         reply.Text,
+	})
+}
+
+// speech returns the audio of the reply or synthesizes its text
+func (r *replier) speech(reply Reply) *sound.AudioData {
+	if audioData, ok := r.audio[reply.Audio]; ok {
+		return audioData
+	}
+	if r.tts == nil || reply.Text == "" {
+		return nil
+	}
+	speech, err := r.tts.Synthesize(reply.Text)
+	if err != nil {
+		log.Errorf("Offline: failed to synthesize the reply: %v", err)
+		return nil
+	}
+	return speech
+}
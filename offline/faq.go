@@ -0,0 +1,123 @@
+package offline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// DefaultFAQThreshold is the default greatest distance of the utterance to
+// a phrase, relative to the length of the phrase
+const DefaultFAQThreshold = 0.25
+
+// FAQEntry maps the phrases of a question with a static answer to the reply
+type FAQEntry struct {
+	Intent  string   `json:"intent"`
+	Phrases []string `json:"phrases"`
+	Reply   Reply    `json:"reply"`
+
+	phrases []string
+}
+
+// FAQ is the frequently asked questions answered on the device. For example:
+//
+//	{
+//	  "threshold": 0.25,
+//	  "entries": [
+//	    {"intent": "WebsitePhoneNumber",
+//	     "phrases": ["what is your phone number", "what is your website"],
+//	     "reply": {"text": "Our number is on the board and our website is rmcsoft.com.", "audio": "phone.pcm"}},
+//	    {"intent": "WhatIsYourName", "phrases": ["what is your name", "who are you"],
+//	     "reply": {"text": "I am HASP, the receptionist."}}
+//	  ]
+//	}
+//
+// The utterance matches a phrase if the whole utterance is close to the
+// phrase: the Levenshtein distance divided by the length of the phrase is
+// not greater than the threshold. The closest phrase is used.
+type FAQ struct {
+	Entries   []FAQEntry `json:"entries"`
+	Threshold float64    `json:"threshold,omitempty"`
+
+	// dir is the directory of the FAQ file
+	dir string
+}
+
+// LoadFAQ loads FAQ from the JSON file
+func LoadFAQ(fileName string) (*FAQ, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	faq, err := ParseFAQ(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse FAQ '%s': %v", fileName, err)
+	}
+	faq.dir = filepath.Dir(fileName)
+	return faq, nil
+}
+
+// ParseFAQ parses FAQ from JSON and normalizes the phrases
+func ParseFAQ(data []byte) (*FAQ, error) {
+	faq := FAQ{Threshold: DefaultFAQThreshold}
+	if err := json.Unmarshal(data, &faq); err != nil {
+		return nil, err
+	}
+	if faq.Threshold < 0 || faq.Threshold >= 1 {
+		return nil, errors.New("Threshold must be in [0, 1)")
+	}
+
+	for i := range faq.Entries {
+		entry := &faq.Entries[i]
+		for _, phrase := range entry.Phrases {
+			if phrase = normalizeUtterance(phrase); phrase != "" {
+				entry.phrases = append(entry.phrases, phrase)
+			}
+		}
+		if len(entry.phrases) == 0 {
+			return nil, fmt.Errorf("Entry '%s' has no phrases", entry.Intent)
+		}
+	}
+	return &faq, nil
+}
+
+// Match returns the entry with the phrase closest to the utterance
+func (f *FAQ) Match(utterance string) (*FAQEntry, bool) {
+	utterance = normalizeUtterance(utterance)
+	if utterance == "" {
+		return nil, false
+	}
+
+	var best *FAQEntry
+	bestDistance := f.Threshold
+	for i := range f.Entries {
+		entry := &f.Entries[i]
+		for _, phrase := range entry.phrases {
+			if distance := phraseDistance(utterance, phrase); distance <= bestDistance {
+				best, bestDistance = entry, distance
+			}
+		}
+	}
+	return best, best != nil
+}
+
+// replies returns the replies of the entries
+func (f *FAQ) replies() []Reply {
+	replies := make([]Reply, 0, len(f.Entries))
+	for _, entry := range f.Entries {
+		replies = append(replies, entry.Reply)
+	}
+	return replies
+}
+
+// phraseDistance returns the distance of the utterance to the phrase
+// relative to the length of the phrase
+func phraseDistance(utterance string, phrase string) float64 {
+	return float64(fuzzy.LevenshteinDistance(utterance, phrase)) / float64(utf8.RuneCountInString(phrase))
+}
@@ -0,0 +1,85 @@
+package offline
+
+import "testing"
+
+func TestFAQMatch(t *testing.T) {
+	faq, err := ParseFAQ([]byte(`{
+		"entries": [
+			{"intent": "PhoneNumber", "phrases": ["what is your phone number", "how can I call you"], "reply": {"text": "555-0100"}},
+			{"intent": "Website", "phrases": ["what is your website"], "reply": {"text": "rmcsoft.com"}},
+			{"intent": "WhatIsYourName", "phrases": ["what is your name", "who are you"], "reply": {"text": "HASP"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		utterance string
+		intent    string
+	}{
+		{"What is your phone number?", "PhoneNumber"},
+		{"how can i call you", "PhoneNumber"},
+		// Close enough to the phrase, e.g. misrecognized
+		{"what's your phone number", "PhoneNumber"},
+		{"what is your web site", "Website"},
+		{"Who are you", "WhatIsYourName"},
+		// The closest phrase wins
+		{"what is your name", "WhatIsYourName"},
+		// The whole utterance must match, not a part of it
+		{"I want to meet Alice, what is your name", ""},
+		{"what is your phone number and when do you open", ""},
+		{"your name", ""},
+		{"what is your", ""},
+		{"", ""},
+		{"?!", ""},
+	}
+	for _, test := range tests {
+		entry, ok := faq.Match(test.utterance)
+		if test.intent == "" {
+			if ok {
+				t.Errorf("'%s' matches '%s'", test.utterance, entry.Intent)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("'%s' matches no entry, want '%s'", test.utterance, test.intent)
+		} else if entry.Intent != test.intent {
+			t.Errorf("'%s' matches '%s', want '%s'", test.utterance, entry.Intent, test.intent)
+		}
+	}
+}
+
+func TestFAQThreshold(t *testing.T) {
+	tests := []struct {
+		threshold string
+		matches   bool
+	}{
+		{"0", false},
+		{"0.1", true},
+	}
+	for _, test := range tests {
+		faq, err := ParseFAQ([]byte(`{"threshold": ` + test.threshold + `,
+			"entries": [{"intent": "Hours", "phrases": ["when do you open"], "reply": {"text": "At nine."}}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := faq.Match("when do you opan"); ok != test.matches {
+			t.Errorf("Threshold %s: matches %v, want %v", test.threshold, ok, test.matches)
+		}
+	}
+}
+
+func TestParseFAQErrors(t *testing.T) {
+	tests := []string{
+		`{"threshold": 1, "entries": []}`,
+		`{"threshold": -0.1, "entries": []}`,
+		`{"entries": [{"intent": "Empty", "phrases": ["?!"], "reply": {"text": "?"}}]}`,
+		`{"entries": `,
+	}
+	for _, data := range tests {
+		if _, err := ParseFAQ([]byte(data)); err == nil {
+			t.Errorf("No error parsing %s", data)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package offline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Reply is the canned reply of a rule
+type Reply struct {
+	// Text is the reply message, it is spoken by the text-to-speech if
+	// there is no audio
+	Text string `json:"text"`
+	// Audio is the PCM file of the reply (16 kHz mono S16LE), relative to
+	// the grammar file
+	Audio string `json:"audio,omitempty"`
+	// End ends the conversation after the reply
+	End bool `json:"end,omitempty"`
+}
+
+// Rule maps the utterances to the reply. The rule matches if the utterance
+// contains one of the keywords as whole words or matches one of the
+// patterns; the case is ignored.
+type Rule struct {
+	Intent   string   `json:"intent"`
+	Keywords []string `json:"keywords,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	Reply    Reply    `json:"reply"`
+
+	keywords []string
+	patterns []*regexp.Regexp
+}
+
+// Grammar is the rules of the on-device intent matcher. For example:
+//
+//	{
+//	  "rules": [
+//	    {"intent": "Restroom", "keywords": ["restroom", "toilet", "bathroom"],
+//	     "reply": {"text": "The restroom is down the hall on the left.", "audio": "restroom.pcm"}},
+//	    {"intent": "Delivery", "patterns": ["deliver(y|ing)?|package|parcel"],
+//	     "reply": {"text": "Please leave the package at the front desk."}},
+//	    {"intent": "Goodbye", "keywords": ["bye", "thank you"],
+//	     "reply": {"text": "Goodbye!", "end": true}}
+//	  ],
+//	  "fallback": {"text": "Sorry, I can only help with simple questions right now."}
+//	}
+//
+// The first matching rule is used.
+type Grammar struct {
+	Rules []Rule `json:"rules"`
+	// Fallback is the reply to the utterances matching no rule
+	Fallback *Reply `json:"fallback,omitempty"`
+
+	// dir is the directory of the grammar file
+	dir string
+}
+
+// LoadGrammar loads Grammar from the JSON file
+func LoadGrammar(fileName string) (*Grammar, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	grammar, err := ParseGrammar(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse grammar '%s': %v", fileName, err)
+	}
+	grammar.dir = filepath.Dir(fileName)
+	return grammar, nil
+}
+
+// ParseGrammar parses Grammar from JSON and compiles the rules
+func ParseGrammar(data []byte) (*Grammar, error) {
+	var grammar Grammar
+	if err := json.Unmarshal(data, &grammar); err != nil {
+		return nil, err
+	}
+
+	for i := range grammar.Rules {
+		rule := &grammar.Rules[i]
+		if len(rule.Keywords) == 0 && len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("Rule '%s' has neither keywords nor patterns", rule.Intent)
+		}
+		for _, keyword := range rule.Keywords {
+			rule.keywords = append(rule.keywords, normalizeUtterance(keyword))
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Rule '%s': %v", rule.Intent, err)
+			}
+			rule.patterns = append(rule.patterns, re)
+		}
+	}
+	return &grammar, nil
+}
+
+// Match returns the first rule matching the utterance
+func (g *Grammar) Match(utterance string) (*Rule, bool) {
+	words := " " + normalizeUtterance(utterance) + " "
+	for i := range g.Rules {
+		rule := &g.Rules[i]
+		for _, keyword := range rule.keywords {
+			if strings.Contains(words, " "+keyword+" ") {
+				return rule, true
+			}
+		}
+		for _, re := range rule.patterns {
+			if re.MatchString(utterance) {
+				return rule, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// replies returns the replies of the rules and the fallback reply
+func (g *Grammar) replies() []Reply {
+	replies := make([]Reply, 0, len(g.Rules)+1)
+	for _, rule := range g.Rules {
+		replies = append(replies, rule.Reply)
+	}
+	if g.Fallback != nil {
+		replies = append(replies, *g.Fallback)
+	}
+	return replies
+}
+
+// normalizeUtterance lowercases the words and separates them with single
+// spaces, the punctuation is dropped
+func normalizeUtterance(utterance string) string {
+	words := strings.FieldsFunc(strings.ToLower(utterance), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,68 @@
+package offline
+
+import "testing"
+
+func TestGrammarMatch(t *testing.T) {
+	grammar, err := ParseGrammar([]byte(`{
+		"rules": [
+			{"intent": "Restroom", "keywords": ["restroom", "toilet"], "reply": {"text": "Down the hall."}},
+			{"intent": "Delivery", "patterns": ["deliver(y|ing)?|parcel"], "reply": {"text": "Front desk."}},
+			{"intent": "Goodbye", "keywords": ["bye", "Thank you!"], "reply": {"text": "Goodbye!", "end": true}},
+			{"intent": "Parking", "keywords": ["parking", "car park"], "patterns": ["where .* park"], "reply": {"text": "Level 2."}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		utterance string
+		intent    string
+	}{
+		{"Where is the restroom?", "Restroom"},
+		{"RESTROOM", "Restroom"},
+		// The keywords match whole words only
+		{"restrooms", ""},
+		{"I'm delivering a parcel", "Delivery"},
+		{"DELIVERY for Alice", "Delivery"},
+		// The multi-word keywords are normalized as the utterance
+		{"thank   you, bye", "Goodbye"},
+		{"Thank you.", "Goodbye"},
+		{"thank-you", "Goodbye"},
+		{"byebye", ""},
+		{"is there a car park", "Parking"},
+		{"Where can I park?", "Parking"},
+		// The first matching rule wins
+		{"toilet and a parcel", "Restroom"},
+		{"a parcel, bye", "Delivery"},
+		{"", ""},
+		{"hello", ""},
+	}
+	for _, test := range tests {
+		rule, ok := grammar.Match(test.utterance)
+		if test.intent == "" {
+			if ok {
+				t.Errorf("'%s' matches '%s'", test.utterance, rule.Intent)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("'%s' matches no rule, want '%s'", test.utterance, test.intent)
+		} else if rule.Intent != test.intent {
+			t.Errorf("'%s' matches '%s', want '%s'", test.utterance, rule.Intent, test.intent)
+		}
+	}
+}
+
+func TestParseGrammarErrors(t *testing.T) {
+	tests := []string{
+		`{"rules": [{"intent": "Empty", "reply": {"text": "?"}}]}`,
+		`{"rules": [{"intent": "Bad", "patterns": ["(unclosed"], "reply": {"text": "?"}}]}`,
+		`{"rules": `,
+	}
+	for _, data := range tests {
+		if _, err := ParseGrammar([]byte(data)); err == nil {
+			t.Errorf("No error parsing %s", data)
+		}
+	}
+}
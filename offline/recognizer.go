@@ -0,0 +1,166 @@
+package offline
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rmcsoft/hasp/sound"
+)
+
+// DefaultRecognizerTimeout is the time limit of the local recognizer
+const DefaultRecognizerTimeout = 15 * time.Second
+
+// Recognizer transcribes the speech
+type Recognizer interface {
+	Recognize(audioData *sound.AudioData) (string, error)
+}
+
+// WAVFileArg is the argument of CommandRecognizer replaced with the path of
+// a WAV file with the speech
+const WAVFileArg = "{wav}"
+
+// CommandRecognizer runs a local recognizer printing the transcript to
+// stdout, e.g. whisper.cpp or a Vosk script. The speech is passed in a WAV
+// file if an argument is WAVFileArg and as raw S16LE samples to stdin
+// otherwise, e.g.
+//
+//	whisper-cli -m ggml-base.en.bin -nt -np -f {wav}
+//	vosk-transcribe --rate 16000
+type CommandRecognizer struct {
+	command string
+	args    []string
+
+	// Timeout is the time limit of the recognizer, the recognizer is killed
+	// when it is exceeded
+	Timeout time.Duration
+}
+
+// NewCommandRecognizer creates new CommandRecognizer
+func NewCommandRecognizer(command string, args ...string) *CommandRecognizer {
+	return &CommandRecognizer{
+		command: command,
+		args:    args,
+		Timeout: DefaultRecognizerTimeout,
+	}
+}
+
+// Recognize implements Recognizer
+func (r *CommandRecognizer) Recognize(audioData *sound.AudioData) (string, error) {
+	args := make([]string, len(r.args))
+	copy(args, r.args)
+
+	var stdin io.Reader = bytes.NewReader(audioData.Samples())
+	for i, arg := range args {
+		if arg != WAVFileArg {
+			continue
+		}
+		wavFile, err := writeWAVFile(audioData)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(wavFile)
+		args[i] = wavFile
+		stdin = nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, r.command, args...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("Recognizer command '%s' timed out after %v", r.command, r.Timeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Recognizer command '%s' failed: %v: %s",
+			r.command, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// SocketRecognizer sends the speech to a local recognizer server listening
+// on the Unix socket. The raw S16LE samples are written to the connection,
+// the writing side is closed and the server replies with the transcript.
+type SocketRecognizer struct {
+	path string
+
+	// Timeout is the time limit of the exchange with the server
+	Timeout time.Duration
+}
+
+// NewSocketRecognizer creates new SocketRecognizer
+func NewSocketRecognizer(path string) *SocketRecognizer {
+	return &SocketRecognizer{
+		path:    path,
+		Timeout: DefaultRecognizerTimeout,
+	}
+}
+
+// Recognize implements Recognizer
+func (r *SocketRecognizer) Recognize(audioData *sound.AudioData) (string, error) {
+	conn, err := net.DialTimeout("unix", r.path, r.Timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(r.Timeout)); err != nil {
+		return "", err
+	}
+
+	if _, err := conn.Write(audioData.Samples()); err != nil {
+		return "", fmt.Errorf("Failed to send speech to recognizer '%s': %v", r.path, err)
+	}
+	if err := conn.(*net.UnixConn).CloseWrite(); err != nil {
+		return "", err
+	}
+
+	output, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read transcript from recognizer '%s': %v", r.path, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// writeWAVFile writes the speech to a temporary WAV file
+func writeWAVFile(audioData *sound.AudioData) (string, error) {
+	f, err := ioutil.TempFile("", "hasp-speech-*.wav")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	samples := audioData.Samples()
+	channels := audioData.ChannelCount()
+	rate := audioData.SampleRate()
+	sampleSize := audioData.SampleSize()
+	header := []interface{}{
+		[]byte("RIFF"), uint32(36 + len(samples)), []byte("WAVE"),
+		[]byte("fmt "), uint32(16), uint16(1), uint16(channels), uint32(rate),
+		uint32(rate * channels * sampleSize), uint16(channels * sampleSize), uint16(8 * sampleSize),
+		[]byte("data"), uint32(len(samples)),
+	}
+	for _, v := range header {
+		if err := binary.Write(f, binary.LittleEndian, v); err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+	}
+	if _, err := f.Write(samples); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,108 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// AllOutputs is the output name of an action applied to every output
+const AllOutputs = "*"
+
+// OutputConfig describes an output pin
+type OutputConfig struct {
+	// Pin is the name of the pin, e.g. "pioA10"
+	Pin string `json:"pin"`
+	// Number is the pin number used to export the pin
+	Number int `json:"number"`
+	// ActiveLow is set if the output is on when the pin is LOW
+	ActiveLow bool `json:"activeLow,omitempty"`
+}
+
+// Action drives the output with the pattern
+type Action struct {
+	Output string `json:"output"`
+	Pattern
+}
+
+// Config is the output actions keyed by state and event.
+// The state actions are applied when the state is entered, the event actions
+// are applied when the event causes a transition. For example:
+//
+//	{
+//	  "outputs": {
+//	    "ring":  {"pin": "pioA10", "number": 10},
+//	    "chime": {"pin": "pioA11", "number": 11, "activeLow": true}
+//	  },
+//	  "states": {
+//	    "idle":       [{"output": "*", "pattern": "off"}],
+//	    "listens":    [{"output": "ring", "pattern": "on"}],
+//	    "processing": [{"output": "ring", "pattern": "blink", "on": "200ms", "off": "200ms"}]
+//	  },
+//	  "events": {
+//	    "AwsRepliedCall": [{"output": "chime", "pattern": "pulse", "on": "500ms"}]
+//	  }
+//	}
+type Config struct {
+	Outputs map[string]OutputConfig `json:"outputs"`
+	States  map[string][]Action     `json:"states,omitempty"`
+	Events  map[string][]Action     `json:"events,omitempty"`
+}
+
+// LoadConfig loads Config from the JSON file
+func LoadConfig(fileName string) (*Config, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse outputs config '%s': %v", fileName, err)
+	}
+	return config, nil
+}
+
+// ParseConfig parses Config from JSON
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks the actions refer to the known outputs
+func (c *Config) Validate() error {
+	for name, output := range c.Outputs {
+		if output.Pin == "" {
+			return fmt.Errorf("Output '%s' has no pin", name)
+		}
+	}
+
+	check := func(key string, actions []Action) error {
+		for _, action := range actions {
+			if _, ok := c.Outputs[action.Output]; !ok && action.Output != AllOutputs {
+				return fmt.Errorf("%s: unknown output '%s'", key, action.Output)
+			}
+			if err := action.Pattern.Validate(); err != nil {
+				return fmt.Errorf("%s: output '%s': %v", key, action.Output, err)
+			}
+		}
+		return nil
+	}
+	for state, actions := range c.States {
+		if err := check("State '"+state+"'", actions); err != nil {
+			return err
+		}
+	}
+	for event, actions := range c.Events {
+		if err := check("Event '"+event+"'", actions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
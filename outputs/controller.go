@@ -0,0 +1,88 @@
+package outputs
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+
+	"github.com/rmcsoft/hasp/events"
+)
+
+// Controller applies the output actions on the character transitions
+type Controller struct {
+	config  *Config
+	outputs map[string]*Output
+	names   []string
+}
+
+// NewController creates new Controller.
+// The output pins must be registered in gpioreg.
+func NewController(config *Config) (*Controller, error) {
+	c := &Controller{
+		config:  config,
+		outputs: make(map[string]*Output, len(config.Outputs)),
+	}
+	for name, outputConfig := range config.Outputs {
+		pin := gpioreg.ByName(outputConfig.Pin)
+		if pin == nil {
+			return nil, fmt.Errorf("Failed to open pin %s of output '%s'", outputConfig.Pin, name)
+		}
+		c.outputs[name] = NewOutput(name, pin, outputConfig.ActiveLow)
+		c.names = append(c.names, name)
+	}
+	sort.Strings(c.names)
+	return c, nil
+}
+
+// Run applies the actions of the transitions until the channel is closed
+func (c *Controller) Run(transitions <-chan events.Transition) {
+	for transition := range transitions {
+		c.HandleTransition(transition)
+	}
+}
+
+// HandleTransition applies the actions of the event.
+// The actions of the states are applied by the character on entering
+// the states, see StateActions.
+func (c *Controller) HandleTransition(transition events.Transition) {
+	c.apply(c.config.Events[transition.Event])
+}
+
+// StateActions returns the actions applied on entering the states
+// keyed by state, see hasp.DriveOutputs
+func (c *Controller) StateActions() map[string][]Action {
+	return c.config.States
+}
+
+// Close turns all the outputs off
+func (c *Controller) Close() {
+	for _, name := range c.names {
+		c.outputs[name].Close()
+	}
+}
+
+// Apply applies the actions out of the transitions,
+// e.g. on entering a state, see hasp.DriveOutputs
+func (c *Controller) Apply(actions ...Action) {
+	c.apply(actions)
+}
+
+func (c *Controller) apply(actions []Action) {
+	for _, action := range actions {
+		if action.Output == AllOutputs {
+			for _, name := range c.names {
+				c.outputs[name].Apply(action.Pattern)
+			}
+			continue
+		}
+
+		output, ok := c.outputs[action.Output]
+		if !ok {
+			continue
+		}
+		log.Debugf("Output '%s': %s", action.Output, action.Kind)
+		output.Apply(action.Pattern)
+	}
+}
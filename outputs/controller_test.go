@@ -0,0 +1,99 @@
+package outputs
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+
+	"github.com/rmcsoft/hasp/events"
+)
+
+func newTestController(t *testing.T, config string) (*Controller, *gpiotest.Pin) {
+	pin := &gpiotest.Pin{N: "OUT_RING"}
+	if err := gpioreg.Register(pin); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller, err := NewController(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return controller, pin
+}
+
+func level(pin *gpiotest.Pin) gpio.Level {
+	pin.Lock()
+	defer pin.Unlock()
+	return pin.L
+}
+
+func setLevel(pin *gpiotest.Pin, l gpio.Level) {
+	pin.Lock()
+	defer pin.Unlock()
+	pin.L = l
+}
+
+func TestHandleTransition(t *testing.T) {
+	controller, pin := newTestController(t, `{
+		"outputs": {"ring": {"pin": "OUT_RING", "number": 10}},
+		"states": {
+			"listens": [{"output": "ring", "pattern": "on"}]
+		},
+		"events": {
+			"AwsRepliedCall": [{"output": "*", "pattern": "on"}],
+			"Cancel": [{"output": "ring", "pattern": "off"}]
+		}
+	}`)
+	defer gpioreg.Unregister(pin.N)
+
+	tests := []struct {
+		transition events.Transition
+		initial    gpio.Level
+		level      gpio.Level
+	}{
+		{events.Transition{Event: "AwsRepliedCall", Src: "processing", Dst: "call"}, gpio.Low, gpio.High},
+		{events.Transition{Event: "Cancel", Src: "call", Dst: "idle"}, gpio.High, gpio.Low},
+		// The state actions are applied by the character, see StateActions
+		{events.Transition{Event: "VisitorApproached", Src: "idle", Dst: "listens"}, gpio.Low, gpio.Low},
+	}
+	for _, test := range tests {
+		setLevel(pin, test.initial)
+		controller.HandleTransition(test.transition)
+		if l := level(pin); l != test.level {
+			t.Errorf("%s from %s to %s: level %v, want %v",
+				test.transition.Event, test.transition.Src, test.transition.Dst, l, test.level)
+		}
+	}
+}
+
+func TestStateActions(t *testing.T) {
+	controller, pin := newTestController(t, `{
+		"outputs": {"ring": {"pin": "OUT_RING", "number": 10}},
+		"states": {
+			"idle": [{"output": "*", "pattern": "off"}],
+			"listens": [{"output": "ring", "pattern": "on"}]
+		}
+	}`)
+	defer gpioreg.Unregister(pin.N)
+
+	tests := []struct {
+		state string
+		level gpio.Level
+	}{
+		{"listens", gpio.High},
+		{"idle", gpio.Low},
+		{"listens", gpio.High},
+	}
+	for _, test := range tests {
+		controller.Apply(controller.StateActions()[test.state]...)
+		if l := level(pin); l != test.level {
+			t.Errorf("%s: level %v, want %v", test.state, l, test.level)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package outputs
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"periph.io/x/periph/conn/gpio"
+)
+
+// Output is an output pin driven by patterns
+type Output struct {
+	name      string
+	pin       gpio.PinOut
+	activeLow bool
+
+	mutex sync.Mutex
+	stop  chan struct{} // stops the running pattern; nil if none
+}
+
+// NewOutput creates new Output
+func NewOutput(name string, pin gpio.PinOut, activeLow bool) *Output {
+	return &Output{
+		name:      name,
+		pin:       pin,
+		activeLow: activeLow,
+	}
+}
+
+// Name returns the output name
+func (o *Output) Name() string {
+	return o.name
+}
+
+// Apply drives the output with the pattern.
+// The pattern being played is stopped.
+func (o *Output) Apply(pattern Pattern) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.stopPattern()
+	switch pattern.Kind {
+	case OffPattern:
+		o.set(false)
+	case OnPattern:
+		o.set(true)
+	case BlinkPattern, PulsePattern:
+		o.stop = make(chan struct{})
+		go o.play(pattern, o.stop)
+	}
+}
+
+// Close stops the pattern and turns the output off
+func (o *Output) Close() {
+	o.Apply(Pattern{Kind: OffPattern})
+}
+
+// stopPattern stops the running pattern.
+//
+// mutex must be held.
+func (o *Output) stopPattern() {
+	if o.stop != nil {
+		close(o.stop)
+		o.stop = nil
+	}
+}
+
+func (o *Output) play(pattern Pattern, stop chan struct{}) {
+	wait := func(d Duration) bool {
+		t := time.NewTimer(time.Duration(d))
+		defer t.Stop()
+		select {
+		case <-stop:
+			return false
+		case <-t.C:
+			return true
+		}
+	}
+
+	if pattern.Kind == PulsePattern {
+		o.setPlaying(true, stop)
+		if wait(pattern.On) {
+			o.setPlaying(false, stop)
+		}
+		return
+	}
+
+	for i := 0; pattern.Count == 0 || i < pattern.Count; i++ {
+		if !o.setPlaying(true, stop) || !wait(pattern.On) {
+			return
+		}
+		if !o.setPlaying(false, stop) || !wait(pattern.Off) {
+			return
+		}
+	}
+}
+
+// setPlaying sets the output level unless the pattern has been stopped
+func (o *Output) setPlaying(active bool, stop chan struct{}) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	select {
+	case <-stop:
+		return false
+	default:
+	}
+	o.set(active)
+	return true
+}
+
+// set sets the output level.
+//
+// mutex must be held.
+func (o *Output) set(active bool) {
+	level := gpio.Level(active != o.activeLow)
+	if err := o.pin.Out(level); err != nil {
+		log.Errorf("Output '%s': %v", o.name, err)
+	}
+}
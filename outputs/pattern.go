@@ -0,0 +1,71 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PatternKind is the way an output is driven
+type PatternKind string
+
+const (
+	// OffPattern turns the output off
+	OffPattern PatternKind = "off"
+	// OnPattern turns the output on
+	OnPattern PatternKind = "on"
+	// BlinkPattern toggles the output, On and Off are the phase durations
+	BlinkPattern PatternKind = "blink"
+	// PulsePattern turns the output on for On and then off
+	PulsePattern PatternKind = "pulse"
+)
+
+// Duration is time.Duration given as a string in JSON, e.g. "250ms"
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Pattern describes how an output is driven.
+// The blink is done in software, so the periods should not be shorter than
+// a few tens of milliseconds.
+type Pattern struct {
+	Kind PatternKind `json:"pattern"`
+	On   Duration    `json:"on,omitempty"`
+	Off  Duration    `json:"off,omitempty"`
+
+	// Count is the number of blinks, 0 blinks until the next pattern
+	Count int `json:"count,omitempty"`
+}
+
+// Validate checks the pattern parameters
+func (p Pattern) Validate() error {
+	switch p.Kind {
+	case OffPattern, OnPattern:
+	case BlinkPattern:
+		if p.On <= 0 || p.Off <= 0 {
+			return fmt.Errorf("Blink pattern requires positive 'on' and 'off' durations")
+		}
+		if p.Count < 0 {
+			return fmt.Errorf("Blink count must not be negative")
+		}
+	case PulsePattern:
+		if p.On <= 0 {
+			return fmt.Errorf("Pulse pattern requires positive 'on' duration")
+		}
+	default:
+		return fmt.Errorf("Unknown pattern '%s'", p.Kind)
+	}
+	return nil
+}
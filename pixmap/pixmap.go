@@ -0,0 +1,65 @@
+// Package pixmap converts images to the pixmaps of the paint engine, for the
+// character and the asset tools that don't need its sound libraries
+package pixmap
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/rmcsoft/chanim"
+)
+
+// FromImage converts the image to the pixel format of the paint engine
+func FromImage(img image.Image, pixFormat chanim.PixelFormat) *chanim.Pixmap {
+	bounds := img.Bounds()
+	pixSize := chanim.GetPixelSize(pixFormat)
+	pixmap := &chanim.Pixmap{
+		Data:        make([]byte, bounds.Dx()*bounds.Dy()*pixSize),
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		BytePerLine: bounds.Dx() * pixSize,
+		PixFormat:   pixFormat,
+	}
+
+	pos := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			switch pixFormat {
+			case chanim.RGB16:
+				// 5-6-5, little endian
+				v := uint16(c.R>>3)<<11 | uint16(c.G>>2)<<5 | uint16(c.B>>3)
+				pixmap.Data[pos] = byte(v)
+				pixmap.Data[pos+1] = byte(v >> 8)
+			case chanim.RGB32:
+				// 0xffRRGGBB, little endian
+				pixmap.Data[pos] = c.B
+				pixmap.Data[pos+1] = c.G
+				pixmap.Data[pos+2] = c.R
+				pixmap.Data[pos+3] = 0xff
+			}
+			pos += pixSize
+		}
+	}
+	return pixmap
+}
+
+// Rotate rotates the pixmap clockwise for the portrait display
+func Rotate(pixmap *chanim.Pixmap) *chanim.Pixmap {
+	pixSize := chanim.GetPixelSize(pixmap.PixFormat)
+	rotatedData := make([]byte, 0, pixmap.Width*pixmap.Height*pixSize)
+	for x := 0; x < pixmap.Width; x++ {
+		for y := pixmap.Height - 1; y >= 0; y-- {
+			pixOffset := y*pixmap.BytePerLine + x*pixSize
+			rotatedData = append(rotatedData, pixmap.Data[pixOffset:pixOffset+pixSize]...)
+		}
+	}
+
+	return &chanim.Pixmap{
+		Data:        rotatedData,
+		Width:       pixmap.Height,
+		Height:      pixmap.Width,
+		PixFormat:   pixmap.PixFormat,
+		BytePerLine: pixSize * pixmap.Height,
+	}
+}
@@ -0,0 +1,59 @@
+package pixmap
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/rmcsoft/chanim"
+)
+
+// testImage is 2x1 image with the red and the blue pixel
+func testImage() image.Image {
+	// The bounds don't start at 0, 0 as of a sprite of the sheet
+	img := image.NewRGBA(image.Rect(10, 20, 12, 21))
+	img.Set(10, 20, color.RGBA{R: 0xff, A: 0xff})
+	img.Set(11, 20, color.RGBA{B: 0xff, A: 0xff})
+	return img
+}
+
+func TestFromImage(t *testing.T) {
+	tests := []struct {
+		pixFormat chanim.PixelFormat
+		data      []byte
+	}{
+		{chanim.RGB16, []byte{0x00, 0xf8, 0x1f, 0x00}},
+		{chanim.RGB32, []byte{0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff}},
+	}
+	for _, test := range tests {
+		p := FromImage(testImage(), test.pixFormat)
+		pixSize := chanim.GetPixelSize(test.pixFormat)
+		if p.Width != 2 || p.Height != 1 || p.BytePerLine != 2*pixSize || p.PixFormat != test.pixFormat {
+			t.Errorf("%d bits: %dx%d, %d bytes per line", chanim.GetPixelDepth(test.pixFormat),
+				p.Width, p.Height, p.BytePerLine)
+		}
+		if !bytes.Equal(p.Data, test.data) {
+			t.Errorf("%d bits: data % x, want % x", chanim.GetPixelDepth(test.pixFormat), p.Data, test.data)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	// 3x2 pixmap of 1 byte pixels for the test: a b c / d e f
+	p := &chanim.Pixmap{
+		Data:        []byte{'a', 0, 'b', 0, 'c', 0, 'd', 0, 'e', 0, 'f', 0},
+		Width:       3,
+		Height:      2,
+		BytePerLine: 6,
+		PixFormat:   chanim.RGB16,
+	}
+	rotated := Rotate(p)
+	if rotated.Width != 2 || rotated.Height != 3 || rotated.BytePerLine != 4 {
+		t.Errorf("%dx%d, %d bytes per line, want 2x3, 4", rotated.Width, rotated.Height, rotated.BytePerLine)
+	}
+	// Clockwise: d a / e b / f c
+	if want := []byte{'d', 0, 'a', 0, 'e', 0, 'b', 0, 'f', 0, 'c', 0}; !bytes.Equal(rotated.Data, want) {
+		t.Errorf("Data %q, want %q", rotated.Data, want)
+	}
+}
@@ -1,41 +1,108 @@
 package hasp
 
 import (
+	"errors"
+
 	"github.com/aws/aws-sdk-go-v2/service/lexruntimeservice"
 	"github.com/rmcsoft/hasp/events"
 	"github.com/rmcsoft/hasp/haspaws"
 	"github.com/rmcsoft/hasp/sound"
-	"github.com/twinj/uuid"
+	log "github.com/sirupsen/logrus"
 )
 
 type processingState struct {
 	availableAnimations []string
 	currentAnimation    int
-	lrs                 *lexruntimeservice.Client
-	debug               bool
+	lex                 haspaws.LexParams
+	languageBots        map[string]haspaws.LexBot
+	session             *haspaws.Session
+}
+
+// ProcessingStateParams are the parameters of ProcessingState
+type ProcessingStateParams struct {
+	AvailableAnimations []string
+	Lex                 haspaws.LexParams
+
+	// LanguageBots is optional. If set, the bot of the conversation
+	// language is requested instead of Lex.Bot, see CtxLanguage.
+	LanguageBots map[string]haspaws.LexBot
+}
+
+// ConversationSession returns the session of the conversation or nil if
+// nothing is said yet
+func ConversationSession(ctx CharacterCtx) *haspaws.Session {
+	session, _ := ctx[CtxSession].(*haspaws.Session)
+	return session
 }
 
 // NewProcessingState creates new ProcessingState
 func NewProcessingState(availableAnimations []string, lrs *lexruntimeservice.Client, debug bool) State {
+	return NewProcessingStateWithParams(ProcessingStateParams{
+		AvailableAnimations: availableAnimations,
+		Lex: haspaws.LexParams{
+			Client:     lrs,
+			MaxRetries: haspaws.DefaultLexMaxRetries,
+			Debug:      debug,
+		},
+	})
+}
+
+// NewProcessingStateWithParams creates new ProcessingState
+func NewProcessingStateWithParams(params ProcessingStateParams) State {
 	return &processingState{
-		availableAnimations: availableAnimations,
-		lrs:                 lrs,
-		debug:               debug,
+		availableAnimations: params.AvailableAnimations,
+		lex:                 params.Lex,
+		languageBots:        params.LanguageBots,
 	}
 }
 
+// Enter posts the captured speech or the text utterance of TextInputEvent
 func (s *processingState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
 
-	data, _ := sound.GetSoundCapturedEventData(&event)
-	userId, ok := ctx[CtxUserId]
-	if !ok {
-		u := uuid.NewV4()
-		ctx[CtxUserId] = u.String()
-		userId = ctx[CtxUserId]
+	// The user id is set by SessionManager when the session starts
+	userId, _ := ctx[CtxUserId].(string)
+	if userId == "" {
+		return nil, errors.New("No user id in the context, is SessionManager set?")
 	}
-	lexResponseSource, err := haspaws.NewLexEventSource(s.lrs, data.AudioData, userId.(string), s.debug)
-	if err != nil {
-		panic(err)
+
+	lex := s.lex
+	if bot, ok := s.languageBots[Language(ctx)]; ok {
+		lex.Bot = bot
+	}
+	if language := Language(ctx); language != "" {
+		lex.Identity.Language = language
+	}
+
+	// The request updates a copy of the session, it replaces the session
+	// of the context when the reply is received, see Leave
+	if session := ConversationSession(ctx); session != nil {
+		s.session = session.Clone()
+	} else {
+		s.session = haspaws.NewSession()
+	}
+	s.session.Turns++
+	lex.Session = s.session
+
+	var lexResponseSource events.EventSource
+	if event.Name == events.TextInputEventName {
+		data, err := events.GetTextInputEventData(&event)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("Text input from %s: %s", data.Source, data.Text)
+		lexResponseSource, err = haspaws.NewLexTextEventSource(lex, data.Text, userId)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := sound.GetSoundCapturedEventData(&event)
+		if err != nil {
+			return nil, err
+		}
+		lexResponseSource, err = haspaws.NewLexEventSourceWithParams(lex, data.AudioData, userId)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return events.EventSources{
@@ -44,6 +111,13 @@ func (s *processingState) Enter(ctx CharacterCtx, event events.Event) (events.Ev
 }
 
 func (s *processingState) Leave(ctx CharacterCtx, event events.Event) bool {
+	// The session is updated by the request until the reply is sent,
+	// it is not taken if the state is left otherwise, e.g. SessionEnded
+	switch event.Name {
+	case haspaws.AwsRepliedEventName, haspaws.AwsRepliedCallEventName,
+		haspaws.AwsRepliedTypeEventName, sound.StopEventName:
+		ctx[CtxSession] = s.session
+	}
 	return true
 }
 
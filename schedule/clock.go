@@ -0,0 +1,22 @@
+package schedule
+
+import "time"
+
+// Clock is a source of time. It allows running the schedule against a fake clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock based on the system time
+type SystemClock struct{}
+
+// Now implements Clock
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements Clock
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
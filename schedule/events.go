@@ -0,0 +1,156 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rmcsoft/hasp/events"
+)
+
+// AnnouncementDueEventData is the AnnouncementDueEvent data
+type AnnouncementDueEventData struct {
+	Name string
+	At   time.Time
+}
+
+const (
+	// AnnouncementDueEventName is emitted when a scheduled announcement should be played
+	AnnouncementDueEventName = "AnnouncementDue"
+	// ClosedEventName is the conventional hotWordEvent of the after-hours mode
+	ClosedEventName = "Closed"
+)
+
+// NewAnnouncementDueEvent creates AnnouncementDueEvent
+func NewAnnouncementDueEvent(name string, at time.Time) *events.Event {
+	return &events.Event{
+		Name: AnnouncementDueEventName,
+		Args: []interface{}{
+			AnnouncementDueEventData{name, at},
+		},
+	}
+}
+
+// GetAnnouncementDueEventData gets AnnouncementDueEvent data
+func GetAnnouncementDueEventData(event *events.Event) (AnnouncementDueEventData, error) {
+	if event.Name != AnnouncementDueEventName {
+		return AnnouncementDueEventData{},
+			fmt.Errorf("The event must be named %s", AnnouncementDueEventName)
+	}
+
+	if len(event.Args) != 1 {
+		return AnnouncementDueEventData{},
+			errors.New("Event does not data")
+	}
+
+	data, ok := event.Args[0].(AnnouncementDueEventData)
+	if !ok {
+		return AnnouncementDueEventData{},
+			errors.New("Event does not contain announcement")
+	}
+
+	return data, nil
+}
+
+type announcementEventSource struct {
+	scheduler *Scheduler
+	eventChan chan *events.Event
+	quit      chan struct{}
+}
+
+// NewAnnouncementEventSource creates an event source that emits
+// AnnouncementDueEvent at the announcement times.
+// Announcements missed while the source was closed are emitted
+// immediately if they are within the grace period.
+func NewAnnouncementEventSource(scheduler *Scheduler) events.EventSource {
+	es := &announcementEventSource{
+		scheduler: scheduler,
+		eventChan: make(chan *events.Event),
+		quit:      make(chan struct{}),
+	}
+	go es.run()
+	return es
+}
+
+func (es *announcementEventSource) Name() string {
+	return "AnnouncementEventSource"
+}
+
+func (es *announcementEventSource) Events() chan *events.Event {
+	return es.eventChan
+}
+
+func (es *announcementEventSource) Close() {
+	close(es.quit)
+}
+
+func (es *announcementEventSource) run() {
+	defer close(es.eventChan)
+
+	clock := es.scheduler.clock
+	for {
+		announcement, at, ok := es.scheduler.nextAnnouncement()
+		if !ok {
+			return
+		}
+
+		if wait := at.Sub(clock.Now()); wait > 0 {
+			select {
+			case <-clock.After(wait):
+			case <-es.quit:
+				return
+			}
+		}
+
+		select {
+		case es.eventChan <- NewAnnouncementDueEvent(announcement.Name, at):
+			es.scheduler.announced(at)
+			// One announcement per source: the state is left to play it
+			return
+		case <-es.quit:
+			return
+		}
+	}
+}
+
+type modeChangeEventSource struct {
+	scheduler *Scheduler
+	eventChan chan *events.Event
+	quit      chan struct{}
+}
+
+// NewModeChangeEventSource creates an event source that emits StateChangedEvent
+// on every mode change, so the state can update its animation
+func NewModeChangeEventSource(scheduler *Scheduler) events.EventSource {
+	es := &modeChangeEventSource{
+		scheduler: scheduler,
+		eventChan: make(chan *events.Event),
+		quit:      make(chan struct{}),
+	}
+	go es.run()
+	return es
+}
+
+func (es *modeChangeEventSource) Name() string {
+	return "ModeChangeEventSource"
+}
+
+func (es *modeChangeEventSource) Events() chan *events.Event {
+	return es.eventChan
+}
+
+func (es *modeChangeEventSource) Close() {
+	close(es.quit)
+}
+
+func (es *modeChangeEventSource) run() {
+	defer close(es.eventChan)
+
+	for es.scheduler.waitModeChange(es.quit) {
+		select {
+		case es.eventChan <- &events.Event{Name: events.StateChangedEventName}:
+		case <-es.quit:
+			return
+		}
+	}
+}
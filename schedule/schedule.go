@@ -0,0 +1,303 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mode is the operating mode of the character
+type Mode string
+
+const (
+	// OpenMode is the normal behavior during opening hours
+	OpenMode Mode = "open"
+	// AfterHoursMode is used when the front desk is closed
+	AfterHoursMode Mode = "after-hours"
+	// QuietMode is used when the character should not disturb anybody
+	QuietMode Mode = "quiet"
+)
+
+// ModeSettings describes the behavior of the character in a mode
+type ModeSettings struct {
+	// IdleAnimations replaces the set of idle animations if not empty
+	IdleAnimations []string `json:"idleAnimations"`
+	// DisableHotWord disables hot word detection in the idle state
+	DisableHotWord bool `json:"disableHotWord"`
+	// HotWordEvent replaces the name of hot word events in the idle state,
+	// e.g. to reply "we're closed" instead of starting a conversation
+	HotWordEvent string `json:"hotWordEvent"`
+	// Volume is the playback volume (0..1), 1 if not set
+	Volume *float64 `json:"volume"`
+}
+
+// PlaybackVolume returns the playback volume for the mode
+func (ms ModeSettings) PlaybackVolume() float64 {
+	if ms.Volume == nil {
+		return 1
+	}
+	return *ms.Volume
+}
+
+// TimeOfDay is the time in minutes since midnight
+type TimeOfDay int
+
+// ParseTimeOfDay parses time in the "15:04" format
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time of day '%s': %v", s, err)
+	}
+	return TimeOfDay(t.Hour()*60 + t.Minute()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (tod *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*tod = v
+	return nil
+}
+
+func (tod TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", int(tod)/60, int(tod)%60)
+}
+
+// on returns the moment of the time of day on the date of t
+func (tod TimeOfDay) on(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, int(tod)/60, int(tod)%60, 0, 0, t.Location())
+}
+
+// Days is a set of week days. An empty set means every day.
+type Days []time.Weekday
+
+// UnmarshalJSON implements json.Unmarshaler
+func (days *Days) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+
+	parsed := make(Days, 0, len(names))
+	for _, name := range names {
+		day, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("Invalid week day '%s'", name)
+		}
+		parsed = append(parsed, day)
+	}
+	*days = parsed
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+func (days Days) contain(day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Window is a daily time window with a mode.
+// A window whose end is before its start lasts past midnight.
+type Window struct {
+	Mode  Mode      `json:"mode"`
+	Days  Days      `json:"days"`
+	Start TimeOfDay `json:"start"`
+	End   TimeOfDay `json:"end"`
+}
+
+func (w Window) contains(t time.Time) bool {
+	tod := TimeOfDay(t.Hour()*60 + t.Minute())
+	if w.Start <= w.End {
+		return w.Days.contain(t.Weekday()) && w.Start <= tod && tod < w.End
+	}
+
+	if tod >= w.Start {
+		return w.Days.contain(t.Weekday())
+	}
+	return tod < w.End && w.Days.contain(t.AddDate(0, 0, -1).Weekday())
+}
+
+// Announcement is a clip played at a set time
+type Announcement struct {
+	Name string    `json:"name"`
+	Days Days      `json:"days"`
+	At   TimeOfDay `json:"at"`
+}
+
+// Schedule describes the modes and announcements of the character
+type Schedule struct {
+	// Timezone is the IANA name of the location time zone, the local one if empty
+	Timezone string `json:"timezone"`
+	// DefaultMode is used outside of all windows
+	DefaultMode Mode `json:"defaultMode"`
+	// Windows are checked in order, the first matching wins
+	Windows       []Window              `json:"windows"`
+	Modes         map[Mode]ModeSettings `json:"modes"`
+	Announcements []Announcement        `json:"announcements"`
+	// AnnouncementGrace is how late an announcement may still be played,
+	// e.g. when the character was busy at the announcement time
+	AnnouncementGrace Duration `json:"announcementGrace"`
+
+	location *time.Location
+}
+
+// Duration is time.Duration in the time.ParseDuration format
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadSchedule loads Schedule from the JSON file
+func LoadSchedule(fileName string) (*Schedule, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := ParseSchedule(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse schedule '%s': %v", fileName, err)
+	}
+	return s, nil
+}
+
+// ParseSchedule parses Schedule from JSON
+func ParseSchedule(data []byte) (*Schedule, error) {
+	s := &Schedule{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schedule) init() error {
+	if len(s.DefaultMode) == 0 {
+		s.DefaultMode = OpenMode
+	}
+
+	s.location = time.Local
+	if len(s.Timezone) != 0 {
+		location, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("Invalid schedule timezone '%s': %v", s.Timezone, err)
+		}
+		s.location = location
+	}
+	return nil
+}
+
+// Location returns the time zone of the schedule
+func (s *Schedule) Location() *time.Location {
+	if s.location == nil {
+		return time.Local
+	}
+	return s.location
+}
+
+// ModeAt returns the mode at the moment
+func (s *Schedule) ModeAt(t time.Time) Mode {
+	t = t.In(s.Location())
+	for _, w := range s.Windows {
+		if w.contains(t) {
+			return w.Mode
+		}
+	}
+	return s.DefaultMode
+}
+
+// Settings returns the settings of the mode
+func (s *Schedule) Settings(mode Mode) ModeSettings {
+	return s.Modes[mode]
+}
+
+// NextModeChange returns the first moment after t with another mode.
+// It returns the zero time if the mode never changes.
+func (s *Schedule) NextModeChange(t time.Time) time.Time {
+	t = t.In(s.Location())
+	current := s.ModeAt(t)
+
+	candidates := make([]time.Time, 0, 2*8*len(s.Windows))
+	for d := 0; d <= 7; d++ {
+		date := t.AddDate(0, 0, d)
+		for _, w := range s.Windows {
+			for _, tod := range []TimeOfDay{w.Start, w.End} {
+				if candidate := tod.on(date); candidate.After(t) {
+					candidates = append(candidates, candidate)
+				}
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+
+	for _, candidate := range candidates {
+		if s.ModeAt(candidate) != current {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
+
+// NextAnnouncement returns the first announcement after t.
+// It returns false if there are no announcements.
+func (s *Schedule) NextAnnouncement(t time.Time) (Announcement, time.Time, bool) {
+	t = t.In(s.Location())
+
+	var next Announcement
+	var nextTime time.Time
+	found := false
+	for d := 0; d <= 7; d++ {
+		date := t.AddDate(0, 0, d)
+		for _, a := range s.Announcements {
+			if !a.Days.contain(date.Weekday()) {
+				continue
+			}
+			at := a.At.on(date)
+			if at.After(t) && (!found || at.Before(nextTime)) {
+				next, nextTime, found = a, at, true
+			}
+		}
+	}
+	return next, nextTime, found
+}
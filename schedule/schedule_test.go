@@ -0,0 +1,181 @@
+package schedule
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// at returns the time on 1-7 July 2019 in UTC, 1 July is a Monday
+func at(day int, clock string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", fmt.Sprintf("2019-07-%02d %s", day, clock))
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+const testSchedule = `{
+	"timezone": "UTC",
+	"defaultMode": "after-hours",
+	"windows": [
+		{"mode": "open", "days": ["mon", "tue", "wed", "thu", "fri"], "start": "09:00", "end": "18:00"},
+		{"mode": "quiet", "start": "22:00", "end": "07:00"}
+	],
+	"announcements": [
+		{"name": "opening", "days": ["mon", "tue", "wed", "thu", "fri"], "at": "09:00"},
+		{"name": "closing", "at": "17:50"}
+	],
+	"announcementGrace": "5m"
+}`
+
+func parseTestSchedule(t *testing.T) *Schedule {
+	s, err := ParseSchedule([]byte(testSchedule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestWindowContains(t *testing.T) {
+	day := Window{Mode: OpenMode, Days: Days{time.Monday}, Start: 9 * 60, End: 18 * 60}
+	night := Window{Mode: QuietMode, Days: Days{time.Friday}, Start: 22 * 60, End: 6 * 60}
+
+	tests := []struct {
+		window   Window
+		t        time.Time
+		contains bool
+	}{
+		{day, at(1, "09:00"), true},
+		{day, at(1, "17:59"), true},
+		{day, at(1, "08:59"), false},
+		{day, at(1, "18:00"), false},
+		{day, at(2, "10:00"), false},
+		// The night of Friday lasts past midnight into Saturday
+		{night, at(5, "22:00"), true},
+		{night, at(5, "23:59"), true},
+		{night, at(6, "00:00"), true},
+		{night, at(6, "05:59"), true},
+		{night, at(6, "06:00"), false},
+		{night, at(5, "21:59"), false},
+		{night, at(6, "22:00"), false},
+		// The morning of Friday belongs to the night of Thursday
+		{night, at(5, "05:00"), false},
+	}
+	for _, test := range tests {
+		if contains := test.window.contains(test.t); contains != test.contains {
+			t.Errorf("%s %v-%v contains %v: %v, want %v", test.window.Mode,
+				test.window.Start, test.window.End, test.t, contains, test.contains)
+		}
+	}
+}
+
+func TestModeAt(t *testing.T) {
+	s := parseTestSchedule(t)
+
+	tests := []struct {
+		t    time.Time
+		mode Mode
+	}{
+		{at(1, "08:59"), AfterHoursMode},
+		{at(1, "09:00"), OpenMode},
+		{at(1, "17:59"), OpenMode},
+		{at(1, "18:00"), AfterHoursMode},
+		{at(1, "22:00"), QuietMode},
+		{at(2, "00:00"), QuietMode},
+		{at(2, "06:59"), QuietMode},
+		{at(2, "07:00"), AfterHoursMode},
+		{at(6, "12:00"), AfterHoursMode},
+		// The schedule time zone is used whatever the location of the time
+		{at(1, "09:00").In(time.FixedZone("UTC+3", 3*60*60)), OpenMode},
+	}
+	for _, test := range tests {
+		if mode := s.ModeAt(test.t); mode != test.mode {
+			t.Errorf("Mode at %v: %s, want %s", test.t, mode, test.mode)
+		}
+	}
+}
+
+func TestNextModeChange(t *testing.T) {
+	s := parseTestSchedule(t)
+
+	tests := []struct {
+		t    time.Time
+		next time.Time
+	}{
+		{at(1, "08:00"), at(1, "09:00")},
+		// The change is after the moment even at a boundary
+		{at(1, "09:00"), at(1, "18:00")},
+		{at(1, "18:00"), at(1, "22:00")},
+		{at(1, "23:00"), at(2, "07:00")},
+		{at(2, "07:00"), at(2, "09:00")},
+		// No open window on Saturday and Sunday
+		{at(5, "22:30"), at(6, "07:00")},
+		{at(6, "07:00"), at(6, "22:00")},
+		{at(7, "07:00"), at(7, "22:00")},
+	}
+	for _, test := range tests {
+		if next := s.NextModeChange(test.t); !next.Equal(test.next) {
+			t.Errorf("Mode change after %v: %v, want %v", test.t, next, test.next)
+		}
+	}
+
+	always, err := ParseSchedule([]byte(`{"timezone": "UTC"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := always.NextModeChange(at(1, "08:00")); !next.IsZero() {
+		t.Errorf("Mode change of the schedule without windows at %v", next)
+	}
+	if mode := always.ModeAt(at(1, "08:00")); mode != OpenMode {
+		t.Errorf("Default mode %s, want %s", mode, OpenMode)
+	}
+}
+
+func TestNextAnnouncement(t *testing.T) {
+	s := parseTestSchedule(t)
+
+	tests := []struct {
+		t    time.Time
+		name string
+		at   time.Time
+	}{
+		{at(1, "08:00"), "opening", at(1, "09:00")},
+		{at(1, "09:00"), "closing", at(1, "17:50")},
+		{at(1, "17:50"), "opening", at(2, "09:00")},
+		// No opening announcement on Saturday and Sunday
+		{at(5, "18:00"), "closing", at(6, "17:50")},
+		{at(6, "18:00"), "closing", at(7, "17:50")},
+		{at(7, "18:00"), "opening", at(1, "09:00").AddDate(0, 0, 7)},
+	}
+	for _, test := range tests {
+		announcement, next, ok := s.NextAnnouncement(test.t)
+		if !ok || announcement.Name != test.name || !next.Equal(test.at) {
+			t.Errorf("Announcement after %v: '%s' at %v (%v), want '%s' at %v",
+				test.t, announcement.Name, next, ok, test.name, test.at)
+		}
+	}
+
+	empty, err := ParseSchedule([]byte(`{"timezone": "UTC"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := empty.NextAnnouncement(at(1, "08:00")); ok {
+		t.Errorf("Announcement of the schedule without announcements")
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	tests := []string{
+		`{"timezone": "Nowhere/Nothing"}`,
+		`{"windows": [{"mode": "open", "start": "25:00", "end": "18:00"}]}`,
+		`{"windows": [{"mode": "open", "days": ["someday"], "start": "09:00", "end": "18:00"}]}`,
+		`{"announcementGrace": "five minutes"}`,
+		`{"windows": `,
+	}
+	for _, data := range tests {
+		if _, err := ParseSchedule([]byte(data)); err == nil {
+			t.Errorf("No error parsing %s", data)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Scheduler tracks the current mode and due announcements of a Schedule
+type Scheduler struct {
+	schedule *Schedule
+	clock    Clock
+
+	mutex         sync.Mutex
+	lastAnnounced time.Time
+}
+
+// NewScheduler creates new Scheduler
+func NewScheduler(schedule *Schedule, clock Clock) *Scheduler {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Scheduler{
+		schedule:      schedule,
+		clock:         clock,
+		lastAnnounced: clock.Now(),
+	}
+}
+
+// Schedule returns the schedule
+func (s *Scheduler) Schedule() *Schedule {
+	return s.schedule
+}
+
+// Clock returns the clock used by the scheduler
+func (s *Scheduler) Clock() Clock {
+	return s.clock
+}
+
+// CurrentMode returns the current mode
+func (s *Scheduler) CurrentMode() Mode {
+	return s.schedule.ModeAt(s.clock.Now())
+}
+
+// CurrentSettings returns the settings of the current mode
+func (s *Scheduler) CurrentSettings() ModeSettings {
+	return s.schedule.Settings(s.CurrentMode())
+}
+
+// Watch calls fn with the current mode and then on every mode change.
+// It returns a function that stops watching.
+func (s *Scheduler) Watch(fn func(mode Mode, settings ModeSettings)) func() {
+	quit := make(chan struct{})
+	go func() {
+		for {
+			mode := s.CurrentMode()
+			log.Infof("Schedule: mode '%s'", mode)
+			fn(mode, s.schedule.Settings(mode))
+
+			if !s.waitModeChange(quit) {
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+	}
+}
+
+// waitModeChange returns false if quit is closed or the mode never changes
+func (s *Scheduler) waitModeChange(quit chan struct{}) bool {
+	now := s.clock.Now()
+	next := s.schedule.NextModeChange(now)
+	if next.IsZero() {
+		<-quit
+		return false
+	}
+
+	select {
+	case <-s.clock.After(next.Sub(now)):
+		return true
+	case <-quit:
+		return false
+	}
+}
+
+// nextAnnouncement returns the next announcement after the last announced one.
+// Announcements later than the grace period are skipped.
+func (s *Scheduler) nextAnnouncement() (Announcement, time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	grace := time.Duration(s.schedule.AnnouncementGrace)
+	now := s.clock.Now()
+	for {
+		announcement, at, ok := s.schedule.NextAnnouncement(s.lastAnnounced)
+		if !ok || at.Add(grace).After(now) {
+			return announcement, at, ok
+		}
+		log.Infof("Schedule: announcement '%s' at %v is missed", announcement.Name, at)
+		s.lastAnnounced = at
+	}
+}
+
+func (s *Scheduler) announced(at time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if at.After(s.lastAnnounced) {
+		s.lastAnnounced = at
+	}
+}
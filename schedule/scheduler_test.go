@@ -0,0 +1,240 @@
+package schedule
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmcsoft/hasp/events"
+)
+
+// fakeClock is the Clock moved by the test, see Advance
+type fakeClock struct {
+	mutex    sync.Mutex
+	now      time.Time
+	sleepers []sleeper
+}
+
+type sleeper struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s := sleeper{c.now.Add(d), make(chan time.Time, 1)}
+	if d <= 0 {
+		s.ch <- c.now
+		return s.ch
+	}
+	c.sleepers = append(c.sleepers, s)
+	return s.ch
+}
+
+// Set moves the clock to the time without waking the sleepers up
+func (c *fakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock to the time and wakes the sleepers up
+func (c *fakeClock) Advance(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+	sleepers := c.sleepers[:0]
+	for _, s := range c.sleepers {
+		if now.Before(s.until) {
+			sleepers = append(sleepers, s)
+		} else {
+			s.ch <- now
+		}
+	}
+	c.sleepers = sleepers
+}
+
+// WaitSleeper waits for a sleeper until the time, e.g. of an event source
+// that waits for the next event
+func (c *fakeClock) WaitSleeper(t *testing.T, until time.Time) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mutex.Lock()
+		for _, s := range c.sleepers {
+			if s.until.Equal(until) {
+				c.mutex.Unlock()
+				return
+			}
+		}
+		c.mutex.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Nobody sleeps until %v", until)
+}
+
+// receive returns the next event of the source or nil if it is closed
+func receive(t *testing.T, source events.EventSource) *events.Event {
+	t.Helper()
+	select {
+	case event := <-source.Events():
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("No event from %s", source.Name())
+		return nil
+	}
+}
+
+// checkNoEvent checks the source waits
+func checkNoEvent(t *testing.T, source events.EventSource) {
+	t.Helper()
+	select {
+	case event, ok := <-source.Events():
+		t.Fatalf("Unexpected event %v (%v) from %s", event, ok, source.Name())
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func checkAnnouncement(t *testing.T, event *events.Event, name string, at time.Time) {
+	t.Helper()
+	if event == nil {
+		t.Fatalf("No announcement, want '%s' at %v", name, at)
+	}
+	data, err := GetAnnouncementDueEventData(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Name != name || !data.At.Equal(at) {
+		t.Errorf("Announcement '%s' at %v, want '%s' at %v", data.Name, data.At, name, at)
+	}
+}
+
+func TestSchedulerNextAnnouncement(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		at   time.Time
+	}{
+		{"opening", at(1, "08:00"), at(1, "09:00")},
+		{"opening", at(1, "09:00"), at(1, "09:00")},
+		// The announcement is due within the grace period
+		{"opening", at(1, "09:04"), at(1, "09:00")},
+		// The later one is missed
+		{"closing", at(1, "09:05"), at(1, "17:50")},
+		{"opening", at(1, "17:56"), at(2, "09:00")},
+	}
+	for _, test := range tests {
+		clock := newFakeClock(at(1, "08:00"))
+		scheduler := NewScheduler(parseTestSchedule(t), clock)
+		clock.Set(test.now)
+		announcement, next, ok := scheduler.nextAnnouncement()
+		if !ok || announcement.Name != test.name || !next.Equal(test.at) {
+			t.Errorf("Announcement at %v: '%s' at %v (%v), want '%s' at %v",
+				test.now, announcement.Name, next, ok, test.name, test.at)
+		}
+	}
+}
+
+func TestAnnouncementEventSource(t *testing.T) {
+	clock := newFakeClock(at(1, "08:00"))
+	scheduler := NewScheduler(parseTestSchedule(t), clock)
+
+	source := NewAnnouncementEventSource(scheduler)
+	clock.WaitSleeper(t, at(1, "09:00"))
+	clock.Advance(at(1, "08:59"))
+	checkNoEvent(t, source)
+	clock.Advance(at(1, "09:00"))
+	checkAnnouncement(t, receive(t, source), "opening", at(1, "09:00"))
+	// One announcement per source
+	if event := receive(t, source); event != nil {
+		t.Errorf("Event %s after the announcement", event.Name)
+	}
+
+	// The announcement is not repeated by the next source
+	clock.Advance(at(1, "09:01"))
+	source = NewAnnouncementEventSource(scheduler)
+	clock.WaitSleeper(t, at(1, "17:50"))
+	source.Close()
+	if event := receive(t, source); event != nil {
+		t.Errorf("Event %s after closing", event.Name)
+	}
+
+	// The announcement missed while the character was busy is played late
+	clock.Advance(at(1, "17:52"))
+	source = NewAnnouncementEventSource(scheduler)
+	checkAnnouncement(t, receive(t, source), "closing", at(1, "17:50"))
+
+	// The one missed for longer than the grace period is skipped
+	clock.Advance(at(2, "09:10"))
+	source = NewAnnouncementEventSource(scheduler)
+	clock.WaitSleeper(t, at(2, "17:50"))
+	clock.Advance(at(2, "17:50"))
+	checkAnnouncement(t, receive(t, source), "closing", at(2, "17:50"))
+}
+
+func TestModeChangeEventSource(t *testing.T) {
+	clock := newFakeClock(at(1, "08:00"))
+	scheduler := NewScheduler(parseTestSchedule(t), clock)
+	if mode := scheduler.CurrentMode(); mode != AfterHoursMode {
+		t.Errorf("Mode %s, want %s", mode, AfterHoursMode)
+	}
+
+	source := NewModeChangeEventSource(scheduler)
+	defer source.Close()
+	for _, change := range []time.Time{at(1, "09:00"), at(1, "18:00"), at(1, "22:00")} {
+		clock.WaitSleeper(t, change)
+		clock.Advance(change.Add(-time.Minute))
+		checkNoEvent(t, source)
+		clock.Advance(change)
+		if event := receive(t, source); event == nil || event.Name != events.StateChangedEventName {
+			t.Fatalf("No %s at %v", events.StateChangedEventName, change)
+		}
+	}
+	if mode := scheduler.CurrentMode(); mode != QuietMode {
+		t.Errorf("Mode %s, want %s", mode, QuietMode)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	clock := newFakeClock(at(1, "17:00"))
+	scheduler := NewScheduler(parseTestSchedule(t), clock)
+
+	modes := make(chan Mode, 1)
+	stop := scheduler.Watch(func(mode Mode, settings ModeSettings) {
+		modes <- mode
+	})
+	defer stop()
+
+	for _, test := range []struct {
+		t    time.Time
+		mode Mode
+	}{
+		{at(1, "17:00"), OpenMode},
+		{at(1, "18:00"), AfterHoursMode},
+		{at(1, "22:00"), QuietMode},
+	} {
+		if !test.t.Equal(clock.Now()) {
+			clock.WaitSleeper(t, test.t)
+			clock.Advance(test.t)
+		}
+		select {
+		case mode := <-modes:
+			if mode != test.mode {
+				t.Errorf("Mode %s at %v, want %s", mode, test.t, test.mode)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("No mode at %v", test.t)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package hasp
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/metrics"
+)
+
+// The reasons of the session end, see SessionEndedEventData.Reason
+const (
+	SessionEndIdle     = "idle"
+	SessionEndTimeout  = "timeout"
+	SessionEndMaxTurns = "max-turns"
+)
+
+var sessionCounter = metrics.NewCounterVec("hasp_sessions_total",
+	"Number of ended conversation sessions by reason", "reason")
+
+// SessionManagerParams are the parameters of SessionManager
+type SessionManagerParams struct {
+	// TurnStates are entered for each utterance of the visitor,
+	// e.g. "processing". The first one entered starts the session.
+	TurnStates []string
+	// ListenStates wait for the next utterance, e.g. "listens".
+	// SessionEnded is fired if one of them is entered after the last turn.
+	ListenStates []string
+	// IdleStates end the session when entered, e.g. "idle"
+	IdleStates []string
+
+	// InactivityTimeout is optional. If set, SessionEnded is fired when
+	// no state is entered for the time.
+	InactivityTimeout time.Duration
+	// MaxTurns is optional. If set, the session ends after the turns.
+	MaxTurns int
+	// AfterFunc is optional, it starts the inactivity timer.
+	// time.AfterFunc is used by default.
+	AfterFunc func(d time.Duration, f func()) SessionTimer
+
+	// OnStart and OnEnd are optional. The context still has the session
+	// when OnEnd is called.
+	OnStart func(ctx CharacterCtx)
+	OnEnd   func(ctx CharacterCtx, reason string)
+}
+
+// SessionTimer is the inactivity timer, e.g. *time.Timer
+type SessionTimer interface {
+	Stop() bool
+}
+
+func systemAfterFunc(d time.Duration, f func()) SessionTimer {
+	return time.AfterFunc(d, f)
+}
+
+// SessionManager keeps the conversation session of the character.
+// The session starts with the first utterance and ends on every return to
+// an idle state, whatever the path: the user id, the Lex session and the
+// language are removed from the context then. SessionEnded is fired to
+// lead the character to an idle state after the inactivity timeout or the
+// last turn.
+type SessionManager struct {
+	params SessionManagerParams
+	inject func(event *events.Event) error
+
+	id        int
+	active    bool
+	turns     int
+	endReason string
+	timer     SessionTimer
+}
+
+// NewSessionManager creates new SessionManager
+func NewSessionManager(params SessionManagerParams) *SessionManager {
+	if params.AfterFunc == nil {
+		params.AfterFunc = systemAfterFunc
+	}
+	return &SessionManager{params: params}
+}
+
+// SetSessionManager sets the manager of the conversation sessions
+func (c *Character) SetSessionManager(sessions *SessionManager) {
+	sessions.inject = c.InjectEvent
+	c.sessions = sessions
+}
+
+// enterState is called before the state is entered
+func (m *SessionManager) enterState(ctx CharacterCtx, state string) {
+	switch {
+	case containsState(m.params.IdleStates, state):
+		if m.active {
+			m.end(ctx)
+		}
+		return
+	case containsState(m.params.TurnStates, state):
+		if !m.active {
+			m.start(ctx)
+		}
+		m.turns++
+	case containsState(m.params.ListenStates, state):
+		if m.active && m.params.MaxTurns > 0 && m.turns >= m.params.MaxTurns {
+			log.Infof("Session %d: %d turns are taken", m.id, m.turns)
+			m.fireEnded(SessionEndMaxTurns)
+		}
+	}
+
+	if m.active {
+		m.resetTimer()
+	}
+}
+
+// acceptEvent drops SessionEnded of an ended session, e.g. fired by the
+// timer while the character was going idle
+func (m *SessionManager) acceptEvent(event *events.Event) bool {
+	if event.Name != events.SessionEndedEventName {
+		return true
+	}
+
+	data, err := events.GetSessionEndedEventData(event)
+	if err != nil || !m.active || data.Session != m.id {
+		return false
+	}
+	m.endReason = data.Reason
+	return true
+}
+
+func (m *SessionManager) start(ctx CharacterCtx) {
+	m.id++
+	m.active = true
+	m.turns = 0
+	m.endReason = ""
+	ctx[CtxUserId] = uuid.NewV4().String()
+
+	log.Infof("Session %d started", m.id)
+	if m.params.OnStart != nil {
+		m.params.OnStart(ctx)
+	}
+}
+
+func (m *SessionManager) end(ctx CharacterCtx) {
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+
+	reason := m.endReason
+	if reason == "" {
+		reason = SessionEndIdle
+	}
+	log.Infof("Session %d ended (%s) after %d turns", m.id, reason, m.turns)
+	sessionCounter.With(reason).Inc()
+	if m.params.OnEnd != nil {
+		m.params.OnEnd(ctx, reason)
+	}
+
+	delete(ctx, CtxUserId)
+	delete(ctx, CtxSession)
+	delete(ctx, CtxLanguage)
+	m.active = false
+}
+
+func (m *SessionManager) resetTimer() {
+	if m.params.InactivityTimeout <= 0 {
+		return
+	}
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+
+	id := m.id
+	m.timer = m.params.AfterFunc(m.params.InactivityTimeout, func() {
+		log.Infof("Session %d: nothing happens for %v", id, m.params.InactivityTimeout)
+		m.fireEndedOf(SessionEndTimeout, id)
+	})
+}
+
+func (m *SessionManager) fireEnded(reason string) {
+	m.fireEndedOf(reason, m.id)
+}
+
+func (m *SessionManager) fireEndedOf(reason string, id int) {
+	if err := m.inject(events.NewSessionEndedEvent(reason, id)); err != nil {
+		log.Errorf("Failed to fire %s: %v", events.SessionEndedEventName, err)
+	}
+}
+
+func containsState(states []string, state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
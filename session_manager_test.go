@@ -0,0 +1,474 @@
+package hasp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/haspaws"
+	"github.com/rmcsoft/hasp/sound"
+)
+
+// fakeTimer is the inactivity timer fired by the test
+type fakeTimer struct {
+	f       func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	stopped := t.stopped
+	t.stopped = true
+	return !stopped
+}
+
+// sessionTest drives SessionManager as Character does, the injected
+// events are recorded instead of being sent to the FSM
+type sessionTest struct {
+	t        *testing.T
+	sessions *SessionManager
+	ctx      CharacterCtx
+	injected []*events.Event
+	timers   []*fakeTimer
+	ended    []string
+}
+
+func newSessionTest(t *testing.T, params SessionManagerParams) *sessionTest {
+	test := &sessionTest{t: t, ctx: make(CharacterCtx)}
+	params.TurnStates = []string{"processing"}
+	params.ListenStates = []string{"listens", "type"}
+	params.IdleStates = []string{"idle"}
+	params.AfterFunc = func(d time.Duration, f func()) SessionTimer {
+		timer := &fakeTimer{f: f}
+		test.timers = append(test.timers, timer)
+		return timer
+	}
+	params.OnEnd = func(ctx CharacterCtx, reason string) {
+		test.ended = append(test.ended, reason)
+	}
+	test.sessions = NewSessionManager(params)
+	test.sessions.inject = func(event *events.Event) error {
+		test.injected = append(test.injected, event)
+		return nil
+	}
+	return test
+}
+
+// enter enters the states in turn, the context gets a Lex session and a
+// language in the first turn as processing and selectLanguage set them
+func (test *sessionTest) enter(states ...string) {
+	for _, state := range states {
+		test.sessions.enterState(test.ctx, state)
+		if state == "processing" {
+			test.ctx[CtxSession] = "session"
+			test.ctx[CtxLanguage] = "es"
+		}
+	}
+}
+
+// fire fires the last inactivity timer unless it is stopped
+func (test *sessionTest) fire() {
+	timer := test.timers[len(test.timers)-1]
+	if !timer.stopped {
+		timer.f()
+	}
+}
+
+// takeEnded returns the injected SessionEnded and accepts it
+func (test *sessionTest) takeEnded() events.SessionEndedEventData {
+	test.t.Helper()
+	if len(test.injected) != 1 {
+		test.t.Fatalf("%d events are injected, want SessionEnded", len(test.injected))
+	}
+	event := test.injected[0]
+	test.injected = nil
+	data, err := events.GetSessionEndedEventData(event)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	if !test.sessions.acceptEvent(event) {
+		test.t.Fatalf("SessionEnded of session %d is dropped", data.Session)
+	}
+	return data
+}
+
+func (test *sessionTest) checkEnded(reasons ...string) {
+	test.t.Helper()
+	if len(test.ended) != len(reasons) {
+		test.t.Fatalf("Sessions ended %v, want %v", test.ended, reasons)
+	}
+	for i := range reasons {
+		if test.ended[i] != reasons[i] {
+			test.t.Fatalf("Sessions ended %v, want %v", test.ended, reasons)
+		}
+	}
+	for _, key := range []string{CtxUserId, CtxSession, CtxLanguage} {
+		if _, ok := test.ctx[key]; ok {
+			test.t.Errorf("%s is kept in the context", key)
+		}
+	}
+}
+
+func TestSessionResetOnIdle(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []string
+	}{
+		{
+			name:   "listens stopped",
+			states: []string{"tells-help", "listens", "processing", "tells-aws", "listens", "idle"},
+		},
+		{
+			name: "three empty captures",
+			states: []string{"tells-help", "listens", "processing", "tells-aws",
+				"listens", "tells-there", "listens", "tells-there", "listens", "idle"},
+		},
+		{
+			name:   "tells bye",
+			states: []string{"tells-help", "listens", "processing", "tells-bye", "goodbye", "idle"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			st := newSessionTest(t, SessionManagerParams{InactivityTimeout: time.Minute})
+			st.enter(test.states...)
+			st.checkEnded(SessionEndIdle)
+			if len(st.injected) != 0 {
+				t.Errorf("%d events are injected", len(st.injected))
+			}
+			if !st.timers[len(st.timers)-1].stopped {
+				t.Errorf("The inactivity timer is not stopped")
+			}
+
+			// The next visitor gets a new session
+			st.enter("listens", "processing")
+			if st.sessions.id != 2 {
+				t.Errorf("Session %d, want 2", st.sessions.id)
+			}
+			if _, ok := st.ctx[CtxUserId]; !ok {
+				t.Errorf("No user id in the new session")
+			}
+		})
+	}
+}
+
+func TestSessionUserId(t *testing.T) {
+	st := newSessionTest(t, SessionManagerParams{})
+	st.enter("listens")
+	if _, ok := st.ctx[CtxUserId]; ok {
+		t.Errorf("The user id is set before the first turn")
+	}
+	st.enter("processing")
+	first, _ := st.ctx[CtxUserId].(string)
+	st.enter("tells-aws", "listens", "processing")
+	if userId, _ := st.ctx[CtxUserId].(string); first == "" || userId != first {
+		t.Errorf("User id '%s' in the second turn, want '%s'", userId, first)
+	}
+	st.enter("tells-aws", "listens", "idle", "listens", "processing")
+	if userId, _ := st.ctx[CtxUserId].(string); userId == "" || userId == first {
+		t.Errorf("User id '%s' in the next session, want a new one", userId)
+	}
+}
+
+func TestSessionInactivityTimeout(t *testing.T) {
+	st := newSessionTest(t, SessionManagerParams{InactivityTimeout: time.Minute})
+	st.enter("tells-help", "listens")
+	if len(st.timers) != 0 {
+		t.Fatalf("The inactivity timer is started before the session")
+	}
+
+	st.enter("processing", "tells-aws", "listens")
+	if len(st.timers) != 3 {
+		t.Fatalf("%d timers are started, want one per state", len(st.timers))
+	}
+	for _, timer := range st.timers[:2] {
+		if !timer.stopped {
+			t.Errorf("The previous timer is not stopped")
+		}
+	}
+
+	st.fire()
+	data := st.takeEnded()
+	if data.Reason != SessionEndTimeout || data.Session != 1 {
+		t.Errorf("SessionEnded(%s, %d), want (%s, 1)", data.Reason, data.Session, SessionEndTimeout)
+	}
+	st.enter("goodbye", "idle")
+	st.checkEnded(SessionEndTimeout)
+}
+
+func TestSessionMaxTurns(t *testing.T) {
+	st := newSessionTest(t, SessionManagerParams{MaxTurns: 2})
+	st.enter("tells-help", "listens", "processing", "tells-aws", "listens", "processing", "tells-aws")
+	if len(st.injected) != 0 {
+		t.Fatalf("SessionEnded is fired before the last turn is listened")
+	}
+
+	// The type state listens as well
+	st.enter("type")
+	data := st.takeEnded()
+	if data.Reason != SessionEndMaxTurns || data.Session != 1 {
+		t.Errorf("SessionEnded(%s, %d), want (%s, 1)", data.Reason, data.Session, SessionEndMaxTurns)
+	}
+	st.enter("goodbye", "idle")
+	st.checkEnded(SessionEndMaxTurns)
+}
+
+func TestSessionStaleEnded(t *testing.T) {
+	st := newSessionTest(t, SessionManagerParams{InactivityTimeout: time.Minute})
+	st.enter("listens", "processing", "tells-aws", "listens")
+	timeout := st.timers[len(st.timers)-1]
+	st.enter("idle")
+
+	// The timer of the ended session fires while the next session starts
+	st.enter("listens", "processing")
+	timeout.f()
+	tests := []struct {
+		name  string
+		event *events.Event
+	}{
+		{"timer of the ended session", st.injected[0]},
+		{"unknown session", events.NewSessionEndedEvent(SessionEndTimeout, 3)},
+	}
+	for _, test := range tests {
+		if st.sessions.acceptEvent(test.event) {
+			t.Errorf("%s: SessionEnded is accepted", test.name)
+		}
+	}
+
+	st.enter("tells-aws", "listens", "idle")
+	if st.sessions.acceptEvent(events.NewSessionEndedEvent(SessionEndTimeout, 2)) {
+		t.Errorf("SessionEnded is accepted after the session ended")
+	}
+	st.checkEnded(SessionEndIdle, SessionEndIdle)
+
+	if !st.sessions.acceptEvent(&events.Event{Name: events.StateGoIdleName}) {
+		t.Errorf("Other events are dropped")
+	}
+}
+
+// conversationEventDescs are the conversation transitions of the character
+// of cmd/hasp, see makeCharacter
+func conversationEventDescs() EventDescs {
+	return EventDescs{
+		{Name: sound.HotWordDetectedEventName, Src: []string{"idle", "sensor-triggered"}, Dst: "tells-help"},
+		{Name: sound.HotWordWithDataDetectedEventName, Src: []string{"idle", "sensor-triggered"}, Dst: "processing"},
+		{Name: sound.SoundPlayedEventName, Src: []string{"tells-help", "tells-aws", "tells-there", "tells-fullhelp"}, Dst: "listens"},
+		{Name: sound.SoundCapturedEventName, Src: []string{"listens", "type"}, Dst: "processing"},
+		{Name: sound.StopEventName, Src: []string{"listens"}, Dst: "idle"},
+		{Name: haspaws.AwsRepliedEventName, Src: []string{"processing"}, Dst: "tells-aws"},
+		{Name: haspaws.AwsRepliedTypeEventName, Src: []string{"processing"}, Dst: "tell-type"},
+		{Name: haspaws.AwsRepliedCallEventName, Src: []string{"processing"}, Dst: "call"},
+		{Name: sound.SoundPlayedEventName, Src: []string{"call"}, Dst: "tell-msg-sent"},
+		{Name: sound.SoundPlayedEventName, Src: []string{"tell-msg-sent"}, Dst: "idle"},
+		{Name: sound.StopEventName, Src: []string{"processing"}, Dst: "tells-bye"},
+		{Name: sound.SoundPlayedEventName, Src: []string{"tell-type"}, Dst: "type"},
+		{Name: sound.SoundPlayedEventName, Src: []string{"tells-bye"}, Dst: "goodbye"},
+		{Name: events.StateGoIdleName, Src: []string{"goodbye"}, Dst: "idle"},
+		{Name: events.SessionEndedEventName, Src: conversationStates, Dst: "goodbye"},
+		{Name: sound.SoundEmptyEventName, Src: []string{"listens", "type"}, Dst: "tells-there"},
+	}
+}
+
+// conversationStates are the states SessionEnded leads to goodbye from
+var conversationStates = []string{"call", "listens", "processing", "sensor-triggered", "tell-msg-sent",
+	"tell-type", "tells-aws", "tells-bye", "tells-fullhelp", "tells-help", "tells-there", "type"}
+
+// inactivityTimeout is the step of conversationTest firing the inactivity
+// timer
+const inactivityTimeout = "inactivity timeout"
+
+// conversationTest drives the character FSM with SessionManager as Run does.
+// The events injected by SessionManager are handled after the event.
+type conversationTest struct {
+	t        *testing.T
+	c        *Character
+	injected []*events.Event
+	timers   []*fakeTimer
+	ended    []string
+}
+
+func newConversationTest(t *testing.T, params SessionManagerParams) *conversationTest {
+	test := &conversationTest{t: t}
+	test.c, _ = newTransitionsCharacter("idle", append(conversationStates, "idle", "goodbye"),
+		conversationEventDescs(), nil)
+	// processing sets the Lex session
+	test.c.OnEnter("processing", func(ctx CharacterCtx, event events.Event) {
+		ctx[CtxSession] = "session"
+	})
+
+	params.TurnStates = []string{"processing"}
+	params.ListenStates = []string{"listens", "type"}
+	params.IdleStates = []string{"idle"}
+	params.AfterFunc = func(d time.Duration, f func()) SessionTimer {
+		timer := &fakeTimer{f: f}
+		test.timers = append(test.timers, timer)
+		return timer
+	}
+	params.OnEnd = func(ctx CharacterCtx, reason string) {
+		test.ended = append(test.ended, reason)
+	}
+	test.c.SetSessionManager(NewSessionManager(params))
+	test.c.sessions.inject = func(event *events.Event) error {
+		test.injected = append(test.injected, event)
+		return nil
+	}
+	return test
+}
+
+// run handles the events named by the steps, inactivityTimeout fires the
+// last inactivity timer. Each step must change the state.
+func (test *conversationTest) run(steps ...string) {
+	test.t.Helper()
+	for _, step := range steps {
+		src := test.c.fsm.Current()
+		if step == inactivityTimeout {
+			if len(test.timers) == 0 || test.timers[len(test.timers)-1].stopped {
+				test.t.Fatalf("No inactivity timer in '%s'", src)
+			}
+			test.timers[len(test.timers)-1].f()
+		} else {
+			test.c.handleEvent(&events.Event{Name: step})
+		}
+		test.handleInjected()
+		if test.c.fsm.Current() == src {
+			test.t.Fatalf("%s is not handled in '%s'", step, src)
+		}
+	}
+}
+
+func (test *conversationTest) handleInjected() {
+	for len(test.injected) > 0 {
+		event := test.injected[0]
+		test.injected = test.injected[1:]
+		test.c.handleEvent(event)
+	}
+}
+
+// steps joins the steps of the parts
+func steps(parts ...[]string) []string {
+	var joined []string
+	for _, part := range parts {
+		joined = append(joined, part...)
+	}
+	return joined
+}
+
+func TestConversationPaths(t *testing.T) {
+	// The visitor asks and is answered
+	question := []string{
+		sound.HotWordDetectedEventName,
+		sound.SoundPlayedEventName,
+		sound.SoundCapturedEventName,
+	}
+	conversation := steps(question, []string{haspaws.AwsRepliedEventName, sound.SoundPlayedEventName})
+
+	tests := []struct {
+		name     string
+		maxTurns int
+		steps    []string
+		ended    string
+	}{
+		{
+			name:  "listens stopped",
+			steps: steps(conversation, []string{sound.StopEventName}),
+			ended: SessionEndIdle,
+		},
+		{
+			// The listens state stops after the third empty capture
+			name: "three empty captures",
+			steps: steps(conversation, []string{
+				sound.SoundEmptyEventName, sound.SoundPlayedEventName,
+				sound.SoundEmptyEventName, sound.SoundPlayedEventName,
+				sound.StopEventName,
+			}),
+			ended: SessionEndIdle,
+		},
+		{
+			name: "tells bye",
+			steps: steps(question, []string{
+				sound.StopEventName, sound.SoundPlayedEventName, events.StateGoIdleName,
+			}),
+			ended: SessionEndIdle,
+		},
+		{
+			name:  "inactivity timeout",
+			steps: steps(conversation, []string{inactivityTimeout, events.StateGoIdleName}),
+			ended: SessionEndTimeout,
+		},
+		{
+			name:     "max turns",
+			maxTurns: 2,
+			steps: steps(conversation, []string{
+				sound.SoundCapturedEventName, haspaws.AwsRepliedEventName,
+				sound.SoundPlayedEventName, events.StateGoIdleName,
+			}),
+			ended: SessionEndMaxTurns,
+		},
+		{
+			name: "message sent",
+			steps: []string{
+				sound.HotWordWithDataDetectedEventName,
+				haspaws.AwsRepliedCallEventName,
+				sound.SoundPlayedEventName,
+				sound.SoundPlayedEventName,
+			},
+			ended: SessionEndIdle,
+		},
+		{
+			name: "type stopped",
+			steps: steps(question, []string{
+				haspaws.AwsRepliedTypeEventName, sound.SoundPlayedEventName,
+				sound.SoundEmptyEventName, sound.SoundPlayedEventName,
+				sound.StopEventName,
+			}),
+			ended: SessionEndIdle,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ct := newConversationTest(t, SessionManagerParams{
+				InactivityTimeout: time.Minute,
+				MaxTurns:          test.maxTurns,
+			})
+			ct.run(test.steps...)
+
+			if state := ct.c.fsm.Current(); state != "idle" {
+				t.Fatalf("'%s' is entered, want 'idle'", state)
+			}
+			if len(ct.ended) != 1 || ct.ended[0] != test.ended {
+				t.Errorf("Sessions ended %v, want [%s]", ct.ended, test.ended)
+			}
+			for _, key := range []string{CtxUserId, CtxSession} {
+				if _, ok := ct.c.ctx[key]; ok {
+					t.Errorf("%s is kept in the context", key)
+				}
+			}
+			if !ct.timers[len(ct.timers)-1].stopped {
+				t.Errorf("The inactivity timer is not stopped")
+			}
+		})
+	}
+}
+
+func TestConversationNextVisitor(t *testing.T) {
+	ct := newConversationTest(t, SessionManagerParams{InactivityTimeout: time.Minute})
+	ct.run(sound.HotWordDetectedEventName, sound.SoundPlayedEventName, sound.SoundCapturedEventName)
+	first, _ := ct.c.ctx[CtxUserId].(string)
+	ct.run(haspaws.AwsRepliedEventName, sound.SoundPlayedEventName)
+	timeout := ct.timers[len(ct.timers)-1]
+	ct.run(sound.StopEventName)
+
+	ct.run(sound.HotWordDetectedEventName, sound.SoundPlayedEventName, sound.SoundCapturedEventName)
+	if userId, _ := ct.c.ctx[CtxUserId].(string); first == "" || userId == "" || userId == first {
+		t.Errorf("User id '%s' of the next visitor, the previous one is '%s'", userId, first)
+	}
+
+	// The timer of the previous session does not end the session
+	timeout.f()
+	ct.handleInjected()
+	if state := ct.c.fsm.Current(); state != "processing" {
+		t.Errorf("'%s' is entered on the timeout of the previous session", state)
+	}
+	if len(ct.ended) != 1 {
+		t.Errorf("Sessions ended %v, want one", ct.ended)
+	}
+}
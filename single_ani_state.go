@@ -10,15 +10,26 @@ import (
 
 type singleAniState struct {
 	Animation string
+	speech    Prompts
+	language  string
 }
 
 func NewSingleAniState(animation string) State {
+	return NewSingleAniStateWithPrompts(animation, nil)
+}
+
+// NewSingleAniStateWithPrompts creates new SingleAniState speaking the prompt
+// in the conversation language, e.g. the goodbye. The state is silent if
+// there is no prompt.
+func NewSingleAniStateWithPrompts(animation string, speech Prompts) State {
 	return &singleAniState{
 		Animation: animation,
+		speech:    speech,
 	}
 }
 
 func (s *singleAniState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
+	s.language = Language(ctx)
 	return events.EventSources{events.NewSingleEventSource(events.StateGoIdleName, func() *events.Event {
 		time.Sleep(2 * time.Second)
 		return &events.Event{Name: events.StateGoIdleName}
@@ -34,5 +45,5 @@ func (s *singleAniState) GetAnimation() string {
 }
 
 func (s *singleAniState) GetSound() *sound.AudioData {
-	return nil
+	return s.speech.Get(s.language)
 }
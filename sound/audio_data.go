@@ -1,8 +1,10 @@
 package sound
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
+	"math"
 )
 
 // SampleType is numerical representation of sample
@@ -73,6 +75,21 @@ func NewMonoS16LEFromInt16(sampleRate int, samples []int16) *AudioData {
 }
 */
 
+// Scaled returns a copy of the audio data with the amplitude multiplied by volume
+func (a *AudioData) Scaled(volume float64) *AudioData {
+	if a.format.SampleType != S16LE {
+		panic("Invalid SampleType")
+	}
+
+	samples := make([]byte, len(a.samples))
+	for i := 0; i+1 < len(a.samples); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(a.samples[i:]))) * volume
+		v = math.Max(math.MinInt16, math.Min(math.MaxInt16, v))
+		binary.LittleEndian.PutUint16(samples[i:], uint16(int16(v)))
+	}
+	return NewAudioData(a.format, samples)
+}
+
 // Samples gets samples
 func (a *AudioData) Samples() []byte {
 	return a.samples
@@ -50,9 +50,17 @@ out:
        return handle;
 }
 
-static pv_porcupine_object_t* createPorcupine(const char *modelPath, const char *keywordPath, float sensitivity, EStr* estr) {
+static pv_porcupine_object_t* createPorcupine(const char *modelPath,
+    const char **keywordPaths, int keywordCount, float sensitivity, EStr* estr) {
        pv_porcupine_object_t* porcupine = NULL;
-       pv_status_t status = pv_porcupine_init(modelPath, keywordPath, sensitivity, &porcupine);
+       float sensitivities[keywordCount];
+       int i;
+       for (i = 0; i < keywordCount; ++i) {
+           sensitivities[i] = sensitivity;
+       }
+
+       pv_status_t status = pv_porcupine_multiple_keywords_init(modelPath,
+           keywordCount, keywordPaths, sensitivities, &porcupine);
        if (status != PV_STATUS_SUCCESS) {
            eprintf("Failed to initialize Porcupine");
            return NULL;
@@ -79,7 +87,7 @@ static void destroyDetector(Detector* d) {
 
 static Detector* newDetector(
        const char *deviceName,
-       const char *modelPath, const char *keywordPath,
+       const char *modelPath, const char **keywordPaths, int keywordCount,
     float sensitivity,
     EStr* estr)
 {
@@ -94,9 +102,9 @@ static Detector* newDetector(
    if (d->capDev == NULL)
 	   goto error;
 
-	if (modelPath != NULL && keywordPath != NULL)
+	if (modelPath != NULL && keywordCount > 0)
 	{
-		d->porcupine = createPorcupine(modelPath, keywordPath, sensitivity, estr);
+		d->porcupine = createPorcupine(modelPath, keywordPaths, keywordCount, sensitivity, estr);
 		if (d->porcupine == NULL)
 			goto error;
 	}
@@ -111,9 +119,9 @@ error:
 static void resetPorcupine(Detector* d) {
     const int bufSize = pv_porcupine_frame_length();
     int16_t buf[bufSize];
-    bool detected = false;
+    int keyword = -1;
     memset(buf, 0, sizeof(int16_t)*bufSize);
-    pv_porcupine_process(d->porcupine, buf, &detected);
+    pv_porcupine_multiple_keywords_process(d->porcupine, buf, &keyword);
 }
 
 static bool startSession(Detector* d, int32_t* stopFlagPtr, EStr* estr) {
@@ -219,18 +227,19 @@ static short getMaxLoud(const int16_t* samples, int sampleCount) {
        return max;
 }
 
-static int waitHotWord(Detector* d, EStr* estr) {
+// waitHotWord waits for one of the keywords and stores its index
+static int waitHotWord(Detector* d, int* keyword, EStr* estr) {
        const int bufSize = pv_porcupine_frame_length();
        int16_t buf[bufSize];
 
-       bool detected = false;
+       *keyword = -1;
        while (notStopped(d)) {
            int n = readSamples(d, buf, bufSize, estr);
            if (n < 0)
                return n;
 
-           pv_porcupine_process(d->porcupine, buf, &detected);
-           if (detected) {
+           pv_porcupine_multiple_keywords_process(d->porcupine, buf, keyword);
+           if (*keyword >= 0) {
                return 0;
            }
        }
@@ -331,8 +340,8 @@ static int soundCapture(Detector* d, int16_t* outputBuffer, int maxSampleCount,
 	return -EINTR;
 }
 
-static int detect(Detector* d, int16_t* buffer, int maxSampleCount, EStr* estr, bool debug) {
-       int err = waitHotWord(d, estr);
+static int detect(Detector* d, int16_t* buffer, int maxSampleCount, int* keyword, EStr* estr, bool debug) {
+       int err = waitHotWord(d, keyword, estr);
        if (err)
            return err;
        return soundCapture(d, buffer, maxSampleCount, NOISE_FRAMES, estr, debug);
@@ -364,7 +373,10 @@ type HotWordDetectorParams struct {
 	CaptureDeviceName string
 	ModelPath         string
 	KeywordPath       string
-	DebugSound        bool
+	// KeywordPaths are optional. If set, any of the keywords is detected
+	// instead of KeywordPath, see HotWordDetectedEventData.Keyword.
+	KeywordPaths []string
+	DebugSound   bool
 }
 
 type hotWordDetectorMode int
@@ -401,10 +413,21 @@ func NewHotWordDetector(params HotWordDetectorParams) (*HotWordDetector, error)
 		debug:             params.DebugSound,
 	}
 
+	keywordPaths := params.KeywordPaths
+	if len(keywordPaths) == 0 {
+		keywordPaths = []string{params.KeywordPath}
+	}
+	cKeywordPaths := make([]*C.char, len(keywordPaths))
+	for i, keywordPath := range keywordPaths {
+		cKeywordPath := C.CString(keywordPath)
+		defer C.free(unsafe.Pointer(cKeywordPath))
+		cKeywordPaths[i] = cKeywordPath
+	}
+
 	estr := &C.EStr{}
 	d.detector = C.newDetector(
 		C.CString(params.CaptureDeviceName),
-		C.CString(params.ModelPath), C.CString(params.KeywordPath),
+		C.CString(params.ModelPath), &cKeywordPaths[0], C.int(len(cKeywordPaths)),
 		C.float(sensitivity),
 		estr,
 	)
@@ -524,14 +547,16 @@ func (d *HotWordDetector) handleError(session *hotWordDetectorSession, op string
 func (d *HotWordDetector) doDetectHotWord(session *hotWordDetectorSession) {
 	buf, cptr, maxSampleCount := d.makeSampleBuf()
 	estr := &C.EStr{}
+	var keyword C.int
 	d.emptySoundCounter = 0
-	sampleCount := C.detect(d.detector, cptr, C.int(maxSampleCount), estr, C.bool(d.debug))
+	sampleCount := C.detect(d.detector, cptr, C.int(maxSampleCount), &keyword, estr, C.bool(d.debug))
 	if sampleCount < 0 {
 		d.handleError(session, "HotWordDetect", estr)
 		return
 	}
 
-	session.eventChan <- NewHotWordDetectedEvent(d.makeAudioData(buf, sampleCount))
+	hotWordDetectionCounter.Inc()
+	session.eventChan <- NewHotWordDetectedEventWithKeyword(d.makeAudioData(buf, sampleCount), int(keyword))
 }
 
 func (d *HotWordDetector) doSoundCapture(session *hotWordDetectorSession) {
@@ -546,8 +571,11 @@ func (d *HotWordDetector) doSoundCapture(session *hotWordDetectorSession) {
 	samples := buf[0:sampleCount]
 	if len(samples) > 0 {
 		d.emptySoundCounter = 0
-		session.eventChan <- NewSoundCapturedEvent(d.makeAudioData(buf, sampleCount))
+		audioData := d.makeAudioData(buf, sampleCount)
+		captureDurationHistogram.Observe(audioData.Duration())
+		session.eventChan <- NewSoundCapturedEvent(audioData)
 	} else {
+		emptyCaptureCounter.Inc()
 		d.emptySoundCounter++
 		if d.emptySoundCounter > 2 {
 			session.eventChan <- NewStopEvent(nil)
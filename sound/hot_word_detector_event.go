@@ -11,6 +11,9 @@ import (
 // HotWordDetectedEventData is the HotWordDetectedEvent data
 type HotWordDetectedEventData struct {
 	AudioData *AudioData
+	// Keyword is the index of the detected keyword,
+	// see HotWordDetectorParams.KeywordPaths
+	Keyword int
 }
 
 const (
@@ -21,6 +24,12 @@ const (
 
 // NewHotWordDetectedEvent creates HotWordDetectedEvent
 func NewHotWordDetectedEvent(audioData *AudioData) *events.Event {
+	return NewHotWordDetectedEventWithKeyword(audioData, 0)
+}
+
+// NewHotWordDetectedEventWithKeyword creates HotWordDetectedEvent of the keyword.
+// The speech following the keyword is also SoundCapturedEventData.
+func NewHotWordDetectedEventWithKeyword(audioData *AudioData, keyword int) *events.Event {
 	typeName := HotWordWithDataDetectedEventName
 	if len(audioData.samples) == 0 {
 		logrus.Debug("HotWordDetected")
@@ -32,23 +41,24 @@ func NewHotWordDetectedEvent(audioData *AudioData) *events.Event {
 		Name: typeName,
 		Args: []interface{}{
 			SoundCapturedEventData{audioData},
+			HotWordDetectedEventData{audioData, keyword},
 		},
 	}
 }
 
 // GetHotWordDetectedEventData gets HotWordDetectedEvent data
 func GetHotWordDetectedEventData(event *events.Event) (HotWordDetectedEventData, error) {
-	if event.Name != HotWordDetectedEventName {
+	if event.Name != HotWordDetectedEventName && event.Name != HotWordWithDataDetectedEventName {
 		return HotWordDetectedEventData{},
-			fmt.Errorf("The event must be named %s", HotWordDetectedEventName)
+			fmt.Errorf("The event must be named %s or %s", HotWordDetectedEventName, HotWordWithDataDetectedEventName)
 	}
 
-	if len(event.Args) != 1 {
+	if len(event.Args) != 2 {
 		return HotWordDetectedEventData{},
 			errors.New("Event does not data")
 	}
 
-	data, ok := event.Args[0].(HotWordDetectedEventData)
+	data, ok := event.Args[1].(HotWordDetectedEventData)
 	if !ok {
 		return HotWordDetectedEventData{},
 			errors.New("Event does not contain samples")
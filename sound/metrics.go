@@ -0,0 +1,22 @@
+package sound
+
+import "github.com/rmcsoft/hasp/metrics"
+
+var (
+	hotWordDetectionCounter = metrics.NewCounter("hasp_hotword_detections_total",
+		"Number of detected hot words")
+	captureDurationHistogram = metrics.NewHistogram("hasp_sound_capture_duration_seconds",
+		"Duration of the captured sound", metrics.DefaultDurationBuckets)
+	emptyCaptureCounter = metrics.NewCounter("hasp_sound_empty_captures_total",
+		"Number of sound captures that contain no speech")
+	playbackUnderrunCounter = metrics.NewCounter("hasp_playback_underruns_total",
+		"Number of playback buffer underruns")
+)
+
+// Duration returns the duration of the audio data in seconds
+func (a *AudioData) Duration() float64 {
+	if a.format.SampleRate == 0 || a.format.ChannelCount == 0 {
+		return 0
+	}
+	return float64(a.SampleCount()) / float64(a.format.ChannelCount*a.format.SampleRate)
+}
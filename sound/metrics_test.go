@@ -0,0 +1,54 @@
+package sound
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rmcsoft/hasp/metrics"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		name      string
+		audioData *AudioData
+		duration  float64
+	}{
+		{"mono", NewMonoS16LE(16000, make([]byte, 16000)), 0.5},
+		{"stereo", NewAudioData(AudioFormat{ChannelCount: 2, SampleType: S16LE, SampleRate: 8000},
+			make([]byte, 32000)), 1},
+		{"empty", NewMonoS16LE(16000, nil), 0},
+		{"no sample rate", NewMonoS16LE(0, make([]byte, 100)), 0},
+	}
+	for _, test := range tests {
+		if duration := test.audioData.Duration(); duration != test.duration {
+			t.Errorf("%s: %v seconds, want %v", test.name, duration, test.duration)
+		}
+	}
+}
+
+func TestSoundMetrics(t *testing.T) {
+	hotWordDetectionCounter.Inc()
+	emptyCaptureCounter.Inc()
+	emptyCaptureCounter.Inc()
+	captureDurationHistogram.Observe(NewMonoS16LE(16000, make([]byte, 64000)).Duration())
+
+	var buf bytes.Buffer
+	if _, err := metrics.DefaultRegistry.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE hasp_hotword_detections_total counter\nhasp_hotword_detections_total 1\n",
+		"# TYPE hasp_sound_empty_captures_total counter\nhasp_sound_empty_captures_total 2\n",
+		"# TYPE hasp_playback_underruns_total counter\nhasp_playback_underruns_total 0\n",
+		"hasp_sound_capture_duration_seconds_bucket{le=\"1\"} 0\n",
+		"hasp_sound_capture_duration_seconds_bucket{le=\"2\"} 1\n",
+		"hasp_sound_capture_duration_seconds_bucket{le=\"+Inf\"} 1\n",
+		"hasp_sound_capture_duration_seconds_sum 2\n",
+		"hasp_sound_capture_duration_seconds_count 1\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("No %q in:\n%s", want, buf.String())
+		}
+	}
+}
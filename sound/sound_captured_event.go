@@ -32,7 +32,7 @@ func GetSoundCapturedEventData(event *events.Event) (SoundCapturedEventData, err
 			fmt.Errorf("The event must be named %s or %s", SoundCapturedEventName, HotWordWithDataDetectedEventName)
 	}
 
-	if len(event.Args) != 1 {
+	if len(event.Args) < 1 {
 		return SoundCapturedEventData{},
 			errors.New("Event does not data")
 	}
@@ -3,6 +3,7 @@ package sound
 /*
 #cgo pkg-config: alsa
 
+#include <errno.h>
 #include <stdbool.h>
 #include <stdint.h>
 #include <alsa/asoundlib.h>
@@ -78,19 +79,19 @@ out:
 	return handle;
 }
 
-static bool playback(snd_pcm_t* handle, const int16_t* buf, int bufSize, EStr* estr) {
+static int playback(snd_pcm_t* handle, const int16_t* buf, int bufSize, EStr* estr) {
 	int err = 0;
 
     if ((err = snd_pcm_writei(handle, buf, bufSize)) != bufSize)
     {
         eprintf("write to audio interface failed (%s)\n", snd_strerror (err));
-        return false;
+        return err < 0 ? err : -EIO;
 	}
 
 	snd_pcm_nonblock(handle, 0);
     snd_pcm_drain(handle);
 	snd_pcm_nonblock(handle, 1);
-	return true;
+	return 0;
 }
 */
 import "C"
@@ -98,7 +99,9 @@ import "C"
 import (
 	"errors"
 	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/rmcsoft/hasp/events"
@@ -115,18 +118,39 @@ type SoundPlayer struct {
 	devClosedCond *sync.Cond
 	devMutex      *sync.Mutex
 	dev           *C.snd_pcm_t
+
+	volumeBits uint64
 }
 
 // NewSoundPlayer creates new SoundPlayer
 func NewSoundPlayer(devName string) (*SoundPlayer, error) {
 	sp := &SoundPlayer{
-		devName:  devName,
-		devMutex: &sync.Mutex{},
+		devName:    devName,
+		devMutex:   &sync.Mutex{},
+		volumeBits: math.Float64bits(1),
 	}
 	sp.devClosedCond = sync.NewCond(sp.devMutex)
 	return sp, nil
 }
 
+// SetVolume sets the playback volume (0..1) for the sounds played afterwards
+func (p *SoundPlayer) SetVolume(volume float64) {
+	volume = math.Max(0, math.Min(1, volume))
+	atomic.StoreUint64(&p.volumeBits, math.Float64bits(volume))
+}
+
+// Volume gets the playback volume
+func (p *SoundPlayer) Volume() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&p.volumeBits))
+}
+
+func (p *SoundPlayer) applyVolume(audioData *AudioData) *AudioData {
+	if volume := p.Volume(); volume != 1 {
+		return audioData.Scaled(volume)
+	}
+	return audioData
+}
+
 // Play starts playing back buffer
 func (p *SoundPlayer) Play(audioData *AudioData) (events.EventSource, error) {
 	p.devMutex.Lock()
@@ -137,6 +161,7 @@ func (p *SoundPlayer) Play(audioData *AudioData) (events.EventSource, error) {
 	if audioData.SampleType() != S16LE || audioData.ChannelCount() != 1 {
 		return nil, errors.New("Unsupported audio format")
 	}
+	audioData = p.applyVolume(audioData)
 
 	estr := &C.EStr{}
 	p.dev = C.openDevice(C.CString(p.devName), C.uint(audioData.SampleRate()), estr)
@@ -157,8 +182,9 @@ func (p *SoundPlayer) Play(audioData *AudioData) (events.EventSource, error) {
 
 		estr := &C.EStr{}
 		cptr := (*C.int16_t)(unsafe.Pointer(&samples[0]))
-		if !C.playback(p.dev, cptr, C.int(sampleCount), estr) {
+		if rc := C.playback(p.dev, cptr, C.int(sampleCount), estr); rc != 0 {
 			// TODO:  Reaction to an error
+			countUnderrun(rc)
 			err := fmt.Errorf("playback failed: %v", estr)
 			log.Errorf("SoundPlayer: %v", err)
 		}
@@ -185,6 +211,7 @@ func (p *SoundPlayer) PlaySync(audioData *AudioData) {
 	if audioData.SampleType() != S16LE || audioData.ChannelCount() != 1 {
 		return
 	}
+	audioData = p.applyVolume(audioData)
 
 	estr := &C.EStr{}
 	p.dev = C.openDevice(C.CString(p.devName), C.uint(audioData.SampleRate()), estr)
@@ -202,7 +229,8 @@ func (p *SoundPlayer) PlaySync(audioData *AudioData) {
 	samples := audioData.Samples()
 
 	cptr := (*C.int16_t)(unsafe.Pointer(&samples[0]))
-	if !C.playback(p.dev, cptr, C.int(sampleCount), estr) {
+	if rc := C.playback(p.dev, cptr, C.int(sampleCount), estr); rc != 0 {
+		countUnderrun(rc)
 		err := fmt.Errorf("playback failed: %v", estr)
 		log.Errorf("SoundPlayer: %v", err)
 	}
@@ -231,6 +259,13 @@ func (p *SoundPlayer) stop(useLock bool) {
 	}
 }
 
+// countUnderrun counts playback errors caused by buffer underrun (EPIPE)
+func countUnderrun(rc C.int) {
+	if rc == -C.EPIPE {
+		playbackUnderrunCounter.Inc()
+	}
+}
+
 func (p *SoundPlayer) closeDev(useLock bool) {
 	if useLock {
 		p.devMutex.Lock()
@@ -9,6 +9,10 @@ import (
 
 type StopEventData struct {
 	StopSpeach *AudioData
+	// InputTranscript is the recognized user speech, if any
+	InputTranscript string
+	// Message is the text of StopSpeach, if any
+	Message string
 }
 
 const (
@@ -19,7 +23,17 @@ func NewStopEvent(stopSpeach *AudioData) *events.Event {
 	return &events.Event{
 		Name: StopEventName,
 		Args: []interface{}{
-			StopEventData{stopSpeach},
+			StopEventData{StopSpeach: stopSpeach},
+		},
+	}
+}
+
+// NewStopEventWithText creates StopEvent with the texts of the speeches
+func NewStopEventWithText(stopSpeach *AudioData, inputTranscript string, message string) *events.Event {
+	return &events.Event{
+		Name: StopEventName,
+		Args: []interface{}{
+			StopEventData{stopSpeach, inputTranscript, message},
 		},
 	}
 }
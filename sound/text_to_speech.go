@@ -0,0 +1,135 @@
+package sound
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTextToSpeechTimeout is the time limit of the text-to-speech command
+const DefaultTextToSpeechTimeout = 10 * time.Second
+
+// TextToSpeech synthesizes the speech of the text
+type TextToSpeech interface {
+	Synthesize(text string) (*AudioData, error)
+}
+
+// CommandTextToSpeech runs a local engine reading the text from stdin and
+// writing the speech to stdout as WAV or as raw S16LE samples, e.g.
+//
+//	espeak-ng --stdin --stdout
+//	piper --model en_US-amy-medium.onnx --output-raw
+type CommandTextToSpeech struct {
+	command string
+	args    []string
+	// rawFormat is the format of the raw output, WAV carries its format
+	rawFormat AudioFormat
+
+	// Timeout is the time limit of the engine, the engine is killed when
+	// it is exceeded
+	Timeout time.Duration
+}
+
+// NewCommandTextToSpeech creates new CommandTextToSpeech
+func NewCommandTextToSpeech(rawFormat AudioFormat, command string, args ...string) *CommandTextToSpeech {
+	return &CommandTextToSpeech{
+		command:   command,
+		args:      args,
+		rawFormat: rawFormat,
+		Timeout:   DefaultTextToSpeechTimeout,
+	}
+}
+
+// Synthesize implements TextToSpeech, it runs the engine for the text
+func (t *CommandTextToSpeech) Synthesize(text string) (*AudioData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, t.command, t.args...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("Text-to-speech command '%s' timed out after %v", t.command, t.Timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Text-to-speech command '%s' failed: %v: %s",
+			t.command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if bytes.HasPrefix(output, []byte("RIFF")) {
+		return parseWAV(output)
+	}
+	return NewAudioData(t.rawFormat, output), nil
+}
+
+// parseWAV parses 16-bit PCM WAV, the channels are mixed down to mono.
+// The size of the data chunk may be unknown if the engine writes to a pipe,
+// so the data lasts to the end.
+func parseWAV(data []byte) (*AudioData, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("Invalid WAV header")
+	}
+
+	var channels, sampleRate, bits int
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		body := data[pos+8:]
+		if size >= 0 && size < len(body) {
+			body = body[:size]
+		}
+
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, errors.New("Invalid WAV format chunk")
+			}
+			if binary.LittleEndian.Uint16(body[0:]) != 1 {
+				return nil, errors.New("Unsupported WAV encoding, PCM is expected")
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits = int(binary.LittleEndian.Uint16(body[14:]))
+		case "data":
+			if bits != 16 || channels == 0 {
+				return nil, fmt.Errorf("Unsupported WAV format: %d bits, %d channels", bits, channels)
+			}
+			return NewMonoS16LE(sampleRate, mixDown(body, channels)), nil
+		}
+
+		// The chunks are word aligned
+		pos += 8 + size + size%2
+		if size < 0 || pos < 0 {
+			break
+		}
+	}
+	return nil, errors.New("WAV has no data")
+}
+
+// mixDown mixes the interleaved S16LE channels down to mono
+func mixDown(samples []byte, channels int) []byte {
+	if channels == 1 {
+		return samples
+	}
+
+	frameSize := 2 * channels
+	mono := make([]byte, 0, len(samples)/channels)
+	for pos := 0; pos+frameSize <= len(samples); pos += frameSize {
+		sum := 0
+		for ch := 0; ch < channels; ch++ {
+			sum += int(int16(binary.LittleEndian.Uint16(samples[pos+2*ch:])))
+		}
+		var sample [2]byte
+		binary.LittleEndian.PutUint16(sample[:], uint16(int16(sum/channels)))
+		mono = append(mono, sample[:]...)
+	}
+	return mono
+}
@@ -0,0 +1,141 @@
+package sound
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// s16le returns the S16LE samples
+func s16le(samples ...int16) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+// wav returns a PCM WAV with the chunks, the RIFF size is not checked
+func wav(chunks ...[]byte) []byte {
+	data := []byte("RIFF\xff\xff\xff\xffWAVE")
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+// chunk returns the chunk with the size, the body is padded if it is odd
+func chunk(id string, size uint32, body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(id)
+	binary.Write(&buf, binary.LittleEndian, size)
+	buf.Write(body)
+	if len(body)%2 != 0 {
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+// fmtChunk returns the format chunk of the PCM samples
+func fmtChunk(encoding uint16, channels uint16, sampleRate uint32, bits uint16) []byte {
+	var body bytes.Buffer
+	blockAlign := channels * bits / 8
+	for _, v := range []interface{}{encoding, channels, sampleRate, sampleRate * uint32(blockAlign), blockAlign, bits} {
+		binary.Write(&body, binary.LittleEndian, v)
+	}
+	return chunk("fmt ", uint32(body.Len()), body.Bytes())
+}
+
+func TestParseWAV(t *testing.T) {
+	samples := s16le(1, -2, 300, -400)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		sampleRate int
+		samples    []byte
+		valid      bool
+	}{
+		{
+			name:       "mono",
+			data:       wav(fmtChunk(1, 1, 16000, 16), chunk("data", 8, samples)),
+			sampleRate: 16000,
+			samples:    samples,
+			valid:      true,
+		},
+		{
+			name:       "stereo",
+			data:       wav(fmtChunk(1, 2, 22050, 16), chunk("data", 8, s16le(100, 200, -100, -300))),
+			sampleRate: 22050,
+			samples:    s16le(150, -200),
+			valid:      true,
+		},
+		{
+			name:       "chunk before format",
+			data:       wav(chunk("LIST", 3, []byte("abc")), fmtChunk(1, 1, 8000, 16), chunk("data", 8, samples)),
+			sampleRate: 8000,
+			samples:    samples,
+			valid:      true,
+		},
+		{
+			name:       "unknown data size of a pipe",
+			data:       wav(fmtChunk(1, 1, 16000, 16), chunk("data", 0xffffffff, samples)),
+			sampleRate: 16000,
+			samples:    samples,
+			valid:      true,
+		},
+		{
+			name:       "data shorter than its size",
+			data:       wav(fmtChunk(1, 1, 16000, 16), chunk("data", 100, samples)),
+			sampleRate: 16000,
+			samples:    samples,
+			valid:      true,
+		},
+		{name: "not RIFF", data: []byte("RIFX\x00\x00\x00\x00WAVE")},
+		{name: "short", data: []byte("RIFF")},
+		{name: "float", data: wav(fmtChunk(3, 1, 16000, 32), chunk("data", 8, samples))},
+		{name: "8 bits", data: wav(fmtChunk(1, 1, 16000, 8), chunk("data", 8, samples))},
+		{name: "data before format", data: wav(chunk("data", 8, samples), fmtChunk(1, 1, 16000, 16))},
+		{name: "short format", data: wav(chunk("fmt ", 4, []byte{1, 0, 1, 0}))},
+		{name: "no data", data: wav(fmtChunk(1, 1, 16000, 16))},
+	}
+	for _, test := range tests {
+		audioData, err := parseWAV(test.data)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if audioData.ChannelCount() != 1 || audioData.SampleRate() != test.sampleRate {
+			t.Errorf("%s: %d channels at %d Hz, want mono at %d Hz",
+				test.name, audioData.ChannelCount(), audioData.SampleRate(), test.sampleRate)
+		}
+		if !bytes.Equal(audioData.Samples(), test.samples) {
+			t.Errorf("%s: samples %v, want %v", test.name, audioData.Samples(), test.samples)
+		}
+	}
+}
+
+func TestMixDown(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []byte
+		channels int
+		mono     []byte
+	}{
+		{"mono", s16le(1, 2, 3), 1, s16le(1, 2, 3)},
+		{"stereo", s16le(10, 20, -10, -30), 2, s16le(15, -20)},
+		{"three channels", s16le(3, 6, 9, 0, 0, -3), 3, s16le(6, -1)},
+		{"full scale", s16le(32767, 32767, -32768, -32768), 2, s16le(32767, -32768)},
+		{"partial frame", s16le(10, 20, 30), 2, s16le(15)},
+		{"empty", nil, 2, []byte{}},
+	}
+	for _, test := range tests {
+		if mono := mixDown(test.samples, test.channels); !bytes.Equal(mono, test.mono) {
+			t.Errorf("%s: %v, want %v", test.name, mono, test.mono)
+		}
+	}
+}
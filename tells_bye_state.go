@@ -10,6 +10,8 @@ import (
 type tellsByeState struct {
 	availableAnimations []string
 	currentAnimation    int
+	heard               string
+	said                string
 	byeSpeech           *sound.AudioData
 }
 
@@ -21,13 +23,16 @@ func NewTellsByeState(availableAnimations []string) State {
 
 func (s *tellsByeState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
 	s.byeSpeech = nil
+	s.heard, s.said = "", ""
 	if len(event.Args) > 0 {
 		if event.Name == sound.StopEventName {
 			data, _ := sound.GetStopEventData(&event)
 			s.byeSpeech = data.StopSpeach
+			s.heard, s.said = data.InputTranscript, data.Message
 		} else if event.Name == haspaws.AwsRepliedCallEventName {
 			data, _ := haspaws.GetAwsRepliedEventData(&event)
 			s.byeSpeech = data.RepliedSpeech
+			s.heard, s.said = data.InputTranscript, data.Message
 		}
 	}
 
@@ -39,8 +44,8 @@ func (s *tellsByeState) Enter(ctx CharacterCtx, event events.Event) (events.Even
 	return nil, nil
 }
 
+// Leave keeps the session, SessionManager removes it on the return to idle
 func (s *tellsByeState) Leave(ctx CharacterCtx, event events.Event) bool {
-	delete(ctx, CtxUserId)
 	return true
 }
 
@@ -52,3 +57,7 @@ func (s *tellsByeState) GetAnimation() string {
 func (s *tellsByeState) GetSound() *sound.AudioData {
 	return s.byeSpeech
 }
+
+func (s *tellsByeState) GetCaptions() (string, string) {
+	return s.heard, s.said
+}
@@ -8,11 +8,18 @@ import (
 type tellsHelpState struct {
 	availableAnimations []string
 	currentAnimation    int
-	welcomeSpeech       *sound.AudioData
+	welcomeSpeech       Prompts
+	language            string
 }
 
 // NewTellsHelpState creates new IdleState
 func NewTellsHelpState(availableAnimations []string, welcomeSpeech *sound.AudioData) State {
+	return NewTellsHelpStateWithPrompts(availableAnimations, Prompts{"": welcomeSpeech})
+}
+
+// NewTellsHelpStateWithPrompts creates new TellsHelpState speaking the prompt
+// in the conversation language
+func NewTellsHelpStateWithPrompts(availableAnimations []string, welcomeSpeech Prompts) State {
 	return &tellsHelpState{
 		availableAnimations: availableAnimations,
 		welcomeSpeech:       welcomeSpeech,
@@ -20,7 +27,7 @@ func NewTellsHelpState(availableAnimations []string, welcomeSpeech *sound.AudioD
 }
 
 func (s *tellsHelpState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
-
+	s.language = Language(ctx)
 	return nil, nil
 }
 
@@ -34,5 +41,5 @@ func (s *tellsHelpState) GetAnimation() string {
 }
 
 func (s *tellsHelpState) GetSound() *sound.AudioData {
-	return s.welcomeSpeech
+	return s.welcomeSpeech.Get(s.language)
 }
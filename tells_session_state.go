@@ -0,0 +1,89 @@
+package hasp
+
+import (
+	"strings"
+	"text/template"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/sound"
+)
+
+type tellsSessionState struct {
+	availableAnimations []string
+	currentAnimation    int
+	prompts             Prompts
+	text                *template.Template
+	tts                 sound.TextToSpeech
+
+	speech *sound.AudioData
+	said   string
+}
+
+// TellsSessionStateParams are the parameters of TellsSessionState
+type TellsSessionStateParams struct {
+	AvailableAnimations []string
+	// Prompts are spoken if there is no text
+	Prompts Prompts
+	// Text is optional. It is executed with the conversation session,
+	// e.g. to say who was notified, and the result is spoken by the
+	// text-to-speech instead of the prompt if it is not empty.
+	Text         *template.Template
+	TextToSpeech sound.TextToSpeech
+}
+
+// NewTellsSessionState creates new TellsSessionState
+func NewTellsSessionState(params TellsSessionStateParams) State {
+	return &tellsSessionState{
+		availableAnimations: params.AvailableAnimations,
+		prompts:             params.Prompts,
+		text:                params.Text,
+		tts:                 params.TextToSpeech,
+	}
+}
+
+func (s *tellsSessionState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
+	s.speech = s.prompts.Get(Language(ctx))
+	s.said = ""
+
+	session := ConversationSession(ctx)
+	if s.text == nil || s.tts == nil || session == nil {
+		return nil, nil
+	}
+
+	var text strings.Builder
+	if err := s.text.Execute(&text, session); err != nil {
+		log.Errorf("Failed to make the text of the session: %v", err)
+		return nil, nil
+	}
+	said := strings.TrimSpace(text.String())
+	if said == "" {
+		return nil, nil
+	}
+
+	speech, err := s.tts.Synthesize(said)
+	if err != nil {
+		log.Errorf("Failed to synthesize the text of the session: %v", err)
+		return nil, nil
+	}
+	s.speech, s.said = speech, said
+	return nil, nil
+}
+
+func (s *tellsSessionState) Leave(ctx CharacterCtx, event events.Event) bool {
+	return true
+}
+
+func (s *tellsSessionState) GetAnimation() string {
+	animation := s.availableAnimations[s.currentAnimation]
+	return animation
+}
+
+func (s *tellsSessionState) GetSound() *sound.AudioData {
+	return s.speech
+}
+
+func (s *tellsSessionState) GetCaptions() (string, string) {
+	return "", s.said
+}
@@ -10,6 +10,8 @@ import (
 type tellsState struct {
 	availableAnimations []string
 	currentAnimation    int
+	heard               string
+	said                string
 	speech              *sound.AudioData
 }
 
@@ -23,6 +25,7 @@ func NewTellsState(availableAnimations []string) State {
 func (s *tellsState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
 	data, _ := haspaws.GetAwsRepliedEventData(&event)
 	s.speech = data.RepliedSpeech
+	s.heard, s.said = data.InputTranscript, data.Message
 	if s.speech == nil || len(s.speech.Samples()) == 0 {
 		return events.EventSources{events.NewSingleEventSource(sound.SoundPlayedEventName, func() *events.Event {
 			return &events.Event{Name: sound.SoundPlayedEventName} }) }, nil
@@ -42,3 +45,7 @@ func (s *tellsState) GetAnimation() string {
 func (s *tellsState) GetSound() *sound.AudioData {
 	return s.speech
 }
+
+func (s *tellsState) GetCaptions() (string, string) {
+	return s.heard, s.said
+}
@@ -0,0 +1,135 @@
+package touch
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+)
+
+// Calibration maps the touch panel coordinates to the display
+type Calibration struct {
+	// The range of the panel coordinates, read from the device if the
+	// maximums are 0
+	MinX int `json:"minX,omitempty"`
+	MaxX int `json:"maxX,omitempty"`
+	MinY int `json:"minY,omitempty"`
+	MaxY int `json:"maxY,omitempty"`
+
+	// Width and Height are the display size the regions are defined in
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// The transformations of the panel coordinates applied before scaling,
+	// e.g. for the panel mounted rotated
+	SwapXY  bool `json:"swapXY,omitempty"`
+	InvertX bool `json:"invertX,omitempty"`
+	InvertY bool `json:"invertY,omitempty"`
+}
+
+// Region is an area of the display reacting to the taps, e.g. a button
+// drawn by the animation or an overlay
+type Region struct {
+	Name   string `json:"name"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+
+	// Event is the name of the event fired by the tap
+	Event string `json:"event,omitempty"`
+	// Text is the utterance sent to the conversation backend by the tap,
+	// see events.TextInputEvent
+	Text string `json:"text,omitempty"`
+	// Language is the conversation language selected by the tap,
+	// see events.LanguageSelectedEvent
+	Language string `json:"language,omitempty"`
+}
+
+// Rect returns the area of the region
+func (r Region) Rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+// Config is the touch regions keyed by state. For example:
+//
+//	{
+//	  "calibration": {"width": 600, "height": 1024},
+//	  "states": {
+//	    "idle": [
+//	      {"name": "Español", "x": 400, "y": 0, "width": 200, "height": 100, "language": "es"},
+//	      {"name": "Tap to talk", "x": 0, "y": 0, "width": 600, "height": 1024, "event": "HotWordDetected"}
+//	    ],
+//	    "type": [
+//	      {"name": "Yes", "x": 40,  "y": 860, "width": 240, "height": 120, "text": "yes"},
+//	      {"name": "No",  "x": 320, "y": 860, "width": 240, "height": 120, "text": "no"}
+//	    ]
+//	  }
+//	}
+//
+// The first region containing the tap is used.
+type Config struct {
+	Calibration Calibration         `json:"calibration"`
+	States      map[string][]Region `json:"states"`
+}
+
+// LoadConfig loads Config from the JSON file
+func LoadConfig(fileName string) (*Config, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse touch config '%s': %v", fileName, err)
+	}
+	return config, nil
+}
+
+// ParseConfig parses Config from JSON
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks the regions are not empty and do one thing
+func (c *Config) Validate() error {
+	if c.Calibration.Width <= 0 || c.Calibration.Height <= 0 {
+		return fmt.Errorf("Calibration: the display size is not set")
+	}
+
+	for state, regions := range c.States {
+		for _, region := range regions {
+			if region.Width <= 0 || region.Height <= 0 {
+				return fmt.Errorf("State '%s': region '%s' is empty", state, region.Name)
+			}
+			actions := 0
+			for _, action := range []string{region.Event, region.Text, region.Language} {
+				if action != "" {
+					actions++
+				}
+			}
+			if actions != 1 {
+				return fmt.Errorf("State '%s': region '%s' must have one of event, text or language", state, region.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// regionAt returns the first region of the state containing the point
+func (c *Config) regionAt(state string, point image.Point) (Region, bool) {
+	for _, region := range c.States[state] {
+		if point.In(region.Rect()) {
+			return region, true
+		}
+	}
+	return Region{}, false
+}
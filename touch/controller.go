@@ -0,0 +1,92 @@
+package touch
+
+import (
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rmcsoft/hasp/evdev"
+	"github.com/rmcsoft/hasp/events"
+)
+
+// Target is the character receiving the events of the taps
+type Target interface {
+	CurrentState() string
+	InjectEvent(event *events.Event) error
+}
+
+// Controller fires the events of the regions tapped in the current state
+type Controller struct {
+	config *Config
+	target Target
+}
+
+// NewController creates new Controller
+func NewController(config *Config, target Target) *Controller {
+	return &Controller{
+		config: config,
+		target: target,
+	}
+}
+
+// OpenDevice opens the touch panel device and completes the calibration with the
+// ranges of its axes
+func (c *Controller) OpenDevice(path string) (*os.File, error) {
+	device, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	calibration := &c.config.Calibration
+	if calibration.MaxX == 0 {
+		if calibration.MinX, calibration.MaxX, err = evdev.ReadAbsRange(device, evdev.AbsX); err != nil {
+			log.Warnf("Touch: %v, the panel coordinates are used as is", err)
+		}
+	}
+	if calibration.MaxY == 0 {
+		if calibration.MinY, calibration.MaxY, err = evdev.ReadAbsRange(device, evdev.AbsY); err != nil {
+			log.Warnf("Touch: %v, the panel coordinates are used as is", err)
+		}
+	}
+	return device, nil
+}
+
+// Run handles the input events until the reader fails, e.g. the end of the
+// recorded stream
+func (c *Controller) Run(reader *evdev.EventReader) error {
+	tracker := newTracker(c.config.Calibration)
+	for {
+		event, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if tap, ok := tracker.handle(event); ok {
+			c.HandleTap(tap)
+		}
+	}
+}
+
+// HandleTap fires the event of the region under the tap in the current state
+func (c *Controller) HandleTap(tap Tap) {
+	state := c.target.CurrentState()
+	region, ok := c.config.regionAt(state, tap.Point)
+	if !ok {
+		log.Debugf("Touch: tap at %v in state '%s' hits no region", tap.Point, state)
+		return
+	}
+
+	event := &events.Event{Name: region.Event}
+	if region.Text != "" {
+		event = events.NewTextInputEvent(region.Text, "touch")
+	} else if region.Language != "" {
+		event = events.NewLanguageSelectedEvent(region.Language)
+	}
+	log.Infof("Touch: region '%s' is tapped in state '%s'", region.Name, state)
+	if err := c.target.InjectEvent(event); err != nil {
+		log.Errorf("Touch: failed to fire %s: %v", event.Name, err)
+	}
+}
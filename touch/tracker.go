@@ -0,0 +1,106 @@
+package touch
+
+import (
+	"image"
+	"time"
+
+	"github.com/rmcsoft/hasp/evdev"
+)
+
+// Tap is a touch released, in the display coordinates
+type Tap struct {
+	Point image.Point
+	Time  time.Time
+}
+
+// tracker turns the input events into the taps. It follows the first
+// contact of the single-touch and multi-touch panels, the contacts made
+// while it is touching are ignored.
+type tracker struct {
+	calibration Calibration
+
+	raw      image.Point
+	touching bool
+	// The multi-touch slot the events are for and the slot of the followed
+	// contact, -1 if there is none
+	slot     int32
+	followed int32
+	// The state at the last SYN_REPORT
+	reportedTouching bool
+	pressed          image.Point
+}
+
+func newTracker(calibration Calibration) *tracker {
+	return &tracker{calibration: calibration, followed: -1}
+}
+
+// handle updates the touch state, it returns the tap when the touch is
+// released
+func (t *tracker) handle(event evdev.InputEvent) (Tap, bool) {
+	switch event.Type {
+	case evdev.EvAbs:
+		switch event.Code {
+		case evdev.AbsX:
+			t.raw.X = int(event.Value)
+		case evdev.AbsY:
+			t.raw.Y = int(event.Value)
+		case evdev.AbsMtPositionX:
+			if t.slot == t.followed {
+				t.raw.X = int(event.Value)
+			}
+		case evdev.AbsMtPositionY:
+			if t.slot == t.followed {
+				t.raw.Y = int(event.Value)
+			}
+		case evdev.AbsMtSlot:
+			t.slot = event.Value
+		case evdev.AbsMtTrackingID:
+			if event.Value >= 0 && t.followed < 0 {
+				t.followed = t.slot
+				t.touching = true
+			} else if event.Value < 0 && t.slot == t.followed {
+				t.followed = -1
+				t.touching = false
+			}
+		}
+	case evdev.EvKey:
+		if event.Code == evdev.BtnTouch {
+			t.touching = event.Value != 0
+		}
+	case evdev.EvSyn:
+		if event.Code != evdev.SynReport {
+			break
+		}
+		pressed := t.touching && !t.reportedTouching
+		released := !t.touching && t.reportedTouching
+		t.reportedTouching = t.touching
+		if pressed {
+			t.pressed = t.raw
+		}
+		if released {
+			return Tap{Point: t.calibration.toDisplay(t.pressed), Time: event.Time}, true
+		}
+	}
+	return Tap{}, false
+}
+
+// toDisplay converts the panel coordinates to the display coordinates
+func (c Calibration) toDisplay(raw image.Point) image.Point {
+	x, y := raw.X, raw.Y
+	minX, maxX, minY, maxY := c.MinX, c.MaxX, c.MinY, c.MaxY
+	if c.SwapXY {
+		x, y = y, x
+		minX, maxX, minY, maxY = minY, maxY, minX, maxX
+	}
+	return image.Pt(scale(x, minX, maxX, c.Width, c.InvertX), scale(y, minY, maxY, c.Height, c.InvertY))
+}
+
+func scale(v int, min int, max int, size int, invert bool) int {
+	if max <= min {
+		return v
+	}
+	if invert {
+		v = max - (v - min)
+	}
+	return (v - min) * (size - 1) / (max - min)
+}
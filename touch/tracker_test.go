@@ -0,0 +1,161 @@
+package touch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"testing"
+
+	"github.com/rmcsoft/hasp/evdev"
+)
+
+// ev is an input event of the recorded stream
+type ev struct {
+	typ   uint16
+	code  uint16
+	value int32
+}
+
+var syn = ev{evdev.EvSyn, evdev.SynReport, 0}
+
+func slot(n int32) ev        { return ev{evdev.EvAbs, evdev.AbsMtSlot, n} }
+func trackingID(id int32) ev { return ev{evdev.EvAbs, evdev.AbsMtTrackingID, id} }
+func touch(on int32) ev      { return ev{evdev.EvKey, evdev.BtnTouch, on} }
+
+func mtPos(x int32, y int32) []ev {
+	return []ev{{evdev.EvAbs, evdev.AbsMtPositionX, x}, {evdev.EvAbs, evdev.AbsMtPositionY, y}}
+}
+
+func pos(x int32, y int32) []ev {
+	return []ev{{evdev.EvAbs, evdev.AbsX, x}, {evdev.EvAbs, evdev.AbsY, y}}
+}
+
+// record returns the stream of the event groups as read from a 64-bit
+// machine, the events of the n-th group are stamped n seconds
+func record(groups ...[]ev) []byte {
+	var stream bytes.Buffer
+	for sec, group := range groups {
+		for _, e := range group {
+			binary.Write(&stream, binary.LittleEndian, []int64{int64(sec), 0})
+			binary.Write(&stream, binary.LittleEndian, []uint16{e.typ, e.code})
+			binary.Write(&stream, binary.LittleEndian, e.value)
+		}
+	}
+	return stream.Bytes()
+}
+
+// tap is the point tapped and the group released in
+type tap struct {
+	point image.Point
+	group int64
+}
+
+// group joins the events and the groups of the events
+func group(events ...interface{}) []ev {
+	var g []ev
+	for _, e := range events {
+		switch e := e.(type) {
+		case ev:
+			g = append(g, e)
+		case []ev:
+			g = append(g, e...)
+		}
+	}
+	return g
+}
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []byte
+		taps   []tap
+	}{
+		{
+			name: "single touch",
+			stream: record(
+				group(touch(1), pos(100, 200), syn),
+				group(pos(110, 210), syn),
+				group(touch(0), syn),
+			),
+			taps: []tap{{image.Pt(100, 200), 2}},
+		},
+		{
+			name: "one finger",
+			stream: record(
+				group(slot(0), trackingID(5), mtPos(300, 400), touch(1), pos(300, 400), syn),
+				group(mtPos(310, 405), pos(310, 405), syn),
+				group(trackingID(-1), touch(0), syn),
+			),
+			taps: []tap{{image.Pt(300, 400), 2}},
+		},
+		{
+			name: "second finger released first",
+			stream: record(
+				group(slot(0), trackingID(5), mtPos(10, 20), touch(1), pos(10, 20), syn),
+				group(slot(1), trackingID(6), mtPos(500, 600), syn),
+				group(trackingID(-1), syn),
+				group(slot(0), mtPos(15, 25), pos(15, 25), syn),
+				group(trackingID(-1), touch(0), syn),
+			),
+			taps: []tap{{image.Pt(10, 20), 4}},
+		},
+		{
+			name: "two fingers at once",
+			stream: record(
+				group(slot(0), trackingID(5), mtPos(10, 20), slot(1), trackingID(6), mtPos(500, 600), touch(1), syn),
+				group(trackingID(-1), syn),
+				group(slot(0), trackingID(-1), touch(0), syn),
+			),
+			taps: []tap{{image.Pt(10, 20), 2}},
+		},
+		{
+			name: "second finger in slot 0",
+			stream: record(
+				group(slot(1), trackingID(7), mtPos(50, 60), touch(1), syn),
+				group(slot(0), trackingID(8), mtPos(900, 900), syn),
+				group(slot(1), mtPos(55, 65), syn),
+				group(trackingID(-1), syn),
+				group(slot(0), trackingID(-1), touch(0), syn),
+			),
+			taps: []tap{{image.Pt(50, 60), 3}},
+		},
+		{
+			name: "two taps",
+			stream: record(
+				group(trackingID(1), mtPos(1, 2), syn),
+				group(trackingID(-1), syn),
+				group(trackingID(2), mtPos(3, 4), syn),
+				group(trackingID(-1), syn),
+			),
+			taps: []tap{{image.Pt(1, 2), 1}, {image.Pt(3, 4), 3}},
+		},
+	}
+	for _, test := range tests {
+		reader := evdev.NewEventReaderWithWordSize(bytes.NewReader(test.stream), 8)
+		tracker := newTracker(Calibration{})
+		var taps []tap
+		for {
+			event, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if released, ok := tracker.handle(event); ok {
+				taps = append(taps, tap{released.Point, released.Time.Unix()})
+			}
+		}
+		if len(taps) != len(test.taps) {
+			t.Errorf("%s: taps %v, want %v", test.name, taps, test.taps)
+			continue
+		}
+		for i := range taps {
+			if taps[i] != test.taps[i] {
+				t.Errorf("%s: taps %v, want %v", test.name, taps, test.taps)
+				break
+			}
+		}
+	}
+}
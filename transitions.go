@@ -0,0 +1,236 @@
+package hasp
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	atmel "github.com/rmcsoft/hasp/atmel/periph_gpio"
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/outputs"
+	"github.com/rmcsoft/hasp/sound"
+)
+
+// Guard is the condition of a Transition. It is evaluated against the
+// character context and the event before the transition is taken.
+type Guard func(ctx CharacterCtx, event events.Event) bool
+
+// Action is run when a state is entered or left, see Character.OnEnter
+type Action func(ctx CharacterCtx, event events.Event)
+
+// Transition is a transition of the FSM taken only if its guard holds.
+//
+// The guarded transitions of an event are tried in order, the first one
+// from the current state whose guard holds is taken. If none holds, the
+// transition of the event in EventDescs is taken if there is one, so an
+// unguarded EventDesc is the "else" of the guarded transitions.
+type Transition struct {
+	Name string
+	Src  []string
+	Dst  string
+	// Guard is optional, the transition is always taken without it
+	Guard Guard
+}
+
+// Transitions is a shorthand for defining the guarded transitions
+type Transitions = []Transition
+
+// guardedTransition is a Transition registered in the FSM
+// as the event named fsmEvent
+type guardedTransition struct {
+	Transition
+	fsmEvent string
+}
+
+// addTransitions registers each guarded transition as a separate FSM event
+func (c *Character) addTransitions(eventDescs EventDescs, transitions Transitions) EventDescs {
+	c.guarded = make(map[string][]guardedTransition)
+	c.guardedEvents = make(map[string]string)
+	for i, t := range transitions {
+		fsmEvent := fmt.Sprintf("%s#%d", t.Name, i)
+		c.guarded[t.Name] = append(c.guarded[t.Name], guardedTransition{t, fsmEvent})
+		c.guardedEvents[fsmEvent] = t.Name
+		eventDescs = append(eventDescs, EventDesc{Name: fsmEvent, Src: t.Src, Dst: t.Dst})
+	}
+	return eventDescs
+}
+
+// fsmEvent returns the FSM event to fire for the event:
+// the event of the first guarded transition that holds or the event itself
+func (c *Character) fsmEvent(event *events.Event) string {
+	transitions, ok := c.guarded[event.Name]
+	if !ok {
+		return event.Name
+	}
+
+	current := c.fsm.Current()
+	for _, t := range transitions {
+		if !containsState(t.Src, current) {
+			continue
+		}
+		if t.Guard == nil || t.Guard(c.ctx, *event) {
+			return t.fsmEvent
+		}
+		log.Debugf("The guard of %s from '%s' to '%s' does not hold", t.Name, current, t.Dst)
+	}
+	return event.Name
+}
+
+// eventName returns the name of the event the FSM event was fired for
+func (c *Character) eventName(fsmEvent string) string {
+	if name, ok := c.guardedEvents[fsmEvent]; ok {
+		return name
+	}
+	return fsmEvent
+}
+
+// OnEnter adds the actions run when the state is entered,
+// before the state itself is entered
+func (c *Character) OnEnter(state string, actions ...Action) {
+	c.enterActions[state] = append(c.enterActions[state], actions...)
+}
+
+// OnLeave adds the actions run when the state is left,
+// after the state itself agreed to be left
+func (c *Character) OnLeave(state string, actions ...Action) {
+	c.leaveActions[state] = append(c.leaveActions[state], actions...)
+}
+
+func runActions(actions []Action, ctx CharacterCtx, event events.Event) {
+	for _, action := range actions {
+		action(ctx, event)
+	}
+}
+
+// Not negates the guard
+func Not(guard Guard) Guard {
+	return func(ctx CharacterCtx, event events.Event) bool {
+		return !guard(ctx, event)
+	}
+}
+
+// AllOf holds if all the guards hold
+func AllOf(guards ...Guard) Guard {
+	return func(ctx CharacterCtx, event events.Event) bool {
+		for _, guard := range guards {
+			if !guard(ctx, event) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf holds if one of the guards holds
+func AnyOf(guards ...Guard) Guard {
+	return func(ctx CharacterCtx, event events.Event) bool {
+		for _, guard := range guards {
+			if guard(ctx, event) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// When holds if the condition is true, e.g. a sensor is high
+func When(condition func() bool) Guard {
+	return func(ctx CharacterCtx, event events.Event) bool {
+		return condition()
+	}
+}
+
+// CtxHas holds if the context has the key
+func CtxHas(key string) Guard {
+	return func(ctx CharacterCtx, event events.Event) bool {
+		_, ok := ctx[key]
+		return ok
+	}
+}
+
+// CtxEquals holds if the value of the key in the context equals the value
+func CtxEquals(key string, value interface{}) Guard {
+	return func(ctx CharacterCtx, event events.Event) bool {
+		v, ok := ctx[key]
+		return ok && v == value
+	}
+}
+
+// TurnsBelow holds if the visitor has said less than n utterances
+// in the conversation session
+func TurnsBelow(n int) Guard {
+	return func(ctx CharacterCtx, event events.Event) bool {
+		session := ConversationSession(ctx)
+		return session == nil || session.Turns < n
+	}
+}
+
+// VisitorPresent holds if the visitor is still there: it asks the presence
+// detector if it is set, otherwise all the sensors must be high
+func VisitorPresent(presence events.PresenceDetector, sensorsPins atmel.AtmelGpioPins) Guard {
+	if presence != nil {
+		return When(presence.Present)
+	}
+
+	pins := openInputPins(sensorsPins)
+	return func(ctx CharacterCtx, event events.Event) bool {
+		return events.CheckAllPins(pins)
+	}
+}
+
+// SetCtx sets the key of the context
+func SetCtx(key string, value interface{}) Action {
+	return func(ctx CharacterCtx, event events.Event) {
+		ctx[key] = value
+	}
+}
+
+// DeleteCtx removes the keys from the context
+func DeleteCtx(keys ...string) Action {
+	return func(ctx CharacterCtx, event events.Event) {
+		for _, key := range keys {
+			delete(ctx, key)
+		}
+	}
+}
+
+// ClipPlayer plays the clips, e.g. sound.SoundPlayer
+type ClipPlayer interface {
+	PlaySync(audioData *sound.AudioData)
+}
+
+// PlayClip plays the audio data, e.g. a chime. The character waits for the
+// clip, so it does not talk over the sound of the state: keep it short.
+func PlayClip(player ClipPlayer, audioData *sound.AudioData) Action {
+	return PlayPrompt(player, Prompts{"": audioData})
+}
+
+// PlayPrompt plays the prompt in the conversation language as PlayClip does
+func PlayPrompt(player ClipPlayer, prompts Prompts) Action {
+	return func(ctx CharacterCtx, event events.Event) {
+		if audioData := prompts.Get(Language(ctx)); audioData != nil {
+			player.PlaySync(audioData)
+		}
+	}
+}
+
+// OutputDriver drives the outputs, e.g. outputs.Controller
+type OutputDriver interface {
+	Apply(actions ...outputs.Action)
+}
+
+// DriveOutputs applies the output actions
+func DriveOutputs(driver OutputDriver, actions ...outputs.Action) Action {
+	return func(ctx CharacterCtx, event events.Event) {
+		driver.Apply(actions...)
+	}
+}
+
+// newWaitTimer fires WaitTimeout after the time, see CharacterParams.WaitTimes
+func newWaitTimer(waitTime time.Duration) events.EventSource {
+	return events.NewSingleEventSource("WaitTimer", func() *events.Event {
+		time.Sleep(waitTime)
+		return &events.Event{Name: events.StateWaitTimeoutName}
+	})
+}
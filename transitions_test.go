@@ -0,0 +1,359 @@
+package hasp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/looplab/fsm"
+
+	"github.com/rmcsoft/hasp/events"
+	"github.com/rmcsoft/hasp/haspaws"
+	"github.com/rmcsoft/hasp/sound"
+)
+
+// recordedState records the names of the events it is entered and left with
+type recordedState struct {
+	entered []string
+	left    []string
+	// stays refuses to leave the state
+	stays bool
+}
+
+func (s *recordedState) Enter(ctx CharacterCtx, event events.Event) (events.EventSources, error) {
+	s.entered = append(s.entered, event.Name)
+	return nil, nil
+}
+
+func (s *recordedState) Leave(ctx CharacterCtx, event events.Event) bool {
+	s.left = append(s.left, event.Name)
+	return !s.stays
+}
+
+func (s *recordedState) GetAnimation() string {
+	return ""
+}
+
+func (s *recordedState) GetSound() *sound.AudioData {
+	return nil
+}
+
+// newTransitionsCharacter creates the character of the states without the
+// animator and the sound player: the FSM only enters and leaves the states
+func newTransitionsCharacter(initState string, stateNames []string,
+	eventDescs EventDescs, transitions Transitions) (*Character, map[string]*recordedState) {
+	states := make(States)
+	recorded := make(map[string]*recordedState)
+	for _, name := range stateNames {
+		recorded[name] = &recordedState{}
+		states[name] = recorded[name]
+	}
+
+	c := &Character{
+		states:                 states,
+		ctx:                    make(CharacterCtx),
+		enterActions:           make(map[string][]Action),
+		leaveActions:           make(map[string][]Action),
+		eventSourceMultiplexer: events.NewEventSourceMultiplexer(),
+	}
+	c.fsm = fsm.NewFSM(
+		initState,
+		c.addTransitions(eventDescs, transitions),
+		fsm.Callbacks{
+			"enter_state": func(e *fsm.Event) {
+				c.enterStateCallbacks(e)
+			},
+			"leave_state": func(e *fsm.Event) {
+				c.leaveStateCallback(e)
+			},
+		},
+	)
+	return c, recorded
+}
+
+// fire fires the event as Run does
+func fire(t *testing.T, c *Character, name string) {
+	t.Helper()
+	event := &events.Event{Name: name}
+	if err := c.fsm.Event(c.fsmEvent(event)); err != nil {
+		t.Fatalf("%s from '%s': %v", name, c.fsm.Current(), err)
+	}
+}
+
+func TestFsmEventGuardOrder(t *testing.T) {
+	holds := func(ctx CharacterCtx, event events.Event) bool { return true }
+	fails := Not(holds)
+
+	tests := []struct {
+		name        string
+		transitions Transitions
+		dst         string
+	}{
+		{
+			name: "first holding guard",
+			transitions: Transitions{
+				{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "fullhelp", Guard: fails},
+				{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "help", Guard: holds},
+				{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "there", Guard: holds},
+			},
+			dst: "help",
+		},
+		{
+			name: "unguarded transition",
+			transitions: Transitions{
+				{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "fullhelp", Guard: fails},
+				{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "there"},
+				{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "help", Guard: holds},
+			},
+			dst: "there",
+		},
+		{
+			name: "other source",
+			transitions: Transitions{
+				{Name: "WaitTimeout", Src: []string{"listens"}, Dst: "fullhelp", Guard: holds},
+				{Name: "WaitTimeout", Src: []string{"idle", "triggered"}, Dst: "help", Guard: holds},
+			},
+			dst: "help",
+		},
+		{
+			name: "no guard holds",
+			transitions: Transitions{
+				{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "fullhelp", Guard: fails},
+				{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "help", Guard: fails},
+			},
+			dst: "idle",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := newTransitionsCharacter("triggered",
+				[]string{"triggered", "idle", "help", "fullhelp", "there", "listens"},
+				EventDescs{{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "idle"}},
+				test.transitions)
+			fire(t, c, "WaitTimeout")
+			if current := c.fsm.Current(); current != test.dst {
+				t.Errorf("'%s' is entered, want '%s'", current, test.dst)
+			}
+		})
+	}
+}
+
+func TestFsmEventFallback(t *testing.T) {
+	c, _ := newTransitionsCharacter("listens", []string{"listens", "processing", "idle"},
+		EventDescs{
+			{Name: "Stop", Src: []string{"listens"}, Dst: "idle"},
+			{Name: "SoundCaptured", Src: []string{"listens"}, Dst: "processing"},
+		},
+		Transitions{
+			{Name: "Stop", Src: []string{"processing"}, Dst: "idle"},
+		})
+
+	// The events without guarded transitions are fired as they are
+	if fsmEvent := c.fsmEvent(&events.Event{Name: "SoundCaptured"}); fsmEvent != "SoundCaptured" {
+		t.Errorf("SoundCaptured is fired as '%s'", fsmEvent)
+	}
+	// The unguarded EventDesc is taken from the other states
+	if fsmEvent := c.fsmEvent(&events.Event{Name: "Stop"}); fsmEvent != "Stop" {
+		t.Errorf("Stop from 'listens' is fired as '%s'", fsmEvent)
+	}
+	fire(t, c, "Stop")
+	if current := c.fsm.Current(); current != "idle" {
+		t.Errorf("'%s' is entered, want 'idle'", current)
+	}
+
+	// Without an EventDesc the event is rejected by the FSM
+	event := &events.Event{Name: "Stop"}
+	if err := c.fsm.Event(c.fsmEvent(event)); err == nil {
+		t.Errorf("Stop from 'idle' is accepted")
+	}
+}
+
+func TestEventName(t *testing.T) {
+	c, states := newTransitionsCharacter("triggered", []string{"triggered", "fullhelp", "idle"},
+		EventDescs{
+			{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "idle"},
+			{Name: "GoIdle", Src: []string{"fullhelp"}, Dst: "idle"},
+			{Name: "HotWordDetected", Src: []string{"idle"}, Dst: "triggered"},
+		},
+		Transitions{
+			{Name: "WaitTimeout", Src: []string{"triggered"}, Dst: "fullhelp", Guard: CtxHas("Visitor")},
+		})
+
+	var actions []string
+	c.OnEnter("fullhelp", func(ctx CharacterCtx, event events.Event) {
+		actions = append(actions, event.Name)
+	})
+
+	c.ctx["Visitor"] = true
+	fire(t, c, "WaitTimeout")
+	fire(t, c, "GoIdle")
+	fire(t, c, "HotWordDetected")
+	delete(c.ctx, "Visitor")
+	fire(t, c, "WaitTimeout")
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"triggered left", states["triggered"].left, []string{"WaitTimeout", "WaitTimeout"}},
+		{"fullhelp entered", states["fullhelp"].entered, []string{"WaitTimeout"}},
+		{"fullhelp actions", actions, []string{"WaitTimeout"}},
+		{"idle entered", states["idle"].entered, []string{"GoIdle", "WaitTimeout"}},
+	}
+	for _, test := range tests {
+		if len(test.got) != len(test.want) {
+			t.Errorf("%s with %v, want %v", test.name, test.got, test.want)
+			continue
+		}
+		for i := range test.want {
+			if test.got[i] != test.want[i] {
+				t.Errorf("%s with %v, want %v", test.name, test.got, test.want)
+				break
+			}
+		}
+	}
+
+	for fsmEvent, name := range c.guardedEvents {
+		if c.eventName(fsmEvent) != name || fsmEvent == name {
+			t.Errorf("FSM event '%s' is named '%s'", fsmEvent, c.eventName(fsmEvent))
+		}
+	}
+	if name := c.eventName("GoIdle"); name != "GoIdle" {
+		t.Errorf("GoIdle is named '%s'", name)
+	}
+}
+
+func TestLeaveActions(t *testing.T) {
+	c, states := newTransitionsCharacter("listens", []string{"listens", "processing", "idle"},
+		EventDescs{
+			{Name: "SoundCaptured", Src: []string{"listens"}, Dst: "processing"},
+			{Name: "Stop", Src: []string{"listens"}, Dst: "idle"},
+		}, nil)
+
+	var actions []string
+	c.OnEnter("processing", func(ctx CharacterCtx, event events.Event) {
+		actions = append(actions, "enter processing")
+	})
+	c.OnLeave("listens", func(ctx CharacterCtx, event events.Event) {
+		actions = append(actions, "leave listens on "+event.Name)
+	})
+
+	// The actions are not run if the state refuses to be left
+	states["listens"].stays = true
+	if err := c.fsm.Event("Stop"); err == nil {
+		t.Fatalf("The refused transition is taken")
+	}
+	states["listens"].stays = false
+	fire(t, c, "SoundCaptured")
+
+	want := []string{"leave listens on SoundCaptured", "enter processing"}
+	if len(actions) != len(want) || actions[0] != want[0] || actions[1] != want[1] {
+		t.Errorf("Actions %v, want %v", actions, want)
+	}
+}
+
+// recordedPlayer records the clips played
+type recordedPlayer struct {
+	played []*sound.AudioData
+}
+
+func (p *recordedPlayer) PlaySync(audioData *sound.AudioData) {
+	p.played = append(p.played, audioData)
+}
+
+func TestPlayPrompt(t *testing.T) {
+	chime := sound.NewMonoS16LE(16000, make([]byte, 2))
+	campana := sound.NewMonoS16LE(16000, make([]byte, 4))
+
+	tests := []struct {
+		name     string
+		action   func(player ClipPlayer) Action
+		language string
+		played   *sound.AudioData
+	}{
+		{"clip", func(player ClipPlayer) Action { return PlayClip(player, chime) }, "es", chime},
+		{"prompt", func(player ClipPlayer) Action {
+			return PlayPrompt(player, Prompts{"": chime, "es": campana})
+		}, "es", campana},
+		{"default prompt", func(player ClipPlayer) Action {
+			return PlayPrompt(player, Prompts{"": chime, "es": campana})
+		}, "fr", chime},
+		{"no prompt", func(player ClipPlayer) Action { return PlayPrompt(player, nil) }, "", nil},
+	}
+	for _, test := range tests {
+		player := &recordedPlayer{}
+		test.action(player)(CharacterCtx{CtxLanguage: test.language}, events.Event{})
+		switch {
+		case test.played == nil && len(player.played) != 0:
+			t.Errorf("%s: %d clips are played, want none", test.name, len(player.played))
+		case test.played != nil && (len(player.played) != 1 || player.played[0] != test.played):
+			t.Errorf("%s: %d clips are played, want the one", test.name, len(player.played))
+		}
+	}
+}
+
+func TestTurnsBelow(t *testing.T) {
+	tests := []struct {
+		name  string
+		ctx   CharacterCtx
+		holds bool
+	}{
+		{"no session", CharacterCtx{}, true},
+		{"fewer turns", CharacterCtx{CtxSession: &haspaws.Session{Turns: 2}}, true},
+		{"as many turns", CharacterCtx{CtxSession: &haspaws.Session{Turns: 3}}, false},
+	}
+	for _, test := range tests {
+		if holds := TurnsBelow(3)(test.ctx, events.Event{}); holds != test.holds {
+			t.Errorf("%s: TurnsBelow(3) is %v, want %v", test.name, holds, test.holds)
+		}
+	}
+}
+
+func TestWaitTimes(t *testing.T) {
+	c, states := newTransitionsCharacter("idle", []string{"idle", "triggered", "fullhelp"},
+		EventDescs{
+			{Name: "VisitorApproached", Src: []string{"idle"}, Dst: "triggered"},
+			{Name: events.StateWaitTimeoutName, Src: []string{"triggered"}, Dst: "idle"},
+		},
+		Transitions{
+			{Name: events.StateWaitTimeoutName, Src: []string{"triggered"}, Dst: "fullhelp", Guard: CtxHas("Visitor")},
+		})
+	c.waitTimes = map[string]time.Duration{"triggered": time.Millisecond}
+
+	next := func() string {
+		t.Helper()
+		ch := make(chan *events.Event, 1)
+		go func() {
+			ch <- c.eventSourceMultiplexer.NextEvent()
+		}()
+		select {
+		case event := <-ch:
+			if err := c.fsm.Event(c.fsmEvent(event)); err != nil {
+				t.Fatalf("%s from '%s': %v", event.Name, c.fsm.Current(), err)
+			}
+			return event.Name
+		case <-time.After(time.Second):
+			t.Fatalf("No event in '%s'", c.fsm.Current())
+			return ""
+		}
+	}
+
+	fire(t, c, "VisitorApproached")
+	if name := next(); name != events.StateWaitTimeoutName {
+		t.Fatalf("%s is fired, want %s", name, events.StateWaitTimeoutName)
+	}
+	if current := c.fsm.Current(); current != "idle" {
+		t.Errorf("'%s' is entered on the timeout, want 'idle'", current)
+	}
+
+	// The timer is started again on each entry
+	c.ctx["Visitor"] = true
+	fire(t, c, "VisitorApproached")
+	next()
+	if current := c.fsm.Current(); current != "fullhelp" {
+		t.Errorf("'%s' is entered on the timeout with the visitor, want 'fullhelp'", current)
+	}
+	if len(states["fullhelp"].entered) != 1 || states["fullhelp"].entered[0] != events.StateWaitTimeoutName {
+		t.Errorf("fullhelp is entered with %v", states["fullhelp"].entered)
+	}
+}
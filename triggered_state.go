@@ -1,33 +1,39 @@
 package hasp
 
 import (
-	"time"
-
-	atmel "github.com/rmcsoft/hasp/atmel/periph_gpio"
 	"github.com/rmcsoft/hasp/events"
 	"github.com/rmcsoft/hasp/sound"
-	"periph.io/x/periph/conn/gpio"
 )
 
 type triggeredState struct {
 	availableAnimation string
 	hotWordDetector    *sound.HotWordDetector
-	sensorsPins        []gpio.PinIO
-	waitTime           time.Duration
+	presence           events.PresenceDetector
 }
 
-// NewTriggeredState creates new TriggeredState
-func NewTriggeredState(availableAnimation string,
-	hotWordDetector *sound.HotWordDetector, sensorsPins atmel.AtmelGpioPins,
-	waitTime time.Duration) State {
+// TriggeredStateParams TriggeredState params
+type TriggeredStateParams struct {
+	AvailableAnimation string
+	HotWordDetector    *sound.HotWordDetector
 
-	atmelPins := loadAtmelPeriph(sensorsPins)
+	// Presence is optional. If set, the state gets presence events.
+	Presence events.PresenceDetector
+}
 
+// NewTriggeredState creates new TriggeredState
+func NewTriggeredState(availableAnimation string, hotWordDetector *sound.HotWordDetector) State {
+	return NewTriggeredStateWithParams(TriggeredStateParams{
+		AvailableAnimation: availableAnimation,
+		HotWordDetector:    hotWordDetector,
+	})
+}
+
+// NewTriggeredStateWithParams creates new TriggeredState
+func NewTriggeredStateWithParams(params TriggeredStateParams) State {
 	return &triggeredState{
-		availableAnimation: availableAnimation,
-		hotWordDetector:    hotWordDetector,
-		sensorsPins:        atmelPins,
-		waitTime:           waitTime,
+		availableAnimation: params.AvailableAnimation,
+		hotWordDetector:    params.HotWordDetector,
+		presence:           params.Presence,
 	}
 }
 
@@ -40,18 +46,8 @@ func (s *triggeredState) Enter(ctx CharacterCtx, event events.Event) (events.Eve
 	sources := events.EventSources{
 		detectorEventSource,
 	}
-
-	if s.waitTime > 0 {
-		src := events.NewSingleEventSource("WaitTimer",
-			func() *events.Event {
-				time.Sleep(s.waitTime)
-				if events.CheckAllPins(s.sensorsPins) {
-					return &events.Event{Name: events.StateFullHelpName }
-				} else {
-					return &events.Event{Name: events.StateWaitTimeoutName }
-				}
-			})
-		sources = append(sources, src)
+	if s.presence != nil {
+		sources = append(sources, s.presence.EventSource())
 	}
 
 	return sources, nil